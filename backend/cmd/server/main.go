@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"time"
+	_ "time/tzdata"
 
 	"sup-anapa/backend/internal/config"
+	"sup-anapa/backend/internal/db"
 	httpHandler "sup-anapa/backend/internal/http"
 	"sup-anapa/backend/internal/repository"
 	"sup-anapa/backend/internal/service"
@@ -15,9 +19,79 @@ func main() {
 	if err != nil {
 		log.Fatalf("config error: %v", err)
 	}
-	repo := repository.New()
-	weather := service.NewWeatherService(repo, cfg.WeatherAPIURL, cfg.WeatherCacheMin)
-	h := httpHandler.NewHandler(repo, weather)
+	var repo repository.Repository
+	if cfg.DatabaseURL != "" {
+		pool, err := db.Connect(context.Background(), cfg.DatabaseURL, int32(cfg.DBMaxConns))
+		if err != nil {
+			log.Fatalf("database error: %v", err)
+		}
+		defer pool.Close()
+		repo = repository.NewPostgres(pool)
+	} else {
+		log.Printf("DATABASE_URL is empty, using in-memory storage")
+		repo = repository.New()
+	}
+	weatherURLs := map[string]string{service.OpenMeteoName: cfg.WeatherAPIURL, service.MetNorwayName: cfg.MetAPIURL}
+	var providers []service.WeatherProvider
+	for _, name := range cfg.WeatherProviders {
+		p, err := service.NewWeatherProvider(name, weatherURLs[name])
+		if err != nil {
+			log.Fatalf("config error: %v", err)
+		}
+		providers = append(providers, p)
+	}
+	parseRules := service.ParseWeatherRules
+	if cfg.WeatherRulesYAML {
+		parseRules = service.ParseWeatherRulesYAML
+	}
+	weatherRules, err := parseRules([]byte(cfg.WeatherRules))
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	weather := service.NewWeatherService(repo, repo, repo, providers, cfg.MarineAPIURL, cfg.WeatherTimeout, cfg.WeatherGuardBudget, cfg.WeatherCacheMin, cfg.WeatherBlockUnsafe, weatherRules)
+	pricing := service.NewPricingService(repo, repo, repo, repo)
+	notifier, err := service.NewNotifier(cfg.NotifyWebhookURL, cfg.NotifySecret)
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	if cfg.NotifyWebhookURL == "" {
+		log.Printf("NOTIFY_WEBHOOK_URL is empty, reschedule and waitlist offers are not sent to customers")
+	}
+	waitlist := service.NewWaitlistService(repo, repo, notifier, cfg.WaitlistOfferTTL)
+	promo := service.NewPromoService(repo, pricing)
+	gifts := service.NewGiftService(repo)
+	booking := service.NewBookingService(repo, pricing, promo, gifts, waitlist, weather, cfg.HoldTTL, cfg.IdempotencyTTL)
+	go service.RunPeriodic(context.Background(), "hold reaper", cfg.HoldReapInterval, booking.ReleaseExpiredHolds)
+	offers := service.NewRescheduleOfferService(repo, weather, booking, notifier, cfg.RescheduleAhead)
+	go service.RunPeriodic(context.Background(), "idempotency purge", time.Hour, booking.PurgeIdempotencyKeys)
+	go service.RunPeriodic(context.Background(), "weather reschedule offers", cfg.RescheduleScan, offers.ScanBookings)
+	if cfg.AdminAPIKey == "" {
+		log.Printf("ADMIN_API_KEY is empty, /api/admin accepts only issued API keys")
+	}
+	auth := service.NewAuthService(repo, repo, cfg.AdminAPIKey)
+	instructor := service.NewInstructorService(repo, booking)
+	schedule := service.NewScheduleService(repo, cfg.ScheduleHorizon)
+	go service.RunPeriodic(context.Background(), "schedule generator", time.Hour, schedule.GenerateAhead)
+	slots := service.NewSlotService(repo)
+	inventory := service.NewInventoryService(repo)
+	options := service.NewOptionService(repo)
+	provider, err := service.NewPaymentProvider(cfg.PaymentProvider, cfg.PaymentSecret)
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	switch cfg.PaymentProvider {
+	case "":
+		log.Printf("PAYMENT_PROVIDER is empty, bookings stay pending until staff confirm them")
+	case "fake":
+		log.Printf("PAYMENT_PROVIDER is fake, payments are simulated")
+	}
+	payments := service.NewPaymentService(repo, booking, provider, cfg.PaymentMode, cfg.DepositPercent)
+	policy, err := service.ParseRefundPolicy(cfg.RefundPolicy)
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	refunds := service.NewRefundService(repo, booking, payments, weather, policy)
+	h := httpHandler.NewHandler(repo, weather, booking, waitlist, offers, auth, instructor, schedule, slots, inventory, options, promo, gifts, payments, refunds)
 	mux := http.NewServeMux()
 	h.Register(mux)
 	log.Printf("backend started on :%s", cfg.Port)
@@ -3,30 +3,145 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
 	Port               string
+	DatabaseURL        string
+	DBMaxConns         int
+	WeatherProviders   []string
 	WeatherAPIURL      string
+	MetAPIURL          string
+	WeatherTimeout     time.Duration
+	WeatherGuardBudget time.Duration
+	MarineAPIURL       string
 	WeatherCacheMin    time.Duration
 	DefaultLocationLat float64
 	DefaultLocationLng float64
+	HoldTTL            time.Duration
+	HoldReapInterval   time.Duration
+	IdempotencyTTL     time.Duration
+	WaitlistOfferTTL   time.Duration
+	RescheduleScan     time.Duration
+	RescheduleAhead    time.Duration
+	AdminAPIKey        string
+	ScheduleHorizon    int
+	PaymentProvider    string
+	PaymentAllowFake   bool
+	PaymentMode        string
+	DepositPercent     int
+	PaymentSecret      string
+	RefundPolicy       string
+	WeatherBlockUnsafe bool
+	WeatherRules       string
+	WeatherRulesYAML   bool
+	NotifyWebhookURL   string
+	NotifySecret       string
 }
 
+// placeholderKeys are well-known example values that must never become the
+// owner key.
+var placeholderKeys = map[string]bool{"change-me": true, "changeme": true, "change_me": true, "admin": true, "secret": true, "password": true, "owner": true, "test": true}
+
 func Load() (Config, error) {
 	cacheMin := getEnvInt("WEATHER_CACHE_MINUTES", 20)
 	cfg := Config{
 		Port:               getEnv("PORT", "8080"),
+		DatabaseURL:        getEnv("DATABASE_URL", ""),
+		DBMaxConns:         getEnvInt("DB_MAX_CONNS", 10),
 		WeatherAPIURL:      getEnv("WEATHER_API_URL", "https://api.open-meteo.com/v1/forecast"),
+		MetAPIURL:          getEnv("MET_API_URL", "https://api.met.no/weatherapi/locationforecast/2.0/complete"),
+		WeatherTimeout:     time.Duration(getEnvInt("WEATHER_TIMEOUT_SECONDS", 5)) * time.Second,
+		WeatherGuardBudget: time.Duration(getEnvInt("WEATHER_GUARD_TIMEOUT_MS", 1500)) * time.Millisecond,
+		MarineAPIURL:       getEnv("MARINE_API_URL", "https://marine-api.open-meteo.com/v1/marine"),
 		WeatherCacheMin:    time.Duration(cacheMin) * time.Minute,
 		DefaultLocationLat: getEnvFloat("DEFAULT_LOCATION_LAT", 45.092),
 		DefaultLocationLng: getEnvFloat("DEFAULT_LOCATION_LNG", 37.268),
+		HoldTTL:            time.Duration(getEnvInt("HOLD_TTL_MINUTES", 10)) * time.Minute,
+		HoldReapInterval:   time.Duration(getEnvInt("HOLD_REAP_SECONDS", 30)) * time.Second,
+		IdempotencyTTL:     time.Duration(getEnvInt("IDEMPOTENCY_RETENTION_HOURS", 24)) * time.Hour,
+		WaitlistOfferTTL:   time.Duration(getEnvInt("WAITLIST_OFFER_MINUTES", 30)) * time.Minute,
+		RescheduleScan:     time.Duration(getEnvInt("RESCHEDULE_SCAN_MINUTES", 60)) * time.Minute,
+		RescheduleAhead:    time.Duration(getEnvInt("RESCHEDULE_LOOKAHEAD_HOURS", 48)) * time.Hour,
+		AdminAPIKey:        getEnv("ADMIN_API_KEY", ""),
+		ScheduleHorizon:    getEnvInt("SCHEDULE_HORIZON_DAYS", 14),
+		PaymentProvider:    getEnv("PAYMENT_PROVIDER", ""),
+		PaymentAllowFake:   getEnvBool("PAYMENT_ALLOW_FAKE", false),
+		PaymentMode:        getEnv("PAYMENT_MODE", "full"),
+		DepositPercent:     getEnvInt("PAYMENT_DEPOSIT_PERCENT", 30),
+		PaymentSecret:      getEnv("PAYMENT_WEBHOOK_SECRET", ""),
+		RefundPolicy:       getEnv("REFUND_POLICY", ""),
+		WeatherBlockUnsafe: getEnvBool("WEATHER_BLOCK_UNSAFE", true),
+		WeatherRules:       getEnv("WEATHER_RULES", ""),
+		NotifyWebhookURL:   getEnv("NOTIFY_WEBHOOK_URL", ""),
+		NotifySecret:       getEnv("NOTIFY_WEBHOOK_SECRET", ""),
+	}
+	if path := getEnv("WEATHER_RULES_FILE", ""); path != "" {
+		if cfg.WeatherRules != "" {
+			return Config{}, fmt.Errorf("set only one of WEATHER_RULES and WEATHER_RULES_FILE")
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("WEATHER_RULES_FILE: %w", err)
+		}
+		cfg.WeatherRules = string(b)
+		ext := strings.ToLower(filepath.Ext(path))
+		cfg.WeatherRulesYAML = ext == ".yaml" || ext == ".yml"
+	}
+	for _, name := range strings.Split(getEnv("WEATHER_PROVIDERS", "open-meteo,met-norway"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.WeatherProviders = append(cfg.WeatherProviders, name)
+		}
+	}
+	if cfg.MarineAPIURL == "off" {
+		cfg.MarineAPIURL = ""
 	}
 	if cfg.Port == "" {
 		return Config{}, fmt.Errorf("PORT is required")
 	}
+	if cfg.AdminAPIKey != "" && (placeholderKeys[strings.ToLower(cfg.AdminAPIKey)] || len(cfg.AdminAPIKey) < 16) {
+		return Config{}, fmt.Errorf("ADMIN_API_KEY grants the owner role; use a random value of at least 16 characters, not a placeholder")
+	}
+	if cfg.HoldTTL <= 0 || cfg.HoldReapInterval <= 0 {
+		return Config{}, fmt.Errorf("HOLD_TTL_MINUTES and HOLD_REAP_SECONDS must be positive")
+	}
+	if len(cfg.WeatherProviders) == 0 {
+		return Config{}, fmt.Errorf("WEATHER_PROVIDERS must name at least one provider")
+	}
+	if cfg.WeatherTimeout <= 0 {
+		return Config{}, fmt.Errorf("WEATHER_TIMEOUT_SECONDS must be positive")
+	}
+	if cfg.WeatherGuardBudget <= 0 {
+		return Config{}, fmt.Errorf("WEATHER_GUARD_TIMEOUT_MS must be positive")
+	}
+	if cfg.WaitlistOfferTTL <= 0 {
+		return Config{}, fmt.Errorf("WAITLIST_OFFER_MINUTES must be positive")
+	}
+	if cfg.ScheduleHorizon < 1 {
+		return Config{}, fmt.Errorf("SCHEDULE_HORIZON_DAYS must be positive")
+	}
+	if cfg.RescheduleScan <= 0 {
+		return Config{}, fmt.Errorf("RESCHEDULE_SCAN_MINUTES must be positive")
+	}
+	if cfg.PaymentProvider == "fake" && !cfg.PaymentAllowFake {
+		return Config{}, fmt.Errorf("PAYMENT_PROVIDER=fake confirms payments without charging; set PAYMENT_ALLOW_FAKE=true for development only")
+	}
+	if cfg.PaymentProvider != "" && cfg.PaymentSecret == "" {
+		return Config{}, fmt.Errorf("PAYMENT_WEBHOOK_SECRET is required with PAYMENT_PROVIDER")
+	}
+	if cfg.NotifyWebhookURL != "" && cfg.NotifySecret == "" {
+		return Config{}, fmt.Errorf("NOTIFY_WEBHOOK_SECRET is required with NOTIFY_WEBHOOK_URL")
+	}
+	if cfg.PaymentMode != "full" && cfg.PaymentMode != "deposit" {
+		return Config{}, fmt.Errorf("PAYMENT_MODE must be full or deposit")
+	}
+	if cfg.DepositPercent < 1 || cfg.DepositPercent > 100 {
+		return Config{}, fmt.Errorf("PAYMENT_DEPOSIT_PERCENT must be between 1 and 100")
+	}
 	return cfg, nil
 }
 
@@ -52,3 +167,11 @@ func getEnvFloat(key string, fallback float64) float64 {
 	}
 	return fallback
 }
+func getEnvBool(key string, fallback bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
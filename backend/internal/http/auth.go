@@ -0,0 +1,92 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/service"
+)
+
+type principalKey struct{}
+
+// authenticate guards the admin and instructor subtrees: every request
+// needs "Authorization: Bearer <key>".
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		p, err := h.auth.Authenticate(strings.TrimSpace(key))
+		if err != nil {
+			code := 500
+			if errors.Is(err, service.ErrUnauthorized) {
+				code = 401
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			writeErr(w, code, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+// allow lets only the given roles through to next.
+func allow(next http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(roles, principal(r).Role) {
+			writeErrMsg(w, 403, "forbidden for role "+principal(r).Role)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func principal(r *http.Request) models.APIKey {
+	p, _ := r.Context().Value(principalKey{}).(models.APIKey)
+	return p
+}
+
+func (h *Handler) apiKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := h.keys.ListAPIKeys()
+		if err != nil {
+			writeErr(w, 500, err)
+			return
+		}
+		writeJSON(w, 200, items)
+	case http.MethodPost:
+		var req models.APIKey
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+			writeErrMsg(w, 400, "name and role required")
+			return
+		}
+		key, err := h.auth.IssueKey(&req)
+		if err != nil {
+			code := 500
+			if errors.Is(err, service.ErrInvalidRole) || errors.Is(err, repository.ErrNotFound) {
+				code = 400
+			}
+			writeErr(w, code, err)
+			return
+		}
+		writeJSON(w, 201, map[string]any{"key": key, "api_key": req})
+	default:
+		writeJSON(w, 405, nil)
+	}
+}
+func (h *Handler) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, 405, nil)
+		return
+	}
+	if err := h.keys.RevokeAPIKey(strings.TrimPrefix(r.URL.Path, "/api/admin/api-keys/")); err != nil {
+		writeErr(w, statusErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"ok": true})
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"testing"
+
+	"sup-anapa/backend/internal/models"
+)
+
+// issueKey has the owner issue an API key and returns its secret.
+func (s *testServer) issueKey(t *testing.T, role, instructorID string) (string, models.APIKey) {
+	t.Helper()
+	var got struct {
+		Key    string        `json:"key"`
+		APIKey models.APIKey `json:"api_key"`
+	}
+	req := models.APIKey{Name: role, Role: role, InstructorID: instructorID}
+	if code := s.do(t, "POST", "/api/admin/api-keys", ownerKey, req, &got); code != 201 {
+		t.Fatalf("issue %s key = %d", role, code)
+	}
+	return got.Key, got.APIKey
+}
+
+func TestAPIKeyRoles(t *testing.T) {
+	srv := newTestServer(t)
+	dispatcher, issued := srv.issueKey(t, models.RoleDispatcher, "")
+	instructor, _ := srv.issueKey(t, models.RoleInstructor, seedInstructor)
+
+	for _, req := range []models.APIKey{
+		{Name: "x", Role: "root"},
+		{Name: "x", Role: models.RoleInstructor},
+		{Name: "x", Role: models.RoleInstructor, InstructorID: "99999999999999999999999999999999"},
+	} {
+		if code := srv.do(t, "POST", "/api/admin/api-keys", ownerKey, req, nil); code != 400 {
+			t.Errorf("issue %+v = %d, want 400", req, code)
+		}
+	}
+
+	tests := []struct {
+		path string
+		key  string
+		code int
+	}{
+		{"/api/admin/waitlist", "", 401},
+		{"/api/admin/waitlist", "not-a-key", 401},
+		{"/api/admin/api-keys", ownerKey, 200},
+		{"/api/admin/api-keys", dispatcher, 403},
+		{"/api/admin/waitlist", dispatcher, 200},
+		{"/api/admin/waitlist", instructor, 403},
+		{"/api/instructor/schedule", instructor, 200},
+		{"/api/instructor/schedule", dispatcher, 403},
+	}
+	for _, tt := range tests {
+		if code := srv.do(t, "GET", tt.path, tt.key, nil, nil); code != tt.code {
+			t.Errorf("GET %s with key %q = %d, want %d", tt.path, tt.key, code, tt.code)
+		}
+	}
+
+	if code := srv.do(t, "DELETE", "/api/admin/api-keys/"+issued.ID, ownerKey, nil, nil); code != 200 {
+		t.Fatalf("revoke = %d", code)
+	}
+	if code := srv.do(t, "GET", "/api/admin/waitlist", dispatcher, nil, nil); code != 401 {
+		t.Errorf("revoked key = %d, want 401", code)
+	}
+}
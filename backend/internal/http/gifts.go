@@ -0,0 +1,98 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/service"
+)
+
+// giftBalance lets a customer check a certificate before booking; the
+// recipient and note stay admin-only.
+func (h *Handler) giftBalance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	g, err := h.gifts.Get(strings.TrimPrefix(r.URL.Path, "/api/gift-certificates/"))
+	if err != nil {
+		writeErr(w, giftErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"code": g.Code, "kind": g.Kind, "balance": g.Balance, "route_id": g.RouteID, "participants": g.Participants, "status": g.Status, "expires_at": g.ExpiresAt})
+}
+func (h *Handler) adminGifts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := h.giftStore.ListGifts(r.URL.Query().Get("status"))
+		if err != nil {
+			writeErr(w, 500, err)
+			return
+		}
+		writeJSON(w, 200, items)
+	case http.MethodPost:
+		var g models.GiftCertificate
+		if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+			writeErr(w, 400, err)
+			return
+		}
+		if err := h.gifts.Issue(&g); err != nil {
+			writeErr(w, giftErrCode(err), err)
+			return
+		}
+		writeJSON(w, 201, g)
+	default:
+		writeJSON(w, 405, nil)
+	}
+}
+
+// adminGift serves GET /api/admin/gift-certificates/{code} with its
+// redemptions and POST .../{code}/void.
+func (h *Handler) adminGift(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/admin/gift-certificates/")
+	if code, ok := strings.CutSuffix(rest, "/void"); ok {
+		if r.Method != http.MethodPost {
+			writeJSON(w, 405, nil)
+			return
+		}
+		g, err := h.gifts.Void(code)
+		if err != nil {
+			writeErr(w, giftErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, g)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	g, err := h.gifts.Get(rest)
+	if err != nil {
+		writeErr(w, giftErrCode(err), err)
+		return
+	}
+	redemptions, err := h.giftStore.ListGiftRedemptions(g.Code)
+	if err != nil {
+		writeErr(w, 500, err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"certificate": g, "redemptions": redemptions})
+}
+
+func giftErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidGift):
+		return 400
+	case errors.Is(err, repository.ErrNotFound):
+		return 404
+	case errors.Is(err, repository.ErrGiftClosed):
+		return 409
+	default:
+		return 500
+	}
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,12 +14,38 @@ import (
 )
 
 type Handler struct {
-	repo    *repository.Repository
-	weather *service.WeatherService
+	instructors repository.InstructorStore
+	routes      repository.RouteStore
+	slots       repository.SlotStore
+	bookings    repository.BookingStore
+	holds       repository.HoldStore
+	weather     *service.WeatherService
+	booking     *service.BookingService
+	waitlist    *service.WaitlistService
+	queue       repository.WaitlistStore
+	offers      *service.RescheduleOfferService
+	offerStore  repository.RescheduleOfferStore
+	auth        *service.AuthService
+	keys        repository.APIKeyStore
+	instructor  *service.InstructorService
+	schedule    *service.ScheduleService
+	templates   repository.TemplateStore
+	slotService *service.SlotService
+	inventory   *service.InventoryService
+	stations    repository.InventoryStore
+	options     repository.OptionStore
+	catalog     *service.OptionService
+	promo       *service.PromoService
+	promoStore  repository.PromoStore
+	gifts       *service.GiftService
+	giftStore   repository.GiftStore
+	payments    *service.PaymentService
+	payStore    repository.PaymentStore
+	refunds     *service.RefundService
 }
 
-func NewHandler(repo *repository.Repository, weather *service.WeatherService) *Handler {
-	return &Handler{repo: repo, weather: weather}
+func NewHandler(repo repository.Repository, weather *service.WeatherService, booking *service.BookingService, waitlist *service.WaitlistService, offers *service.RescheduleOfferService, auth *service.AuthService, instructor *service.InstructorService, schedule *service.ScheduleService, slotService *service.SlotService, inventory *service.InventoryService, catalog *service.OptionService, promo *service.PromoService, gifts *service.GiftService, payments *service.PaymentService, refunds *service.RefundService) *Handler {
+	return &Handler{instructors: repo, routes: repo, slots: repo, bookings: repo, holds: repo, weather: weather, booking: booking, waitlist: waitlist, queue: repo, offers: offers, offerStore: repo, auth: auth, keys: repo, instructor: instructor, schedule: schedule, templates: repo, slotService: slotService, inventory: inventory, stations: repo, options: repo, catalog: catalog, promo: promo, promoStore: repo, gifts: gifts, giftStore: repo, payments: payments, payStore: repo, refunds: refunds}
 }
 
 func (h *Handler) Register(mux *http.ServeMux) {
@@ -26,14 +53,53 @@ func (h *Handler) Register(mux *http.ServeMux) {
 	mux.HandleFunc("/api/instructors", h.listInstructors)
 	mux.HandleFunc("/api/instructors/", h.getInstructor)
 	mux.HandleFunc("/api/routes", h.listRoutes)
+	mux.HandleFunc("/api/options", h.listOptions)
+	mux.HandleFunc("/api/promo-codes/validate", h.validatePromo)
+	mux.HandleFunc("/api/gift-certificates/", h.giftBalance)
+	mux.HandleFunc("/api/payments/webhook", h.paymentWebhook)
 	mux.HandleFunc("/api/availability", h.listAvailability)
 	mux.HandleFunc("/api/weather", h.getWeather)
 	mux.HandleFunc("/api/bookings", h.createBooking)
 	mux.HandleFunc("/api/bookings/", h.getBooking)
-	mux.HandleFunc("/api/admin/instructors", h.upsertInstructor)
-	mux.HandleFunc("/api/admin/routes", h.upsertRoute)
-	mux.HandleFunc("/api/admin/availability/bulk", h.bulkSlots)
-	mux.HandleFunc("/api/admin/bookings/", h.patchBookingStatus)
+	mux.HandleFunc("/api/holds", h.createHold)
+	mux.HandleFunc("/api/holds/", h.hold)
+	mux.HandleFunc("/api/waitlist", h.joinWaitlist)
+	mux.HandleFunc("/api/waitlist/", h.waitlistEntry)
+	mux.HandleFunc("/api/reschedule-offers/", h.rescheduleOffer)
+
+	staff := []string{models.RoleOwner, models.RoleDispatcher}
+	all := []string{models.RoleOwner, models.RoleDispatcher, models.RoleInstructor}
+	admin := http.NewServeMux()
+	admin.HandleFunc("/api/admin/instructors", allow(h.upsertInstructor, models.RoleOwner))
+	admin.HandleFunc("/api/admin/routes", allow(h.upsertRoute, models.RoleOwner))
+	admin.HandleFunc("/api/admin/options", allow(h.adminOptions, models.RoleOwner))
+	admin.HandleFunc("/api/admin/options/", allow(h.adminOption, models.RoleOwner))
+	admin.HandleFunc("/api/admin/promo-codes", allow(h.adminPromoCodes, models.RoleOwner))
+	admin.HandleFunc("/api/admin/promo-codes/", allow(h.adminPromoCode, models.RoleOwner))
+	admin.HandleFunc("/api/admin/gift-certificates", allow(h.adminGifts, staff...))
+	admin.HandleFunc("/api/admin/gift-certificates/", allow(h.adminGift, staff...))
+	admin.HandleFunc("/api/admin/stations", allow(h.adminStations, staff...))
+	admin.HandleFunc("/api/admin/stations/", allow(h.adminStation, staff...))
+	admin.HandleFunc("/api/admin/availability/bulk", allow(h.bulkSlots, all...))
+	admin.HandleFunc("/api/admin/bookings/", allow(h.adminBookings, all...))
+	admin.HandleFunc("/api/admin/payments/", allow(h.refundPayment, staff...))
+	admin.HandleFunc("/api/admin/reports/consistency", allow(h.consistencyReport, staff...))
+	admin.HandleFunc("/api/admin/weather/rules", allow(h.adminWeatherRules, staff...))
+	admin.HandleFunc("/api/admin/weather/preview", allow(h.previewWeatherScore, staff...))
+	admin.HandleFunc("/api/admin/waitlist", allow(h.adminListWaitlist, staff...))
+	admin.HandleFunc("/api/admin/waitlist/", allow(h.adminWaitlistEntry, staff...))
+	admin.HandleFunc("/api/admin/reschedule-offers", allow(h.adminListRescheduleOffers, staff...))
+	admin.HandleFunc("/api/admin/schedule-templates", allow(h.scheduleTemplates, staff...))
+	admin.HandleFunc("/api/admin/schedule-templates/", allow(h.scheduleTemplate, staff...))
+	admin.HandleFunc("/api/admin/api-keys", allow(h.apiKeys, models.RoleOwner))
+	admin.HandleFunc("/api/admin/api-keys/", allow(h.revokeAPIKey, models.RoleOwner))
+	mux.Handle("/api/admin/", h.authenticate(admin))
+
+	portal := http.NewServeMux()
+	portal.HandleFunc("/api/instructor/schedule", allow(h.instructorSchedule, models.RoleInstructor))
+	portal.HandleFunc("/api/instructor/slots/", allow(h.instructorSlot, models.RoleInstructor))
+	portal.HandleFunc("/api/instructor/bookings/", allow(h.instructorAttendance, models.RoleInstructor))
+	mux.Handle("/api/instructor/", h.authenticate(portal))
 }
 
 func (h *Handler) listInstructors(w http.ResponseWriter, r *http.Request) {
@@ -44,7 +110,7 @@ func (h *Handler) listInstructors(w http.ResponseWriter, r *http.Request) {
 	minPrice, _ := strconv.Atoi(r.URL.Query().Get("min_price"))
 	maxPrice, _ := strconv.Atoi(r.URL.Query().Get("max_price"))
 	minRating, _ := strconv.ParseFloat(r.URL.Query().Get("min_rating"), 64)
-	items, err := h.repo.ListInstructors(minPrice, maxPrice, minRating, r.URL.Query().Get("tag"))
+	items, err := h.instructors.ListInstructors(minPrice, maxPrice, minRating, r.URL.Query().Get("tag"))
 	if err != nil {
 		writeErr(w, 500, err)
 		return
@@ -57,7 +123,7 @@ func (h *Handler) getInstructor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	id := strings.TrimPrefix(r.URL.Path, "/api/instructors/")
-	item, err := h.repo.GetInstructor(id)
+	item, err := h.instructors.GetInstructor(id)
 	if err != nil {
 		writeErrMsg(w, 404, "not found")
 		return
@@ -69,7 +135,7 @@ func (h *Handler) listRoutes(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, 405, nil)
 		return
 	}
-	items, err := h.repo.ListRoutes()
+	items, err := h.routes.ListRoutes()
 	if err != nil {
 		writeErr(w, 500, err)
 		return
@@ -86,7 +152,8 @@ func (h *Handler) listAvailability(w http.ResponseWriter, r *http.Request) {
 		writeErrMsg(w, 400, "invalid date")
 		return
 	}
-	slots, err := h.repo.ListAvailability(date, r.URL.Query().Get("route_id"), r.URL.Query().Get("instructor_id"))
+	includeFull := r.URL.Query().Get("include_full") == "true"
+	slots, err := h.slots.ListAvailability(date, r.URL.Query().Get("route_id"), r.URL.Query().Get("instructor_id"), includeFull)
 	if err != nil {
 		writeErr(w, 500, err)
 		return
@@ -98,6 +165,10 @@ func (h *Handler) getWeather(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, 405, nil)
 		return
 	}
+	if slotID := r.URL.Query().Get("slot_id"); slotID != "" {
+		h.slotWeather(w, slotID)
+		return
+	}
 	lat, err := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
 	if err != nil {
 		writeErrMsg(w, 400, "invalid lat")
@@ -120,6 +191,26 @@ func (h *Handler) getWeather(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, 200, resp)
 }
+
+// slotWeather scores every hour of the slot at its route's location.
+func (h *Handler) slotWeather(w http.ResponseWriter, slotID string) {
+	slot, err := h.slots.GetSlot(slotID)
+	if err != nil {
+		writeErr(w, statusErrCode(err), err)
+		return
+	}
+	route, err := h.routes.GetRoute(slot.RouteID)
+	if err != nil {
+		writeErr(w, statusErrCode(err), err)
+		return
+	}
+	resp, err := h.weather.ForSlot(route, slot)
+	if err != nil {
+		writeErr(w, 500, err)
+		return
+	}
+	writeJSON(w, 200, resp)
+}
 func (h *Handler) createBooking(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeJSON(w, 405, nil)
@@ -137,25 +228,117 @@ func (h *Handler) createBooking(w http.ResponseWriter, r *http.Request) {
 	if req.Options == nil {
 		req.Options = map[string]any{}
 	}
-	if err := h.repo.CreateBooking(&req); err != nil {
-		writeErrMsg(w, 400, "cannot book selected slot")
-		return
+	var err error
+	replayed := false
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		if len(key) > 255 {
+			writeErrMsg(w, 400, "Idempotency-Key is too long")
+			return
+		}
+		replayed, err = h.booking.CreateIdempotent(key, &req)
+	} else {
+		err = h.booking.Create(&req)
+	}
+	if err != nil {
+		var mismatch *service.PriceMismatchError
+		switch {
+		case errors.Is(err, service.ErrIdempotencyConflict), errors.Is(err, service.ErrIdempotencyInProgress), errors.Is(err, repository.ErrEquipmentUnavailable),
+			errors.Is(err, service.ErrUnsafeConditions), errors.Is(err, repository.ErrPromoExhausted), errors.Is(err, repository.ErrGiftClosed), errors.Is(err, repository.ErrGiftBalance):
+			writeErr(w, 409, err)
+		case errors.As(err, &mismatch):
+			writeJSON(w, 409, map[string]any{"error": err.Error(), "price_total": mismatch.Quote.Total, "price_breakdown": mismatch.Quote})
+		case errors.Is(err, service.ErrInvalidOption), errors.Is(err, service.ErrPromoRejected), errors.Is(err, service.ErrGiftRejected),
+			errors.Is(err, repository.ErrSlotMismatch):
+			writeErr(w, 422, err)
+		case errors.Is(err, repository.ErrHoldNotFound), errors.Is(err, repository.ErrHoldExpired):
+			writeErrMsg(w, 410, "seat hold expired, please select the slot again")
+		case errors.Is(err, repository.ErrHoldMismatch):
+			writeErr(w, 422, err)
+		case errors.Is(err, repository.ErrNotFound), errors.Is(err, repository.ErrSlotUnavailable):
+			writeErrMsg(w, 400, "cannot book selected slot")
+		default:
+			writeErr(w, 500, err)
+		}
+		return
+	}
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
 	}
 	writeJSON(w, 201, req)
 }
 func (h *Handler) getBooking(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/bookings/")
+	if id, ok := strings.CutSuffix(id, "/payments"); ok {
+		h.bookingPayments(w, r, id)
+		return
+	}
+	if id, ok := strings.CutSuffix(id, "/cancellation"); ok {
+		h.cancellationQuote(w, r, id, service.CancelByCustomer)
+		return
+	}
+	if id, ok := strings.CutSuffix(id, "/cancel"); ok {
+		h.cancelBooking(w, r, id)
+		return
+	}
 	if r.Method != http.MethodGet {
 		writeJSON(w, 405, nil)
 		return
 	}
-	id := strings.TrimPrefix(r.URL.Path, "/api/bookings/")
-	b, err := h.repo.GetBooking(id)
+	b, err := h.bookings.GetBooking(id)
 	if err != nil {
 		writeErrMsg(w, 404, "not found")
 		return
 	}
 	writeJSON(w, 200, b)
 }
+func (h *Handler) createHold(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, nil)
+		return
+	}
+	var req struct {
+		SlotID string `json:"slot_id"`
+		Seats  int    `json:"seats"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErr(w, 400, err)
+		return
+	}
+	if req.SlotID == "" || req.Seats < 1 {
+		writeErrMsg(w, 400, "slot_id and seats required")
+		return
+	}
+	hold, err := h.booking.HoldSeats(req.SlotID, req.Seats)
+	if err != nil {
+		if errors.Is(err, repository.ErrSlotUnavailable) {
+			writeErrMsg(w, 409, "not enough seats")
+			return
+		}
+		writeErr(w, 500, err)
+		return
+	}
+	writeJSON(w, 201, hold)
+}
+func (h *Handler) hold(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/api/holds/")
+	switch r.Method {
+	case http.MethodGet:
+		hold, err := h.holds.GetHold(token)
+		if err != nil {
+			writeErrMsg(w, 404, "not found")
+			return
+		}
+		writeJSON(w, 200, hold)
+	case http.MethodDelete:
+		if err := h.booking.ReleaseHold(token); err != nil {
+			writeErrMsg(w, 404, "not found")
+			return
+		}
+		writeJSON(w, 200, map[string]any{"ok": true})
+	default:
+		writeJSON(w, 405, nil)
+	}
+}
 func (h *Handler) upsertInstructor(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost && r.Method != http.MethodPut {
 		writeJSON(w, 405, nil)
@@ -166,7 +349,7 @@ func (h *Handler) upsertInstructor(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, 400, err)
 		return
 	}
-	if err := h.repo.UpsertInstructor(&m); err != nil {
+	if err := h.instructors.UpsertInstructor(&m); err != nil {
 		writeErr(w, 500, err)
 		return
 	}
@@ -182,7 +365,15 @@ func (h *Handler) upsertRoute(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, 400, err)
 		return
 	}
-	if err := h.repo.UpsertRoute(&m); err != nil {
+	if m.ShoreBearing != nil && (*m.ShoreBearing < 0 || *m.ShoreBearing > 359) {
+		writeErrMsg(w, 400, "shore_bearing must be between 0 and 359")
+		return
+	}
+	if err := h.routes.UpsertRoute(&m); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			writeErrMsg(w, 422, "station not found")
+			return
+		}
 		writeErr(w, 500, err)
 		return
 	}
@@ -198,31 +389,163 @@ func (h *Handler) bulkSlots(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, 400, err)
 		return
 	}
-	if err := h.repo.BulkCreateSlots(s); err != nil {
+	if p := principal(r); p.Role == models.RoleInstructor {
+		for i := range s {
+			if s[i].InstructorID == "" {
+				s[i].InstructorID = p.InstructorID
+			}
+			if s[i].InstructorID != p.InstructorID {
+				writeErrMsg(w, 403, "instructors may only create their own slots")
+				return
+			}
+		}
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	report, ok, err := h.slotService.Create(s, dryRun)
+	switch {
+	case errors.Is(err, repository.ErrSlotOverlap), errors.Is(err, repository.ErrCapacityBooked), errors.Is(err, repository.ErrSlotBooked):
+		writeErr(w, 409, err)
+	case err != nil:
 		writeErr(w, 500, err)
+	case dryRun:
+		writeJSON(w, 200, map[string]any{"dry_run": true, "valid": ok, "items": report})
+	case !ok:
+		writeJSON(w, 422, map[string]any{"error": "some slots are invalid, nothing was created", "items": report})
+	default:
+		writeJSON(w, 201, map[string]any{"created": len(report), "items": report})
+	}
+}
+
+// adminBookings lets instructors see the history of and set the status of
+// their own bookings only; cancelling, moving and money are left to staff.
+func (h *Handler) adminBookings(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/admin/bookings/")
+	if p := principal(r); p.Role == models.RoleInstructor {
+		id, action, _ := strings.Cut(rest, "/")
+		if action != "history" && action != "status" {
+			writeErrMsg(w, 403, "forbidden for role "+p.Role)
+			return
+		}
+		b, err := h.bookings.GetBooking(id)
+		if err != nil {
+			writeErr(w, statusErrCode(err), err)
+			return
+		}
+		if b.InstructorID != p.InstructorID {
+			writeErrMsg(w, 403, "booking belongs to another instructor")
+			return
+		}
+	}
+	if id, ok := strings.CutSuffix(rest, "/history"); ok {
+		h.bookingHistory(w, r, id)
+		return
+	}
+	if id, ok := strings.CutSuffix(rest, "/reschedule"); ok {
+		h.rescheduleBooking(w, r, id)
+		return
+	}
+	if id, ok := strings.CutSuffix(rest, "/payments"); ok {
+		h.listPayments(w, r, id)
 		return
 	}
-	writeJSON(w, 201, map[string]any{"created": len(s)})
+	if id, ok := strings.CutSuffix(rest, "/cancellation"); ok {
+		h.cancellationQuote(w, r, id, service.CancelByStaff)
+		return
+	}
+	h.patchBookingStatus(w, r, strings.TrimSuffix(rest, "/status"))
 }
-func (h *Handler) patchBookingStatus(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) patchBookingStatus(w http.ResponseWriter, r *http.Request, id string) {
 	if r.Method != http.MethodPatch {
 		writeJSON(w, 405, nil)
 		return
 	}
-	id := strings.TrimPrefix(r.URL.Path, "/api/admin/bookings/")
-	id = strings.TrimSuffix(id, "/status")
 	var req struct {
 		Status string `json:"status"`
+		Reason string `json:"reason"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Status == "" {
 		writeErrMsg(w, 400, "status required")
 		return
 	}
-	if err := h.repo.PatchBookingStatus(id, req.Status); err != nil {
+	if p := principal(r); req.Status == models.BookingCancelled && p.Role == models.RoleInstructor {
+		writeErrMsg(w, 403, "forbidden for role "+p.Role)
+		return
+	}
+	if req.Status == models.BookingCancelled {
+		b, q, err := h.refunds.Cancel(id, service.CancelByStaff, req.Reason)
+		if err != nil {
+			writeErr(w, statusErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"ok": true, "booking": b, "refund": q})
+		return
+	}
+	b, err := h.booking.ChangeStatus(id, req.Status, req.Reason)
+	if err != nil {
+		writeErr(w, statusErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"ok": true, "booking": b})
+}
+func (h *Handler) rescheduleBooking(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, nil)
+		return
+	}
+	var req struct {
+		SlotID string `json:"slot_id"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SlotID == "" {
+		writeErrMsg(w, 400, "slot_id required")
+		return
+	}
+	b, err := h.booking.Reschedule(id, req.SlotID, req.Reason)
+	if err != nil {
+		writeErr(w, statusErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"ok": true, "booking": b})
+}
+func (h *Handler) bookingHistory(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	items, err := h.bookings.ListBookingHistory(id)
+	if err != nil {
+		writeErr(w, statusErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, items)
+}
+func (h *Handler) consistencyReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	items, err := h.bookings.ListBookingMismatches()
+	if err != nil {
 		writeErr(w, 500, err)
 		return
 	}
-	writeJSON(w, 200, map[string]any{"ok": true})
+	writeJSON(w, 200, map[string]any{"ok": len(items) == 0, "mismatches": items, "checked_at": time.Now().UTC()})
+}
+
+func statusErrCode(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return 404
+	case errors.Is(err, repository.ErrUnknownStatus):
+		return 400
+	case errors.Is(err, service.ErrPromoRejected), errors.Is(err, service.ErrGiftRejected):
+		return 422
+	case errors.Is(err, repository.ErrInvalidTransition), errors.Is(err, repository.ErrSlotUnavailable), errors.Is(err, repository.ErrEquipmentUnavailable),
+		errors.Is(err, service.ErrUnsafeConditions):
+		return 409
+	default:
+		return 500
+	}
 }
 
 func writeErr(w http.ResponseWriter, code int, err error) {
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"slices"
+	"testing"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func TestPatchBookingStatus(t *testing.T) {
+	srv := newTestServer(t)
+	slot := srv.addSlot(t, 10, 9, 4)
+	b := srv.book(t, slot.ID, 1)
+	path := "/api/admin/bookings/" + b.ID + "/status"
+
+	steps := []struct {
+		status string
+		code   int
+	}{
+		{models.BookingCompleted, 409}, // pending → completed skips confirmation
+		{"paid", 400},
+		{models.BookingConfirmed, 200},
+		{models.BookingConfirmed, 200}, // no-op
+		{models.BookingCompleted, 200},
+		{models.BookingCancelled, 409},
+		{models.BookingPending, 409},
+	}
+	for _, s := range steps {
+		var got map[string]any
+		if code := srv.do(t, "PATCH", path, ownerKey, map[string]string{"status": s.status}, &got); code != s.code {
+			t.Fatalf("PATCH status %q = %d %v, want %d", s.status, code, got, s.code)
+		}
+	}
+
+	var history []models.BookingStatusChange
+	if code := srv.do(t, "GET", "/api/admin/bookings/"+b.ID+"/history", ownerKey, nil, &history); code != 200 {
+		t.Fatalf("GET history = %d", code)
+	}
+	var got []string
+	for _, c := range history {
+		got = append(got, c.To)
+	}
+	if want := []string{models.BookingPending, models.BookingConfirmed, models.BookingCompleted}; !slices.Equal(got, want) {
+		t.Errorf("history = %v, want %v: rejected and no-op changes must not be recorded", got, want)
+	}
+}
+
+func TestCreateBookingIgnoresServerFields(t *testing.T) {
+	srv := newTestServer(t)
+	slot := srv.addSlot(t, 10, 9, 4)
+	req := map[string]any{
+		"slot_id": slot.ID, "customer_name": "Анна", "phone": "+79990000000", "participants": 1,
+		"status": models.BookingConfirmed, "gift_amount": 5500, "refund_amount": 5500, "refund_rule": "100% forever", "gift_refund": 100,
+		"created_at": "2020-01-01T00:00:00Z",
+	}
+	var b models.Booking
+	if code := srv.do(t, "POST", "/api/bookings", "", req, &b); code != 201 {
+		t.Fatalf("create booking = %d", code)
+	}
+	stored, err := srv.repo.GetBooking(b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.BookingPending || stored.GiftAmount != 0 || stored.RefundAmount != 0 || stored.RefundRule != "" || stored.GiftRefund != 0 || stored.CreatedAt.Year() == 2020 {
+		t.Errorf("stored %+v: server-owned fields must not come from the client", stored)
+	}
+	var history []models.BookingStatusChange
+	srv.do(t, "GET", "/api/admin/bookings/"+b.ID+"/history", ownerKey, nil, &history)
+	if len(history) != 1 || history[0].To != models.BookingPending {
+		t.Errorf("history = %+v, want only the created entry", history)
+	}
+}
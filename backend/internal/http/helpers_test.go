@@ -0,0 +1,146 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/service"
+)
+
+const (
+	ownerKey      = "test-owner-key-0123456789"
+	webhookSecret = "test-webhook-secret"
+
+	// Seeded by repository.New.
+	seedInstructor = "11111111111111111111111111111111"
+	seedRoute      = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+)
+
+// calmWeather is a WeatherProvider with light wind every hour.
+type calmWeather struct{}
+
+func (calmWeather) Name() string { return "calm" }
+
+func (calmWeather) Forecast(_ context.Context, _, _ float64, from, to time.Time) (service.Forecast, error) {
+	var f service.Forecast
+	last := to.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	for t := from.UTC().Truncate(24 * time.Hour); t.Before(last); t = t.Add(time.Hour) {
+		f.Times = append(f.Times, t)
+		f.Points = append(f.Points, service.ForecastHour{Temperature: 24, WindSpeed: 2, WindGusts: 4, CloudCover: 10})
+	}
+	return f, nil
+}
+
+// testServer is the handler of cmd/server over a memory store, a calm
+// forecast and the fake payment provider, with ownerKey as the bootstrap
+// admin key.
+type testServer struct {
+	*httptest.Server
+	repo *repository.Memory
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	repo := repository.New()
+	weather := service.NewWeatherService(repo, repo, repo, []service.WeatherProvider{calmWeather{}}, "", time.Second, time.Second, 20*time.Minute, true, service.WeatherRules{service.DefaultDifficulty: service.DefaultScoringRules})
+	pricing := service.NewPricingService(repo, repo, repo, repo)
+	notifier, err := service.NewNotifier("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitlist := service.NewWaitlistService(repo, repo, notifier, 30*time.Minute)
+	promo := service.NewPromoService(repo, pricing)
+	gifts := service.NewGiftService(repo)
+	booking := service.NewBookingService(repo, pricing, promo, gifts, waitlist, weather, 10*time.Minute, 24*time.Hour)
+	offers := service.NewRescheduleOfferService(repo, weather, booking, nil, 72*time.Hour)
+	provider, err := service.NewPaymentProvider("fake", webhookSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payments := service.NewPaymentService(repo, booking, provider, "full", 30)
+	policy, err := service.ParseRefundPolicy("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(repo, weather, booking, waitlist, offers,
+		service.NewAuthService(repo, repo, ownerKey), service.NewInstructorService(repo, booking), service.NewScheduleService(repo, 14),
+		service.NewSlotService(repo), service.NewInventoryService(repo), service.NewOptionService(repo), promo, gifts, payments,
+		service.NewRefundService(repo, booking, payments, weather, policy))
+	mux := http.NewServeMux()
+	h.Register(mux)
+	srv := &testServer{Server: httptest.NewServer(mux), repo: repo}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// do sends body as JSON with key as the bearer token (none when empty) and
+// decodes the JSON answer into out when it is not nil.
+func (s *testServer) do(t *testing.T, method, path, key string, body, out any) int {
+	t.Helper()
+	var rd io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rd = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, s.URL+path, rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode %d answer: %v", method, path, resp.StatusCode, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// book creates a booking for n participants in slotID through the API.
+func (s *testServer) book(t *testing.T, slotID string, n int) models.Booking {
+	t.Helper()
+	var b models.Booking
+	req := models.Booking{SlotID: slotID, CustomerName: "Анна", Phone: "+79990000000", Participants: n}
+	if code := s.do(t, "POST", "/api/bookings", "", req, &b); code != 201 {
+		t.Fatalf("create booking = %d", code)
+	}
+	return b
+}
+
+// addSlot stores a 90-minute slot of the seeded instructor and route,
+// days ahead of today.
+func (s *testServer) addSlot(t *testing.T, days, hour, capacity int) models.TimeSlot {
+	t.Helper()
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, days).Add(time.Duration(hour) * time.Hour)
+	slot := models.TimeSlot{ID: newID(), InstructorID: seedInstructor, RouteID: seedRoute, StartAt: start, EndAt: start.Add(90 * time.Minute), Capacity: capacity, Remaining: capacity, Status: "open"}
+	if err := s.repo.BulkCreateSlots([]models.TimeSlot{slot}); err != nil {
+		t.Fatalf("add slot: %v", err)
+	}
+	return slot
+}
+
+var idSeq atomic.Int64
+
+func newID() string {
+	return fmt.Sprintf("%032x", idSeq.Add(1))
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/service"
+)
+
+func (h *Handler) instructorSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	if v := r.URL.Query().Get("from"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeErrMsg(w, 400, "invalid from")
+			return
+		}
+		from = d
+	}
+	to := from.AddDate(0, 0, 14)
+	if v := r.URL.Query().Get("to"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil || d.Before(from) {
+			writeErrMsg(w, 400, "invalid to")
+			return
+		}
+		to = d.AddDate(0, 0, 1)
+	}
+	items, err := h.instructor.Schedule(principal(r).InstructorID, from, to)
+	if err != nil {
+		writeErr(w, 500, err)
+		return
+	}
+	writeJSON(w, 200, items)
+}
+func (h *Handler) instructorSlot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, nil)
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, "/api/instructor/slots/")
+	id, action, _ := strings.Cut(rest, "/")
+	if action != "block" && action != "unblock" {
+		writeErrMsg(w, 404, "not found")
+		return
+	}
+	slot, err := h.instructor.SetSlotBlocked(principal(r).InstructorID, id, action == "block")
+	if err != nil {
+		writeErr(w, instructorErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, slot)
+}
+func (h *Handler) instructorAttendance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		writeJSON(w, 405, nil)
+		return
+	}
+	id, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/instructor/bookings/"), "/attendance")
+	if !ok {
+		writeErrMsg(w, 404, "not found")
+		return
+	}
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErr(w, 400, err)
+		return
+	}
+	b, err := h.instructor.MarkAttendance(principal(r).InstructorID, id, req.Status)
+	if err != nil {
+		writeErr(w, instructorErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, b)
+}
+
+func instructorErrCode(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return 404
+	case errors.Is(err, service.ErrInvalidAttendance):
+		return 400
+	case errors.Is(err, service.ErrForeignResource):
+		return 403
+	case errors.Is(err, service.ErrSlotNotStarted), errors.Is(err, repository.ErrInvalidTransition):
+		return 409
+	default:
+		return 500
+	}
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"testing"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/service"
+)
+
+func TestInstructorPortalIsScoped(t *testing.T) {
+	srv := newTestServer(t)
+	own, _ := srv.issueKey(t, models.RoleInstructor, seedInstructor)
+	other, _ := srv.issueKey(t, models.RoleInstructor, "22222222222222222222222222222222")
+	slot := srv.addSlot(t, 10, 9, 4)
+	b := srv.book(t, slot.ID, 2)
+	date := slot.StartAt.Format("2006-01-02")
+
+	schedule := func(key string) []service.ScheduleSlot {
+		t.Helper()
+		var items []service.ScheduleSlot
+		if code := srv.do(t, "GET", "/api/instructor/schedule?from="+date+"&to="+date, key, nil, &items); code != 200 {
+			t.Fatalf("GET schedule = %d", code)
+		}
+		return items
+	}
+	if items := schedule(own); len(items) != 1 || items[0].ID != slot.ID || items[0].Booked != 2 {
+		t.Errorf("own schedule = %+v, want slot %s with 2 booked", items, slot.ID)
+	}
+	if items := schedule(other); len(items) != 0 {
+		t.Errorf("another instructor's schedule = %+v, want empty", items)
+	}
+
+	tests := []struct {
+		method, path, key string
+		body              any
+		code              int
+	}{
+		{"POST", "/api/instructor/slots/" + slot.ID + "/block", other, nil, 403},
+		{"PATCH", "/api/instructor/bookings/" + b.ID + "/attendance", other, map[string]string{"status": models.BookingCompleted}, 403},
+		{"PATCH", "/api/instructor/bookings/" + b.ID + "/attendance", own, map[string]string{"status": models.BookingCancelled}, 400},
+		{"PATCH", "/api/instructor/bookings/" + b.ID + "/attendance", own, map[string]string{"status": models.BookingCompleted}, 409},
+		{"PATCH", "/api/admin/bookings/" + b.ID + "/status", other, map[string]string{"status": models.BookingConfirmed}, 403},
+		{"POST", "/api/admin/bookings/" + b.ID + "/reschedule", own, map[string]string{"slot_id": slot.ID}, 403},
+		{"PATCH", "/api/admin/bookings/" + b.ID, own, map[string]string{"status": models.BookingConfirmed}, 403},
+		{"PATCH", "/api/admin/bookings/" + b.ID + "/status", own, map[string]string{"status": models.BookingCancelled}, 403},
+		{"POST", "/api/admin/bookings/" + b.ID + "/cancellation", own, nil, 403},
+		{"GET", "/api/admin/bookings/" + b.ID + "/cancellation", own, nil, 403},
+		{"GET", "/api/admin/bookings/" + b.ID + "/payments", own, nil, 403},
+		{"GET", "/api/admin/bookings/" + b.ID + "/history", own, nil, 200},
+		{"PATCH", "/api/admin/bookings/" + b.ID + "/status", own, map[string]string{"status": models.BookingConfirmed}, 200},
+		{"POST", "/api/instructor/slots/" + slot.ID + "/block", own, nil, 200},
+	}
+	for _, tt := range tests {
+		if code := srv.do(t, tt.method, tt.path, tt.key, tt.body, nil); code != tt.code {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, code, tt.code)
+		}
+	}
+	if s, _ := srv.repo.GetSlot(slot.ID); s.Status != "blocked" {
+		t.Errorf("slot status = %q, want blocked", s.Status)
+	}
+}
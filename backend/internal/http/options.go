@@ -0,0 +1,81 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/service"
+)
+
+// listOptions is the public add-on catalog, optionally narrowed to a route.
+func (h *Handler) listOptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	items, err := h.catalog.ForRoute(r.URL.Query().Get("route_id"))
+	if err != nil {
+		writeErr(w, optionErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, items)
+}
+func (h *Handler) adminOptions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := h.options.ListOptions()
+		if err != nil {
+			writeErr(w, 500, err)
+			return
+		}
+		writeJSON(w, 200, items)
+	case http.MethodPost, http.MethodPut:
+		o := models.Option{IsActive: true}
+		if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+			writeErr(w, 400, err)
+			return
+		}
+		if err := h.catalog.Save(&o); err != nil {
+			writeErr(w, optionErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, o)
+	default:
+		writeJSON(w, 405, nil)
+	}
+}
+func (h *Handler) adminOption(w http.ResponseWriter, r *http.Request) {
+	code := strings.TrimPrefix(r.URL.Path, "/api/admin/options/")
+	switch r.Method {
+	case http.MethodGet:
+		o, err := h.options.GetOption(code)
+		if err != nil {
+			writeErr(w, optionErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, o)
+	case http.MethodDelete:
+		if err := h.options.DeleteOption(code); err != nil {
+			writeErr(w, optionErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"ok": true})
+	default:
+		writeJSON(w, 405, nil)
+	}
+}
+
+func optionErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidOption):
+		return 400
+	case errors.Is(err, repository.ErrNotFound):
+		return 404
+	default:
+		return 500
+	}
+}
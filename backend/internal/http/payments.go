@@ -0,0 +1,125 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/service"
+)
+
+// bookingPayments lets the customer start a payment (POST {"mode"}) and
+// follow its status (GET).
+func (h *Handler) bookingPayments(w http.ResponseWriter, r *http.Request, id string) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listPayments(w, r, id)
+	case http.MethodPost:
+		var req struct {
+			Mode string `json:"mode"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeErr(w, 400, err)
+			return
+		}
+		p, err := h.payments.Start(id, req.Mode)
+		if err != nil {
+			writeErr(w, paymentErrCode(err), err)
+			return
+		}
+		writeJSON(w, 201, p)
+	default:
+		writeJSON(w, 405, nil)
+	}
+}
+func (h *Handler) listPayments(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	b, err := h.bookings.GetBooking(id)
+	if err != nil {
+		writeErr(w, paymentErrCode(err), err)
+		return
+	}
+	items, err := h.payStore.ListBookingPayments(b.ID)
+	if err != nil {
+		writeErr(w, 500, err)
+		return
+	}
+	paid, err := h.payments.Paid(b.ID)
+	if err != nil {
+		writeErr(w, 500, err)
+		return
+	}
+	due := 0
+	if b.Status == models.BookingPending || b.Status == models.BookingConfirmed {
+		due = max(b.PriceTotal-b.GiftAmount-paid, 0)
+	}
+	writeJSON(w, 200, map[string]any{"items": items, "paid": paid, "due": due})
+}
+func (h *Handler) paymentWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, nil)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeErr(w, 400, err)
+		return
+	}
+	p, err := h.payments.HandleWebhook(body, r.Header)
+	if err != nil {
+		writeErr(w, paymentErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"ok": true, "payment_id": p.ID, "status": p.Status})
+}
+
+// refundPayment serves POST /api/admin/payments/{id}/refund with an
+// optional {"amount"}; without it the whole rest of the payment is refunded.
+func (h *Handler) refundPayment(w http.ResponseWriter, r *http.Request) {
+	id, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/admin/payments/"), "/refund")
+	if !ok {
+		writeErrMsg(w, 404, "not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, nil)
+		return
+	}
+	var req struct {
+		Amount int `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeErr(w, 400, err)
+		return
+	}
+	p, err := h.payments.Refund(id, req.Amount)
+	if err != nil {
+		writeErr(w, paymentErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, p)
+}
+
+func paymentErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidPayment):
+		return 400
+	case errors.Is(err, service.ErrWebhookSignature):
+		return 401
+	case errors.Is(err, repository.ErrNotFound):
+		return 404
+	case errors.Is(err, service.ErrNothingToPay), errors.Is(err, service.ErrNotPayable), errors.Is(err, repository.ErrPaymentClosed), errors.Is(err, repository.ErrRefundTooLarge):
+		return 409
+	case errors.Is(err, service.ErrNoPayments):
+		return 503
+	default:
+		return 500
+	}
+}
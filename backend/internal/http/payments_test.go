@@ -0,0 +1,56 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func TestPaymentWebhook(t *testing.T) {
+	srv := newTestServer(t)
+	slot := srv.addSlot(t, 10, 9, 4)
+	b := srv.book(t, slot.ID, 1)
+	var p models.Payment
+	if code := srv.do(t, "POST", "/api/bookings/"+b.ID+"/payments", "", map[string]string{"mode": models.PaymentFull}, &p); code != 201 {
+		t.Fatalf("start payment = %d", code)
+	}
+
+	post := func(body, signature string) int {
+		t.Helper()
+		req, _ := http.NewRequest("POST", srv.URL+"/api/payments/webhook", strings.NewReader(body))
+		req.Header.Set("X-Fake-Signature", signature)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, []byte(webhookSecret))
+		mac.Write([]byte(body))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	body := `{"ref":"` + p.ProviderRef + `","status":"succeeded"}`
+	if code := post(body, "bad"); code != 401 {
+		t.Errorf("unsigned webhook = %d, want 401", code)
+	}
+	if code := post(`{"ref":"fake_nope","status":"succeeded"}`, sign(`{"ref":"fake_nope","status":"succeeded"}`)); code != 404 {
+		t.Errorf("webhook for an unknown payment = %d, want 404", code)
+	}
+	if code := post(body, sign(body)); code != 200 {
+		t.Fatalf("signed webhook = %d, want 200", code)
+	}
+	var got struct {
+		Paid int `json:"paid"`
+		Due  int `json:"due"`
+	}
+	if code := srv.do(t, "GET", "/api/bookings/"+b.ID+"/payments", "", nil, &got); code != 200 || got.Paid != p.Amount || got.Due != 0 {
+		t.Errorf("payments = %d %+v, want %d paid and nothing due", code, got, p.Amount)
+	}
+}
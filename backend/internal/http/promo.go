@@ -0,0 +1,112 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/service"
+)
+
+type promoCheckRequest struct {
+	Code         string              `json:"code"`
+	SlotID       string              `json:"slot_id"`
+	Participants int                 `json:"participants"`
+	Options      map[string]any      `json:"options"`
+	OptionLines  []models.OptionLine `json:"option_lines"`
+}
+
+// validatePromo prices the checkout form with the code applied. A code that
+// does not apply is still a 200 with valid=false and the reason.
+func (h *Handler) validatePromo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, nil)
+		return
+	}
+	var req promoCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErr(w, 400, err)
+		return
+	}
+	if req.Code == "" || req.SlotID == "" || req.Participants < 1 {
+		writeErrMsg(w, 400, "code, slot_id and participants are required")
+		return
+	}
+	lines := req.OptionLines
+	if len(lines) == 0 {
+		var err error
+		if lines, err = service.LegacyOptions(req.Options); err != nil {
+			writeErr(w, 422, err)
+			return
+		}
+	}
+	quote, promo, err := h.promo.Check(req.Code, req.SlotID, req.Participants, lines)
+	switch {
+	case err == nil:
+		writeJSON(w, 200, map[string]any{"valid": true, "code": promo.Code, "discount": quote.Discount, "price_total": quote.Total, "price_breakdown": quote})
+	case errors.Is(err, service.ErrPromoRejected), errors.Is(err, repository.ErrPromoExhausted):
+		writeJSON(w, 200, map[string]any{"valid": false, "code": service.NormalizeCode(req.Code), "error": err.Error()})
+	default:
+		writeErr(w, promoErrCode(err), err)
+	}
+}
+func (h *Handler) adminPromoCodes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := h.promoStore.ListPromoCodes()
+		if err != nil {
+			writeErr(w, 500, err)
+			return
+		}
+		writeJSON(w, 200, items)
+	case http.MethodPost, http.MethodPut:
+		p := models.PromoCode{IsActive: true}
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeErr(w, 400, err)
+			return
+		}
+		if err := h.promo.Save(&p); err != nil {
+			writeErr(w, promoErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, p)
+	default:
+		writeJSON(w, 405, nil)
+	}
+}
+func (h *Handler) adminPromoCode(w http.ResponseWriter, r *http.Request) {
+	code := service.NormalizeCode(strings.TrimPrefix(r.URL.Path, "/api/admin/promo-codes/"))
+	switch r.Method {
+	case http.MethodGet:
+		p, err := h.promoStore.GetPromoCode(code)
+		if err != nil {
+			writeErr(w, promoErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, p)
+	case http.MethodDelete:
+		if err := h.promoStore.DeletePromoCode(code); err != nil {
+			writeErr(w, promoErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"ok": true})
+	default:
+		writeJSON(w, 405, nil)
+	}
+}
+
+func promoErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidPromo):
+		return 400
+	case errors.Is(err, service.ErrInvalidOption):
+		return 422
+	case errors.Is(err, repository.ErrNotFound):
+		return 404
+	default:
+		return 500
+	}
+}
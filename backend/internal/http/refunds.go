@@ -0,0 +1,60 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/service"
+)
+
+// cancellationQuote shows what cancelling now would refund, so the customer
+// can decide before POST /cancel.
+func (h *Handler) cancellationQuote(w http.ResponseWriter, r *http.Request, id, initiator string) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	q, err := h.refunds.Quote(id, initiator, time.Now())
+	if err != nil {
+		writeErr(w, refundErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, q)
+}
+func (h *Handler) cancelBooking(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, nil)
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeErr(w, 400, err)
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "cancelled by customer"
+	}
+	b, q, err := h.refunds.Cancel(id, service.CancelByCustomer, req.Reason)
+	if err != nil {
+		writeErr(w, refundErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"ok": true, "booking": b, "refund": q})
+}
+
+func refundErrCode(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return 404
+	case errors.Is(err, repository.ErrInvalidTransition):
+		return 409
+	default:
+		return 500
+	}
+}
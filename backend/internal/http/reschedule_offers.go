@@ -0,0 +1,86 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/service"
+)
+
+func (h *Handler) rescheduleOffer(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/reschedule-offers/")
+	if token, ok := strings.CutSuffix(rest, "/accept"); ok {
+		if r.Method != http.MethodPost {
+			writeJSON(w, 405, nil)
+			return
+		}
+		var req struct {
+			SlotID string `json:"slot_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SlotID == "" {
+			writeErrMsg(w, 400, "slot_id required")
+			return
+		}
+		o, b, err := h.offers.Accept(token, req.SlotID)
+		if err != nil {
+			writeErr(w, offerErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"offer": o, "booking": b})
+		return
+	}
+	if token, ok := strings.CutSuffix(rest, "/decline"); ok {
+		if r.Method != http.MethodPost {
+			writeJSON(w, 405, nil)
+			return
+		}
+		o, err := h.offers.Decline(token)
+		if err != nil {
+			writeErr(w, offerErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"offer": o})
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	o, slots, err := h.offers.Get(rest)
+	if err != nil {
+		writeErr(w, offerErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"offer": o, "slots": slots})
+}
+func (h *Handler) adminListRescheduleOffers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	items, err := h.offerStore.ListRescheduleOffers(r.URL.Query().Get("status"))
+	if err != nil {
+		writeErr(w, 500, err)
+		return
+	}
+	writeJSON(w, 200, items)
+}
+
+func offerErrCode(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return 404
+	case errors.Is(err, service.ErrSlotNotOffered):
+		return 400
+	case errors.Is(err, service.ErrPromoRejected), errors.Is(err, service.ErrGiftRejected):
+		return 422
+	case errors.Is(err, repository.ErrOfferClosed), errors.Is(err, service.ErrOfferExpired), errors.Is(err, repository.ErrSlotUnavailable), errors.Is(err, repository.ErrInvalidTransition), errors.Is(err, repository.ErrEquipmentUnavailable),
+		errors.Is(err, service.ErrUnsafeConditions):
+		return 409
+	default:
+		return 500
+	}
+}
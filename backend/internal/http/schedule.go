@@ -0,0 +1,113 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/service"
+)
+
+func (h *Handler) scheduleTemplates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := h.templates.ListTemplates()
+		if err != nil {
+			writeErr(w, 500, err)
+			return
+		}
+		writeJSON(w, 200, items)
+	case http.MethodPost, http.MethodPut:
+		t := models.ScheduleTemplate{IsActive: true}
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			writeErr(w, 400, err)
+			return
+		}
+		if err := h.schedule.Save(&t); err != nil {
+			writeErr(w, scheduleErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, t)
+	default:
+		writeJSON(w, 405, nil)
+	}
+}
+func (h *Handler) scheduleTemplate(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/admin/schedule-templates/")
+	switch {
+	case rest == "preview" && r.Method == http.MethodGet:
+		from, days, ok := scheduleRange(w, r, h.schedule.HorizonDays())
+		if !ok {
+			return
+		}
+		plan, err := h.schedule.Plan(from, days, r.URL.Query().Get("template_id"))
+		if err != nil {
+			writeErr(w, scheduleErrCode(err), err)
+			return
+		}
+		missing := 0
+		for _, p := range plan {
+			if !p.Exists {
+				missing++
+			}
+		}
+		writeJSON(w, 200, map[string]any{"would_create": missing, "slots": plan})
+	case rest == "generate" && r.Method == http.MethodPost:
+		from, days, ok := scheduleRange(w, r, h.schedule.HorizonDays())
+		if !ok {
+			return
+		}
+		created, err := h.schedule.Generate(from, days)
+		if err != nil {
+			writeErr(w, scheduleErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"created": len(created), "slots": created})
+	case r.Method == http.MethodGet:
+		t, err := h.templates.GetTemplate(rest)
+		if err != nil {
+			writeErr(w, scheduleErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, t)
+	case r.Method == http.MethodDelete:
+		if err := h.templates.DeleteTemplate(rest); err != nil {
+			writeErr(w, scheduleErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"ok": true})
+	default:
+		writeJSON(w, 405, nil)
+	}
+}
+
+// scheduleRange reads ?days= (default: the generator horizon) counted from
+// now.
+func scheduleRange(w http.ResponseWriter, r *http.Request, defaultDays int) (time.Time, int, bool) {
+	days := defaultDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 366 {
+			writeErrMsg(w, 400, "days must be between 1 and 366")
+			return time.Time{}, 0, false
+		}
+		days = n
+	}
+	return time.Now().UTC(), days, true
+}
+
+func scheduleErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidTemplate):
+		return 400
+	case errors.Is(err, repository.ErrNotFound):
+		return 404
+	default:
+		return 500
+	}
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/service"
+)
+
+func (h *Handler) adminStations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := h.stations.ListStations()
+		if err != nil {
+			writeErr(w, 500, err)
+			return
+		}
+		writeJSON(w, 200, items)
+	case http.MethodPost, http.MethodPut:
+		var st models.Station
+		if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+			writeErr(w, 400, err)
+			return
+		}
+		if err := h.inventory.Save(&st); err != nil {
+			writeErr(w, stationErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, st)
+	default:
+		writeJSON(w, 405, nil)
+	}
+}
+
+// adminStation shows the stock of one station and what is left of it for
+// [from, to) (default: the hour starting now).
+func (h *Handler) adminStation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	from, to := time.Now().UTC(), time.Time{}
+	for name, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := r.URL.Query().Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeErrMsg(w, 400, name+" must be RFC3339")
+				return
+			}
+			*dst = t
+		}
+	}
+	if to.IsZero() {
+		to = from.Add(time.Hour)
+	}
+	st, free, err := h.inventory.Free(strings.TrimPrefix(r.URL.Path, "/api/admin/stations/"), from, to)
+	if err != nil {
+		writeErr(w, stationErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"station": st, "from": from, "to": to, "free": free})
+}
+
+func stationErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidStation):
+		return 400
+	case errors.Is(err, repository.ErrNotFound):
+		return 404
+	default:
+		return 500
+	}
+}
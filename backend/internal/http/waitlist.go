@@ -0,0 +1,103 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/service"
+)
+
+func (h *Handler) joinWaitlist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, nil)
+		return
+	}
+	var req models.WaitlistEntry
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErr(w, 400, err)
+		return
+	}
+	if req.CustomerName == "" || req.Phone == "" || req.Participants < 1 || req.SlotID == "" {
+		writeErrMsg(w, 400, "missing required fields")
+		return
+	}
+	if err := h.waitlist.Join(&req); err != nil {
+		writeErr(w, waitlistErrCode(err), err)
+		return
+	}
+	writeJSON(w, 201, req)
+}
+func (h *Handler) waitlistEntry(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/waitlist/")
+	switch r.Method {
+	case http.MethodGet:
+		e, err := h.queue.GetWaitlistEntry(id)
+		if err != nil {
+			writeErrMsg(w, 404, "not found")
+			return
+		}
+		writeJSON(w, 200, e)
+	case http.MethodDelete:
+		e, err := h.waitlist.Cancel(id)
+		if err != nil {
+			writeErr(w, waitlistErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, e)
+	default:
+		writeJSON(w, 405, nil)
+	}
+}
+func (h *Handler) adminListWaitlist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	items, err := h.queue.ListWaitlist(r.URL.Query().Get("slot_id"))
+	if err != nil {
+		writeErr(w, waitlistErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, items)
+}
+func (h *Handler) adminWaitlistEntry(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/admin/waitlist/")
+	if id, ok := strings.CutSuffix(rest, "/offer"); ok {
+		if r.Method != http.MethodPost {
+			writeJSON(w, 405, nil)
+			return
+		}
+		e, err := h.waitlist.Offer(id)
+		if err != nil {
+			writeErr(w, waitlistErrCode(err), err)
+			return
+		}
+		writeJSON(w, 200, e)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeJSON(w, 405, nil)
+		return
+	}
+	e, err := h.waitlist.Cancel(rest)
+	if err != nil {
+		writeErr(w, waitlistErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, e)
+}
+
+func waitlistErrCode(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return 404
+	case errors.Is(err, service.ErrSeatsAvailable), errors.Is(err, repository.ErrWaitlistClosed), errors.Is(err, repository.ErrSlotUnavailable):
+		return 409
+	default:
+		return 500
+	}
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"sup-anapa/backend/internal/service"
+)
+
+// adminWeatherRules shows the scoring rules loaded at startup.
+func (h *Handler) adminWeatherRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, nil)
+		return
+	}
+	writeJSON(w, 200, h.weather.Rules())
+}
+
+// previewWeatherScore scores sample conditions with the rules of a
+// difficulty. An optional "rules" object in the WEATHER_RULES format is
+// validated and used instead of the loaded rules, so a rule change can be
+// checked before it reaches customers.
+func (h *Handler) previewWeatherScore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, nil)
+		return
+	}
+	var req struct {
+		Difficulty   string                  `json:"difficulty"`
+		ShoreBearing *int                    `json:"shore_bearing"`
+		Samples      []service.WeatherSample `json:"samples"`
+		Rules        json.RawMessage         `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErr(w, 400, err)
+		return
+	}
+	var candidate service.WeatherRules
+	if len(req.Rules) > 0 && string(req.Rules) != "null" {
+		var err error
+		if candidate, err = service.ParseWeatherRules(req.Rules); err != nil {
+			writeErr(w, 400, err)
+			return
+		}
+	}
+	results, rules, err := h.weather.Preview(req.Difficulty, req.ShoreBearing, req.Samples, candidate)
+	if err != nil {
+		writeErr(w, weatherRulesErrCode(err), err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"difficulty": req.Difficulty, "rules": rules, "results": results})
+}
+
+func weatherRulesErrCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidPreview):
+		return 400
+	default:
+		return 500
+	}
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"testing"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/service"
+)
+
+func TestPreviewWeatherScore(t *testing.T) {
+	srv := newTestServer(t)
+	instructor, _ := srv.issueKey(t, models.RoleInstructor, seedInstructor)
+	req := map[string]any{"difficulty": "hard", "samples": []service.WeatherSample{{Temperature: 24, WindSpeed: 3, WindGusts: 15}}}
+
+	var got struct {
+		Rules   string                 `json:"rules"`
+		Results []service.ScorePreview `json:"results"`
+	}
+	if code := srv.do(t, "POST", "/api/admin/weather/preview", ownerKey, req, &got); code != 200 {
+		t.Fatalf("preview = %d", code)
+	}
+	if got.Rules != service.DefaultDifficulty || len(got.Results) != 1 || !got.Results[0].Unsafe || got.Results[0].ConditionsLevel != "Плохие" {
+		t.Errorf("preview = %+v, want the default rules rating 15 m/s gusts unsafe", got)
+	}
+	req["rules"] = map[string]any{"hard": map[string]any{"gusts_unsafe": 16}}
+	if code := srv.do(t, "POST", "/api/admin/weather/preview", ownerKey, req, &got); code != 200 {
+		t.Fatalf("preview with rules = %d", code)
+	}
+	if got.Rules != "hard" || got.Results[0].Unsafe {
+		t.Errorf("preview with rules = %+v, want the posted hard rules rating 15 m/s gusts safe", got)
+	}
+	req["rules"] = map[string]any{"hard": map[string]any{"gusts_unsafe": "high"}}
+	if code := srv.do(t, "POST", "/api/admin/weather/preview", ownerKey, req, nil); code != 400 {
+		t.Errorf("preview with invalid rules = %d, want 400", code)
+	}
+	if code := srv.do(t, "POST", "/api/admin/weather/preview", ownerKey, map[string]any{"samples": []any{}}, nil); code != 400 {
+		t.Errorf("preview without samples = %d, want 400", code)
+	}
+	if code := srv.do(t, "GET", "/api/admin/weather/rules", instructor, nil, nil); code != 403 {
+		t.Errorf("rules for an instructor key = %d, want 403", code)
+	}
+}
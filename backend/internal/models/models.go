@@ -18,6 +18,9 @@ type Instructor struct {
 	UpdatedAt       time.Time `json:"updated_at"`
 }
 
+// Route is a SUP trip. ShoreBearing is the compass bearing from the start
+// out to open water, in degrees; wind blowing that way is offshore. It is
+// unset on rivers.
 type Route struct {
 	ID              string    `json:"id"`
 	Title           string    `json:"title"`
@@ -28,10 +31,41 @@ type Route struct {
 	LocationLat     float64   `json:"location_lat"`
 	LocationLng     float64   `json:"location_lng"`
 	LocationTitle   string    `json:"location_title"`
+	StationID       string    `json:"station_id,omitempty"`
+	ShoreBearing    *int      `json:"shore_bearing,omitempty"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
 
+const (
+	EquipmentBoard  = "board"
+	EquipmentPaddle = "paddle"
+	EquipmentVest   = "vest"
+	EquipmentDrybag = "drybag"
+)
+
+// Station is a rental point shared by every instructor starting there.
+// Stock counts pieces per equipment kind; kinds it does not list are not
+// tracked.
+type Station struct {
+	ID        string         `json:"id"`
+	Title     string         `json:"title"`
+	Stock     map[string]int `json:"stock"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// EquipmentReservation is station equipment held by a booking for the
+// time of its slot.
+type EquipmentReservation struct {
+	BookingID string    `json:"booking_id"`
+	StationID string    `json:"station_id"`
+	Kind      string    `json:"kind"`
+	Quantity  int       `json:"quantity"`
+	StartAt   time.Time `json:"start_at"`
+	EndAt     time.Time `json:"end_at"`
+}
+
 type TimeSlot struct {
 	ID           string    `json:"id"`
 	InstructorID string    `json:"instructor_id"`
@@ -45,20 +79,302 @@ type TimeSlot struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+const (
+	BookingPending   = "pending"
+	BookingConfirmed = "confirmed"
+	BookingCompleted = "completed"
+	BookingCancelled = "cancelled"
+	BookingNoShow    = "no_show"
+)
+
+// ScheduleTemplate describes recurring slots of one instructor on one route:
+// every listed weekday (1 = Monday … 7 = Sunday) at each local time, within
+// the optional [ValidFrom, ValidTo] date range.
+type ScheduleTemplate struct {
+	ID           string    `json:"id"`
+	InstructorID string    `json:"instructor_id"`
+	RouteID      string    `json:"route_id"`
+	Weekdays     []int     `json:"weekdays"`
+	Times        []string  `json:"times"`
+	Capacity     int       `json:"capacity"`
+	ValidFrom    string    `json:"valid_from,omitempty"`
+	ValidTo      string    `json:"valid_to,omitempty"`
+	Timezone     string    `json:"timezone"`
+	IsActive     bool      `json:"is_active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Refund is what a cancellation gives back under Rule: Amount roubles of
+// the money paid and GiftPercent of the gift certificate amount.
+type Refund struct {
+	Amount      int
+	GiftPercent int
+	Rule        string
+}
+
 type Booking struct {
-	ID           string         `json:"id"`
-	InstructorID string         `json:"instructor_id"`
-	RouteID      string         `json:"route_id"`
-	SlotID       string         `json:"slot_id"`
-	CustomerName string         `json:"customer_name"`
-	Phone        string         `json:"phone"`
-	Messenger    string         `json:"messenger"`
-	Participants int            `json:"participants"`
-	Options      map[string]any `json:"options"`
-	PriceTotal   int            `json:"price_total"`
-	Status       string         `json:"status"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
+	ID           string          `json:"id"`
+	InstructorID string          `json:"instructor_id"`
+	RouteID      string          `json:"route_id"`
+	SlotID       string          `json:"slot_id"`
+	CustomerName string          `json:"customer_name"`
+	Phone        string          `json:"phone"`
+	Messenger    string          `json:"messenger"`
+	Participants int             `json:"participants"`
+	Options      map[string]any  `json:"options"`
+	OptionLines  []OptionLine    `json:"option_lines"`
+	PromoCode    string          `json:"promo_code,omitempty"`
+	GiftCode     string          `json:"gift_code,omitempty"`
+	GiftAmount   int             `json:"gift_amount,omitempty"`
+	RefundAmount int             `json:"refund_amount,omitempty"`
+	RefundRule   string          `json:"refund_rule,omitempty"`
+	GiftRefund   int             `json:"gift_refund,omitempty"`
+	PriceTotal   int             `json:"price_total"`
+	Price        *PriceBreakdown `json:"price_breakdown,omitempty"`
+	HoldToken    string          `json:"hold_token,omitempty"`
+	Status       string          `json:"status"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+const (
+	OptionPerPerson  = "per_person"
+	OptionPerBooking = "per_booking"
+)
+
+// Option is an add-on from the catalog. Equipment names the station stock
+// it is limited by ("" means unlimited); an empty RouteIDs offers it on
+// every route.
+type Option struct {
+	Code      string    `json:"code"`
+	Title     string    `json:"title"`
+	Price     int       `json:"price"`
+	PriceType string    `json:"price_type"`
+	Equipment string    `json:"equipment,omitempty"`
+	RouteIDs  []string  `json:"route_ids"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OptionLine is an add-on on a booking, priced from the catalog when the
+// booking was made. Booking.Options is the legacy {"code": true} form of
+// the same lines.
+type OptionLine struct {
+	Code      string `json:"code"`
+	Title     string `json:"title"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int    `json:"unit_price"`
+	Amount    int    `json:"amount"`
+	PriceType string `json:"price_type,omitempty"`
+	Equipment string `json:"equipment,omitempty"`
+}
+
+const (
+	PromoPercent = "percent"
+	PromoFixed   = "fixed"
+)
+
+// PromoCode is a discount customers enter at checkout. Value is a percentage
+// for percent codes and roubles for fixed ones. Zero MaxUses means
+// unlimited; empty RouteIDs / InstructorIDs mean any route / instructor.
+type PromoCode struct {
+	Code            string     `json:"code"`
+	Kind            string     `json:"kind"`
+	Value           int        `json:"value"`
+	ValidFrom       *time.Time `json:"valid_from,omitempty"`
+	ValidTo         *time.Time `json:"valid_to,omitempty"`
+	MaxUses         int        `json:"max_uses"`
+	Used            int        `json:"used"`
+	MinParticipants int        `json:"min_participants"`
+	RouteIDs        []string   `json:"route_ids"`
+	InstructorIDs   []string   `json:"instructor_ids"`
+	IsActive        bool       `json:"is_active"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+const (
+	GiftValue = "value"
+	GiftRoute = "route"
+
+	GiftActive   = "active"
+	GiftRedeemed = "redeemed"
+	GiftVoided   = "voided"
+)
+
+// GiftCertificate is prepaid credit. A value certificate holds Balance
+// roubles spendable over several bookings; a route certificate pays the
+// instructor and route price for up to Participants people on RouteID once.
+type GiftCertificate struct {
+	Code         string    `json:"code"`
+	Kind         string    `json:"kind"`
+	Amount       int       `json:"amount"`
+	Balance      int       `json:"balance"`
+	RouteID      string    `json:"route_id,omitempty"`
+	Participants int       `json:"participants,omitempty"`
+	Recipient    string    `json:"recipient,omitempty"`
+	Note         string    `json:"note,omitempty"`
+	Status       string    `json:"status"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// GiftRedemption is the part of a booking paid with a certificate.
+type GiftRedemption struct {
+	BookingID string    `json:"booking_id"`
+	Code      string    `json:"code"`
+	Amount    int       `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	PaymentFull    = "full"
+	PaymentDeposit = "deposit"
+
+	PaymentPending   = "pending"
+	PaymentSucceeded = "succeeded"
+	PaymentFailed    = "failed"
+	PaymentRefunded  = "refunded"
+)
+
+// Payment is one charge of a booking through the payment provider.
+// ProviderRef is the provider's id of the payment intent; Refunded counts
+// the roubles returned so far, and the status becomes refunded once it
+// reaches Amount.
+type Payment struct {
+	ID              string    `json:"id"`
+	BookingID       string    `json:"booking_id"`
+	Provider        string    `json:"provider"`
+	ProviderRef     string    `json:"provider_ref"`
+	Mode            string    `json:"mode"`
+	Amount          int       `json:"amount"`
+	Refunded        int       `json:"refunded"`
+	Status          string    `json:"status"`
+	ConfirmationURL string    `json:"confirmation_url,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type SeatHold struct {
+	Token     string    `json:"token"`
+	SlotID    string    `json:"slot_id"`
+	Seats     int       `json:"seats"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IdempotencyKey remembers which booking a client-supplied Idempotency-Key
+// produced. BookingID is empty while the first request is still running.
+type IdempotencyKey struct {
+	Key         string    `json:"key"`
+	RequestHash string    `json:"request_hash"`
+	BookingID   string    `json:"booking_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+const (
+	WaitlistWaiting   = "waiting"
+	WaitlistOffered   = "offered"
+	WaitlistAccepted  = "accepted"
+	WaitlistExpired   = "expired"
+	WaitlistCancelled = "cancelled"
+)
+
+// WaitlistEntry is a customer queued for a full slot. When seats free up
+// the entry is offered a SeatHold; booking with its token accepts the offer.
+type WaitlistEntry struct {
+	ID             string     `json:"id"`
+	SlotID         string     `json:"slot_id"`
+	CustomerName   string     `json:"customer_name"`
+	Phone          string     `json:"phone"`
+	Messenger      string     `json:"messenger"`
+	Participants   int        `json:"participants"`
+	Status         string     `json:"status"`
+	HoldToken      string     `json:"hold_token,omitempty"`
+	OfferExpiresAt *time.Time `json:"offer_expires_at,omitempty"`
+	BookingID      string     `json:"booking_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+const (
+	OfferPending  = "pending"
+	OfferAccepted = "accepted"
+	OfferDeclined = "declined"
+)
+
+// RescheduleOffer proposes other slots to a booking whose slot looks bad
+// weather-wise. The customer picks one through the link carrying Token.
+type RescheduleOffer struct {
+	ID              string    `json:"id"`
+	BookingID       string    `json:"booking_id"`
+	Token           string    `json:"token"`
+	SlotIDs         []string  `json:"slot_ids"`
+	ConditionsLevel string    `json:"conditions_level"`
+	Score           int       `json:"score"`
+	Explanation     string    `json:"explanation"`
+	Status          string    `json:"status"`
+	ChosenSlotID    string    `json:"chosen_slot_id,omitempty"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+const (
+	RoleOwner      = "owner"
+	RoleDispatcher = "dispatcher"
+	RoleInstructor = "instructor"
+)
+
+// APIKey authenticates /api/admin requests. Only a SHA-256 of the key is
+// stored; instructor keys are bound to one instructor.
+type APIKey struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Role         string     `json:"role"`
+	InstructorID string     `json:"instructor_id,omitempty"`
+	KeyHash      string     `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+type BookingStatusChange struct {
+	ID         string    `json:"id"`
+	BookingID  string    `json:"booking_id"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	FromSlotID string    `json:"from_slot_id,omitempty"`
+	ToSlotID   string    `json:"to_slot_id,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type PriceLine struct {
+	Code   string `json:"code"`
+	Title  string `json:"title"`
+	Amount int    `json:"amount"`
+}
+
+type PriceBreakdown struct {
+	Participants int         `json:"participants"`
+	PerPerson    int         `json:"per_person"`
+	Lines        []PriceLine `json:"lines"`
+	Discount     int         `json:"discount,omitempty"`
+	Total        int         `json:"total"`
+}
+
+// BookingMismatch is one row of the admin consistency report: a booking
+// field that disagrees with the slot it references.
+type BookingMismatch struct {
+	BookingID    string `json:"booking_id"`
+	SlotID       string `json:"slot_id"`
+	Field        string `json:"field"`
+	BookingValue string `json:"booking_value"`
+	SlotValue    string `json:"slot_value"`
 }
 
 type WeatherSnapshot struct {
@@ -69,8 +385,14 @@ type WeatherSnapshot struct {
 	TimeTo          time.Time      `json:"time_to"`
 	Temperature     float64        `json:"temperature"`
 	WindSpeed       float64        `json:"wind_speed"`
+	WindGusts       float64        `json:"wind_gusts"`
+	WindDirection   int            `json:"wind_direction"`
 	Precipitation   float64        `json:"precipitation"`
 	CloudCover      int            `json:"cloud_cover"`
+	WaveHeight      *float64       `json:"wave_height,omitempty"`
+	WavePeriod      *float64       `json:"wave_period,omitempty"`
+	SeaTemperature  *float64       `json:"sea_temperature,omitempty"`
+	Provider        string         `json:"provider"`
 	ConditionsLevel string         `json:"conditions_level"`
 	Score           int            `json:"score"`
 	Raw             map[string]any `json:"raw"`
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"sup-anapa/backend/internal/models"
+)
+
+var ErrEquipmentUnavailable = errors.New("not enough equipment at the station")
+
+// seatKinds are the kinds every participant needs, so their free stock caps
+// the seats a slot can still sell.
+var seatKinds = []string{models.EquipmentBoard, models.EquipmentPaddle}
+
+// equipmentNeeds is what a booking takes from its station: a board and a
+// paddle per participant, plus the stock-limited options it includes.
+func equipmentNeeds(b models.Booking) map[string]int {
+	needs := map[string]int{}
+	for _, kind := range seatKinds {
+		needs[kind] = b.Participants
+	}
+	for _, line := range b.OptionLines {
+		if line.Equipment != "" {
+			needs[line.Equipment] += line.Quantity
+		}
+	}
+	return needs
+}
+
+// planReservations checks the booking's needs against the station stock not
+// yet reserved for the slot time and returns the reservations to store.
+func planReservations(b models.Booking, s models.TimeSlot, st models.Station, reserved map[string]int) ([]models.EquipmentReservation, error) {
+	needs := equipmentNeeds(b)
+	kinds := make([]string, 0, len(needs))
+	for kind := range needs {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	out := []models.EquipmentReservation{}
+	for _, kind := range kinds {
+		stock, tracked := st.Stock[kind]
+		if !tracked || needs[kind] == 0 {
+			continue
+		}
+		if free := stock - reserved[kind]; needs[kind] > free {
+			return nil, fmt.Errorf("%w: %s has %d %s free, %d needed", ErrEquipmentUnavailable, st.Title, max(free, 0), kind, needs[kind])
+		}
+		out = append(out, models.EquipmentReservation{BookingID: b.ID, StationID: st.ID, Kind: kind, Quantity: needs[kind], StartAt: s.StartAt, EndAt: s.EndAt})
+	}
+	return out, nil
+}
+
+// freeStock is the station stock minus the reserved pieces.
+func freeStock(st models.Station, reserved map[string]int) map[string]int {
+	out := map[string]int{}
+	for kind, n := range st.Stock {
+		out[kind] = max(n-reserved[kind], 0)
+	}
+	return out
+}
+
+// effectiveRemaining caps the slot's remaining seats by the free boards and
+// paddles of its station.
+func effectiveRemaining(remaining int, free map[string]int) int {
+	for _, kind := range seatKinds {
+		if n, tracked := free[kind]; tracked && n < remaining {
+			remaining = n
+		}
+	}
+	return remaining
+}
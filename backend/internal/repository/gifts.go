@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"crypto/rand"
+	"errors"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+var (
+	ErrGiftClosed  = errors.New("gift certificate is not active")
+	ErrGiftBalance = errors.New("gift certificate balance is too low")
+)
+
+// giftAlphabet leaves out characters that are easy to misread on a printed
+// certificate (0/O, 1/I).
+const giftAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// giftCode returns a code like GIFT-7KQ2-M9XD.
+func giftCode() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	out := []byte("GIFT-")
+	for i, c := range b {
+		if i == 4 {
+			out = append(out, '-')
+		}
+		out = append(out, giftAlphabet[int(c)%len(giftAlphabet)])
+	}
+	return string(out)
+}
+
+// spendGift takes amount off the certificate. A route certificate is used
+// up by a single booking whatever the amount.
+func spendGift(g *models.GiftCertificate, amount int, now time.Time) error {
+	if g.Status != models.GiftActive || !now.Before(g.ExpiresAt) {
+		return ErrGiftClosed
+	}
+	if g.Kind == models.GiftRoute {
+		g.Status = models.GiftRedeemed
+		return nil
+	}
+	if amount > g.Balance {
+		return ErrGiftBalance
+	}
+	g.Balance -= amount
+	if g.Balance == 0 {
+		g.Status = models.GiftRedeemed
+	}
+	return nil
+}
+
+// returnGiftPart gives back part of a booking's payment that a re-price
+// made unnecessary. A route certificate stays used; voided certificates
+// stay voided.
+func returnGiftPart(g *models.GiftCertificate, amount int) {
+	if g.Kind == models.GiftRoute || g.Status == models.GiftVoided || amount <= 0 {
+		return
+	}
+	g.Balance += amount
+	g.Status = models.GiftActive
+}
+
+// refundGift gives percent of a cancelled booking's amount back and returns
+// what it restored. A route certificate comes back only on a full refund;
+// voided certificates stay voided.
+func refundGift(g *models.GiftCertificate, amount, percent int) int {
+	if g.Status == models.GiftVoided {
+		return 0
+	}
+	if g.Kind == models.GiftRoute {
+		if percent < 100 {
+			return 0
+		}
+		g.Status = models.GiftActive
+		return amount
+	}
+	back := amount * percent / 100
+	if back > 0 {
+		g.Balance += back
+		g.Status = models.GiftActive
+	}
+	return back
+}
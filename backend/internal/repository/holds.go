@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+var (
+	ErrHoldNotFound = errors.New("hold not found")
+	ErrHoldExpired  = errors.New("hold expired")
+	ErrHoldMismatch = errors.New("hold does not cover booking")
+)
+
+// applyHold checks that the hold covers the booking and returns any seats
+// the booking does not need back to the slot.
+func applyHold(h models.SeatHold, b *models.Booking, s *models.TimeSlot, now time.Time) error {
+	if !sameID(h.SlotID, s.ID) {
+		return fmt.Errorf("%w: hold is for slot %s", ErrHoldMismatch, h.SlotID)
+	}
+	if !now.Before(h.ExpiresAt) {
+		return ErrHoldExpired
+	}
+	if b.Participants > h.Seats {
+		return fmt.Errorf("%w: hold covers %d seats, booking needs %d", ErrHoldMismatch, h.Seats, b.Participants)
+	}
+	if extra := h.Seats - b.Participants; extra > 0 {
+		releaseSeats(s, extra)
+	}
+	return nil
+}
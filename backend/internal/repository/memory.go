@@ -0,0 +1,691 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+// Memory keeps everything in maps; it is used when DATABASE_URL is empty and in tests.
+type Memory struct {
+	mu       sync.RWMutex
+	inst     map[string]models.Instructor
+	routes   map[string]models.Route
+	slots    map[string]models.TimeSlot
+	bookings map[string]models.Booking
+	history  map[string][]models.BookingStatusChange
+	holds    map[string]models.SeatHold
+	idem     map[string]models.IdempotencyKey
+	waitlist map[string]models.WaitlistEntry
+	offers   map[string]models.RescheduleOffer
+	apiKeys  map[string]models.APIKey
+	tpl      map[string]models.ScheduleTemplate
+	stations map[string]models.Station
+	reserved map[string][]models.EquipmentReservation
+	options  map[string]models.Option
+	promos   map[string]models.PromoCode
+	gifts    map[string]models.GiftCertificate
+	redeemed map[string]models.GiftRedemption
+	payments map[string]models.Payment
+	weather  []models.WeatherSnapshot
+}
+
+func New() *Memory {
+	r := &Memory{
+		inst:     map[string]models.Instructor{},
+		routes:   map[string]models.Route{},
+		slots:    map[string]models.TimeSlot{},
+		bookings: map[string]models.Booking{},
+		history:  map[string][]models.BookingStatusChange{},
+		holds:    map[string]models.SeatHold{},
+		idem:     map[string]models.IdempotencyKey{},
+		waitlist: map[string]models.WaitlistEntry{},
+		offers:   map[string]models.RescheduleOffer{},
+		apiKeys:  map[string]models.APIKey{},
+		tpl:      map[string]models.ScheduleTemplate{},
+		stations: map[string]models.Station{},
+		reserved: map[string][]models.EquipmentReservation{},
+		options:  map[string]models.Option{},
+		promos:   map[string]models.PromoCode{},
+		gifts:    map[string]models.GiftCertificate{},
+		redeemed: map[string]models.GiftRedemption{},
+		payments: map[string]models.Payment{},
+		weather:  []models.WeatherSnapshot{},
+	}
+	r.seed()
+	return r
+}
+
+func (r *Memory) seed() {
+	now := time.Now().UTC()
+	i1 := models.Instructor{ID: "11111111111111111111111111111111", Name: "Алексей Морев", PhotoURL: "https://images.unsplash.com/photo-1500648767791-00dcc994a43e", Bio: "Спокойные прогулки для новичков и семей.", Rating: 4.9, ReviewsCount: 132, ExperienceYears: 7, Tags: []string{"новички", "дети", "закат"}, Languages: []string{"RU", "EN"}, BasePrice: 3000, IsActive: true, CreatedAt: now, UpdatedAt: now}
+	i2 := models.Instructor{ID: "22222222222222222222222222222222", Name: "Мария Волна", PhotoURL: "https://images.unsplash.com/photo-1494790108377-be9c29b29330", Bio: "Тренировки и SUP-фитнес на реке.", Rating: 4.8, ReviewsCount: 96, ExperienceYears: 5, Tags: []string{"спорт", "новички"}, Languages: []string{"RU"}, BasePrice: 3200, IsActive: true, CreatedAt: now, UpdatedAt: now}
+	r.inst[i1.ID] = i1
+	r.inst[i2.ID] = i2
+	st := models.Station{ID: "cccccccccccccccccccccccccccccccc", Title: "Станция на реке у Анапы", Stock: map[string]int{models.EquipmentBoard: 10, models.EquipmentPaddle: 10, models.EquipmentVest: 12, models.EquipmentDrybag: 6}, CreatedAt: now, UpdatedAt: now}
+	r.stations[st.ID] = st
+	for _, o := range []models.Option{
+		{Code: "photo", Title: "Фото/видео", Price: 700, PriceType: models.OptionPerPerson},
+		{Code: "drybag", Title: "Гидромешок", Price: 200, PriceType: models.OptionPerPerson, Equipment: models.EquipmentDrybag},
+		{Code: "vest", Title: "Спасательный жилет", Price: 0, PriceType: models.OptionPerPerson, Equipment: models.EquipmentVest},
+	} {
+		o.RouteIDs, o.IsActive, o.CreatedAt, o.UpdatedAt = []string{}, true, now, now
+		r.options[o.Code] = o
+	}
+	r1 := models.Route{ID: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", Title: "Река у Анапы — спокойная вода", DurationMinutes: 90, Difficulty: "easy", BasePrice: 2500, Description: "Идеально для первого SUP", LocationLat: 45.092, LocationLng: 37.268, LocationTitle: "Старт: река у Анапы", StationID: st.ID, CreatedAt: now, UpdatedAt: now}
+	r.routes[r1.ID] = r1
+	for d := 0; d < 7; d++ {
+		s := models.TimeSlot{ID: id(), InstructorID: i1.ID, RouteID: r1.ID, StartAt: time.Date(now.Year(), now.Month(), now.Day()+d, 9, 0, 0, 0, time.UTC), EndAt: time.Date(now.Year(), now.Month(), now.Day()+d, 10, 30, 0, 0, time.UTC), Capacity: 6, Remaining: 6, Status: "open", CreatedAt: now, UpdatedAt: now}
+		r.slots[s.ID] = s
+	}
+}
+
+func (r *Memory) ListInstructors(minPrice, maxPrice int, minRating float64, tag string) ([]models.Instructor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []models.Instructor{}
+	for _, i := range r.inst {
+		if !i.IsActive {
+			continue
+		}
+		if minPrice > 0 && i.BasePrice < minPrice {
+			continue
+		}
+		if maxPrice > 0 && i.BasePrice > maxPrice {
+			continue
+		}
+		if minRating > 0 && i.Rating < minRating {
+			continue
+		}
+		if tag != "" {
+			ok := false
+			for _, t := range i.Tags {
+				if strings.EqualFold(t, tag) {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+		}
+		out = append(out, i)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].Rating > out[b].Rating })
+	return out, nil
+}
+func (r *Memory) GetInstructor(id string) (models.Instructor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	i, ok := r.inst[id]
+	if !ok {
+		return models.Instructor{}, ErrNotFound
+	}
+	return i, nil
+}
+func (r *Memory) ListRoutes() ([]models.Route, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []models.Route{}
+	for _, v := range r.routes {
+		out = append(out, v)
+	}
+	return out, nil
+}
+func (r *Memory) GetRoute(id string) (models.Route, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.routes[id]
+	if !ok {
+		return models.Route{}, ErrNotFound
+	}
+	return v, nil
+}
+func (r *Memory) GetSlot(id string) (models.TimeSlot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.slots[id]
+	if !ok {
+		return models.TimeSlot{}, ErrNotFound
+	}
+	return s, nil
+}
+func (r *Memory) ListAvailability(date time.Time, routeID, instructorID string, includeFull bool) ([]models.TimeSlot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []models.TimeSlot{}
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	for _, s := range r.slots {
+		if s.StartAt.Before(start) || !s.StartAt.Before(end) {
+			continue
+		}
+		s.Remaining = r.slotFreeSeats(s)
+		if !(s.Status == "open" && s.Remaining > 0) && !(includeFull && (s.Status == "open" || s.Status == "closed")) {
+			continue
+		}
+		if routeID != "" && s.RouteID != routeID {
+			continue
+		}
+		if instructorID != "" && s.InstructorID != instructorID {
+			continue
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].StartAt.Before(out[b].StartAt) })
+	return out, nil
+}
+func (r *Memory) ListInstructorSlots(instructorID string, from, to time.Time) ([]models.TimeSlot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []models.TimeSlot{}
+	for _, s := range r.slots {
+		if s.InstructorID == instructorID && !s.StartAt.Before(from) && s.StartAt.Before(to) {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].StartAt.Before(out[b].StartAt) })
+	return out, nil
+}
+func (r *Memory) SetSlotBlocked(id string, blocked bool) (models.TimeSlot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.slots[id]
+	if !ok {
+		return models.TimeSlot{}, ErrNotFound
+	}
+	setSlotBlocked(&s, blocked)
+	s.UpdatedAt = time.Now().UTC()
+	r.slots[id] = s
+	return s, nil
+}
+func (r *Memory) CreateBooking(b *models.Booking) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.slots[b.SlotID]
+	if !ok {
+		return ErrSlotUnavailable
+	}
+	if err := bindSlot(b, s); err != nil {
+		return err
+	}
+	equipment, err := r.reserveEquipment(*b, s)
+	if err != nil {
+		return err
+	}
+	promo, err := r.usePromo(b.PromoCode)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	gift, err := r.useGift(*b, now)
+	if err != nil {
+		return err
+	}
+	if b.HoldToken != "" {
+		h, ok := r.holds[b.HoldToken]
+		if !ok {
+			return ErrHoldNotFound
+		}
+		if err := applyHold(h, b, &s, now); err != nil {
+			return err
+		}
+		delete(r.holds, h.Token)
+	} else if err := takeSeats(&s, b.Participants); err != nil {
+		return err
+	}
+	s.UpdatedAt = now
+	r.slots[s.ID] = s
+	b.ID = id()
+	b.Status = models.BookingPending
+	b.CreatedAt = now
+	b.UpdatedAt = now
+	r.bookings[b.ID] = *b
+	for i := range equipment {
+		equipment[i].BookingID = b.ID
+	}
+	r.reserved[b.ID] = equipment
+	if promo.Code != "" {
+		r.promos[promo.Code] = promo
+	}
+	if gift.Code != "" {
+		r.gifts[gift.Code] = gift
+		r.redeemed[b.ID] = models.GiftRedemption{BookingID: b.ID, Code: gift.Code, Amount: b.GiftAmount, CreatedAt: now}
+	}
+	r.recordStatus(b.ID, "", b.Status, "created", now)
+	if b.HoldToken != "" {
+		r.settleWaitlistHold(b.HoldToken, models.WaitlistAccepted, b)
+	}
+	return nil
+}
+func (r *Memory) recordStatus(bookingID, from, to, reason string, at time.Time) {
+	r.history[bookingID] = append(r.history[bookingID], models.BookingStatusChange{ID: id(), BookingID: bookingID, From: from, To: to, Reason: reason, CreatedAt: at})
+}
+func (r *Memory) GetBooking(id string) (models.Booking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b, ok := r.bookings[id]
+	if !ok {
+		return models.Booking{}, ErrNotFound
+	}
+	return b, nil
+}
+func (r *Memory) ListBookingMismatches() ([]models.BookingMismatch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []models.BookingMismatch{}
+	for _, b := range r.bookings {
+		s, ok := r.slots[b.SlotID]
+		if !ok {
+			out = append(out, models.BookingMismatch{BookingID: b.ID, SlotID: b.SlotID, Field: "slot_id", BookingValue: b.SlotID})
+			continue
+		}
+		out = append(out, slotMismatches(b, s)...)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].BookingID < out[b].BookingID })
+	return out, nil
+}
+func (r *Memory) UpsertInstructor(item *models.Instructor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if item.ID == "" {
+		item.ID = id()
+	}
+	item.UpdatedAt = time.Now().UTC()
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = item.UpdatedAt
+	}
+	r.inst[item.ID] = *item
+	return nil
+}
+func (r *Memory) UpsertRoute(item *models.Route) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.stations[item.StationID]; item.StationID != "" && !ok {
+		return ErrNotFound
+	}
+	if item.ID == "" {
+		item.ID = id()
+	}
+	item.UpdatedAt = time.Now().UTC()
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = item.UpdatedAt
+	}
+	r.routes[item.ID] = *item
+	return nil
+}
+func (r *Memory) BulkCreateSlots(slots []models.TimeSlot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	out := make([]models.TimeSlot, len(slots))
+	for i, s := range slots {
+		if other, ok := r.overlapping(s, slots[:i]); ok {
+			return fmt.Errorf("%w: %s at %s clashes with slot %s", ErrSlotOverlap, s.InstructorID, s.StartAt.Format(time.RFC3339), other.ID)
+		}
+		if old, ok := r.slots[s.ID]; ok && s.ID != "" {
+			updated, err := updateSlot(old, s)
+			if err != nil {
+				return err
+			}
+			out[i] = updated
+			continue
+		}
+		if s.ID == "" {
+			s.ID = id()
+		}
+		if s.Status == "" {
+			s.Status = "open"
+		}
+		if s.Remaining == 0 {
+			s.Remaining = s.Capacity
+		}
+		s.CreatedAt = now
+		out[i] = s
+	}
+	for _, s := range out {
+		s.UpdatedAt = now
+		r.slots[s.ID] = s
+	}
+	return nil
+}
+
+// overlapping finds a stored slot, or one of pending, that overlaps s.
+func (r *Memory) overlapping(s models.TimeSlot, pending []models.TimeSlot) (models.TimeSlot, bool) {
+	for _, other := range r.slots {
+		if other.ID != s.ID && overlaps(s, other) {
+			return other, true
+		}
+	}
+	for _, other := range pending {
+		if overlaps(s, other) {
+			return other, true
+		}
+	}
+	return models.TimeSlot{}, false
+}
+
+func (r *Memory) CreateSlotsIfAbsent(slots []models.TimeSlot) ([]models.TimeSlot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	out := []models.TimeSlot{}
+	for _, s := range slots {
+		s.ID = id()
+		if _, clash := r.overlapping(s, nil); clash {
+			continue
+		}
+		s.Status = "open"
+		s.Remaining = s.Capacity
+		s.CreatedAt = now
+		s.UpdatedAt = now
+		r.slots[s.ID] = s
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func (r *Memory) PatchBookingStatus(id, status, reason string) (models.Booking, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.bookings[id]
+	if !ok {
+		return models.Booking{}, ErrNotFound
+	}
+	if err := checkTransition(b.Status, status); err != nil {
+		return models.Booking{}, err
+	}
+	if b.Status == status {
+		return b, nil
+	}
+	now := time.Now().UTC()
+	if status == models.BookingCancelled {
+		b.GiftRefund = r.releaseBooking(b, 100, now)
+	}
+	r.recordStatus(b.ID, b.Status, status, reason, now)
+	b.Status = status
+	b.UpdatedAt = now
+	r.bookings[id] = b
+	return b, nil
+}
+
+func (r *Memory) CancelBooking(id, reason string, refund models.Refund) (models.Booking, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.bookings[id]
+	if !ok {
+		return models.Booking{}, false, ErrNotFound
+	}
+	if b.Status == models.BookingCancelled {
+		return b, false, nil
+	}
+	if err := checkTransition(b.Status, models.BookingCancelled); err != nil {
+		return models.Booking{}, false, err
+	}
+	now := time.Now().UTC()
+	b.GiftRefund = r.releaseBooking(b, refund.GiftPercent, now)
+	r.recordStatus(b.ID, b.Status, models.BookingCancelled, reason, now)
+	b.Status = models.BookingCancelled
+	b.RefundAmount, b.RefundRule = refund.Amount, refund.Rule
+	b.UpdatedAt = now
+	r.bookings[id] = b
+	return b, true, nil
+}
+
+// releaseBooking gives back the seats and equipment held by a booking being
+// cancelled and giftPercent of its gift certificate amount, returning the
+// latter.
+func (r *Memory) releaseBooking(b models.Booking, giftPercent int, now time.Time) int {
+	if s, ok := r.slots[b.SlotID]; ok {
+		releaseSeats(&s, b.Participants)
+		s.UpdatedAt = now
+		r.slots[s.ID] = s
+	}
+	delete(r.reserved, b.ID)
+	return r.releaseGift(b.ID, giftPercent, now)
+}
+
+func (r *Memory) RescheduleBooking(bookingID, slotID string, price *models.PriceBreakdown, giftAmount int, reason string) (models.Booking, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.bookings[bookingID]
+	if !ok {
+		return models.Booking{}, ErrNotFound
+	}
+	if err := checkReschedulable(b.Status); err != nil {
+		return models.Booking{}, err
+	}
+	to, ok := r.slots[slotID]
+	if !ok {
+		return models.Booking{}, ErrSlotUnavailable
+	}
+	if to.ID == b.SlotID {
+		return b, nil
+	}
+	equipment, err := r.reserveEquipment(b, to)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	if err := takeSeats(&to, b.Participants); err != nil {
+		return models.Booking{}, err
+	}
+	now := time.Now().UTC()
+	if from, ok := r.slots[b.SlotID]; ok {
+		releaseSeats(&from, b.Participants)
+		from.UpdatedAt = now
+		r.slots[from.ID] = from
+	}
+	to.UpdatedAt = now
+	r.slots[to.ID] = to
+	r.reserved[b.ID] = equipment
+	r.history[b.ID] = append(r.history[b.ID], models.BookingStatusChange{ID: id(), BookingID: b.ID, From: b.Status, To: b.Status, FromSlotID: b.SlotID, ToSlotID: to.ID, Reason: reason, CreatedAt: now})
+	b.SlotID, b.InstructorID, b.RouteID = to.ID, to.InstructorID, to.RouteID
+	if price != nil {
+		b.PriceTotal = price.Total
+		b.Price = price
+		if giftAmount < b.GiftAmount {
+			r.shrinkGift(b.ID, giftAmount, now)
+			b.GiftAmount = max(giftAmount, 0)
+		}
+	}
+	b.UpdatedAt = now
+	r.bookings[b.ID] = b
+	return b, nil
+}
+
+func (r *Memory) ListBookingsStarting(from, to time.Time, status string) ([]models.Booking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []models.Booking{}
+	for _, b := range r.bookings {
+		s, ok := r.slots[b.SlotID]
+		if !ok || b.Status != status || s.StartAt.Before(from) || !s.StartAt.Before(to) {
+			continue
+		}
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return r.slots[out[i].SlotID].StartAt.Before(r.slots[out[j].SlotID].StartAt) })
+	return out, nil
+}
+func (r *Memory) ListSlotBookings(slotIDs []string) ([]models.Booking, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	want := map[string]bool{}
+	for _, id := range slotIDs {
+		want[id] = true
+	}
+	out := []models.Booking{}
+	for _, b := range r.bookings {
+		if want[b.SlotID] {
+			out = append(out, b)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+func (r *Memory) ListBookingHistory(id string) ([]models.BookingStatusChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.bookings[id]; !ok {
+		return nil, ErrNotFound
+	}
+	return append([]models.BookingStatusChange{}, r.history[id]...), nil
+}
+func (r *Memory) FindWeatherSnapshot(lat, lng float64, timeFrom time.Time, ttl time.Duration) (models.WeatherSnapshot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	threshold := time.Now().UTC().Add(-ttl)
+	for i := len(r.weather) - 1; i >= 0; i-- {
+		w := r.weather[i]
+		if w.LocationLat == lat && w.LocationLng == lng && w.TimeFrom.Equal(timeFrom) && w.FetchedAt.After(threshold) {
+			return w, nil
+		}
+	}
+	return models.WeatherSnapshot{}, ErrNotFound
+}
+func (r *Memory) SaveWeatherSnapshot(s *models.WeatherSnapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s.ID == "" {
+		s.ID = id()
+	}
+	r.weather = append(r.weather, *s)
+	return nil
+}
+func (r *Memory) SuggestedSlots(target time.Time, routeID, instructorID string, limit int) ([]models.TimeSlot, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []models.TimeSlot{}
+	for _, s := range r.slots {
+		if s.Status != "open" || s.Remaining <= 0 {
+			continue
+		}
+		if routeID != "" && s.RouteID != routeID {
+			continue
+		}
+		if instructorID != "" && s.InstructorID != instructorID {
+			continue
+		}
+		if s.StartAt.Before(target.Add(-4*time.Hour)) || s.StartAt.After(target.Add(8*time.Hour)) {
+			continue
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(a, b int) bool {
+		da := out[a].StartAt.Sub(target)
+		if da < 0 {
+			da = -da
+		}
+		db := out[b].StartAt.Sub(target)
+		if db < 0 {
+			db = -db
+		}
+		return da < db
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+func (r *Memory) CreateHold(slotID string, seats int, ttl time.Duration) (models.SeatHold, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.slots[slotID]
+	if !ok {
+		return models.SeatHold{}, ErrSlotUnavailable
+	}
+	if err := takeSeats(&s, seats); err != nil {
+		return models.SeatHold{}, err
+	}
+	now := time.Now().UTC()
+	s.UpdatedAt = now
+	r.slots[s.ID] = s
+	h := models.SeatHold{Token: id(), SlotID: s.ID, Seats: seats, ExpiresAt: now.Add(ttl), CreatedAt: now}
+	r.holds[h.Token] = h
+	return h, nil
+}
+func (r *Memory) GetHold(token string) (models.SeatHold, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.holds[token]
+	if !ok {
+		return models.SeatHold{}, ErrHoldNotFound
+	}
+	return h, nil
+}
+func (r *Memory) ReleaseHold(token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.holds[token]
+	if !ok {
+		return ErrHoldNotFound
+	}
+	r.releaseHold(h, time.Now().UTC())
+	return nil
+}
+func (r *Memory) ReleaseExpiredHolds(now time.Time) ([]models.SeatHold, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.SeatHold{}
+	for _, h := range r.holds {
+		if now.Before(h.ExpiresAt) {
+			continue
+		}
+		r.releaseHold(h, now)
+		out = append(out, h)
+	}
+	return out, nil
+}
+func (r *Memory) releaseHold(h models.SeatHold, now time.Time) {
+	delete(r.holds, h.Token)
+	r.settleWaitlistHold(h.Token, models.WaitlistExpired, nil)
+	if s, ok := r.slots[h.SlotID]; ok {
+		releaseSeats(&s, h.Seats)
+		s.UpdatedAt = now
+		r.slots[s.ID] = s
+	}
+}
+func (r *Memory) ReserveIdempotencyKey(key, requestHash string, retention time.Duration) (models.IdempotencyKey, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	if k, ok := r.idem[key]; ok && now.Before(k.ExpiresAt) {
+		return k, false, nil
+	}
+	k := models.IdempotencyKey{Key: key, RequestHash: requestHash, CreatedAt: now, ExpiresAt: now.Add(retention)}
+	r.idem[key] = k
+	return k, true, nil
+}
+func (r *Memory) CompleteIdempotencyKey(key, bookingID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.idem[key]
+	if !ok {
+		return ErrNotFound
+	}
+	k.BookingID = bookingID
+	r.idem[key] = k
+	return nil
+}
+func (r *Memory) ReleaseIdempotencyKey(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.idem, key)
+	return nil
+}
+func (r *Memory) PurgeIdempotencyKeys(now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for key, k := range r.idem {
+		if !now.Before(k.ExpiresAt) {
+			delete(r.idem, key)
+			n++
+		}
+	}
+	return n, nil
+}
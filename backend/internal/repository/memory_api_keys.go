@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func (r *Memory) CreateAPIKey(k *models.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if k.InstructorID != "" {
+		if _, ok := r.inst[k.InstructorID]; !ok {
+			return ErrNotFound
+		}
+	}
+	k.ID = id()
+	k.CreatedAt = time.Now().UTC()
+	k.RevokedAt = nil
+	r.apiKeys[k.ID] = *k
+	return nil
+}
+func (r *Memory) FindAPIKey(hash string) (models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.apiKeys {
+		if k.KeyHash == hash && k.RevokedAt == nil {
+			return k, nil
+		}
+	}
+	return models.APIKey{}, ErrNotFound
+}
+func (r *Memory) ListAPIKeys() ([]models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]models.APIKey, 0, len(r.apiKeys))
+	for _, k := range r.apiKeys {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+func (r *Memory) RevokeAPIKey(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.apiKeys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if k.RevokedAt == nil {
+		now := time.Now().UTC()
+		k.RevokedAt = &now
+		r.apiKeys[id] = k
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func (r *Memory) CreateGift(g *models.GiftCertificate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if g.RouteID != "" {
+		if _, ok := r.routes[g.RouteID]; !ok {
+			return ErrNotFound
+		}
+	}
+	for g.Code = giftCode(); ; g.Code = giftCode() {
+		if _, taken := r.gifts[g.Code]; !taken {
+			break
+		}
+	}
+	now := time.Now().UTC()
+	g.Status = models.GiftActive
+	g.CreatedAt = now
+	g.UpdatedAt = now
+	r.gifts[g.Code] = *g
+	return nil
+}
+func (r *Memory) GetGift(code string) (models.GiftCertificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	g, ok := r.gifts[code]
+	if !ok {
+		return models.GiftCertificate{}, ErrNotFound
+	}
+	return g, nil
+}
+func (r *Memory) ListGifts(status string) ([]models.GiftCertificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []models.GiftCertificate{}
+	for _, g := range r.gifts {
+		if status == "" || g.Status == status {
+			out = append(out, g)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+func (r *Memory) VoidGift(code string) (models.GiftCertificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.gifts[code]
+	if !ok {
+		return g, ErrNotFound
+	}
+	if g.Status != models.GiftActive {
+		return g, ErrGiftClosed
+	}
+	g.Status = models.GiftVoided
+	g.UpdatedAt = time.Now().UTC()
+	r.gifts[code] = g
+	return g, nil
+}
+func (r *Memory) ListGiftRedemptions(code string) ([]models.GiftRedemption, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []models.GiftRedemption{}
+	for _, red := range r.redeemed {
+		if red.Code == code {
+			out = append(out, red)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// useGift returns the booking's certificate with its amount spent, without
+// saving it. A booking without a gift code yields a zero certificate.
+func (r *Memory) useGift(b models.Booking, now time.Time) (models.GiftCertificate, error) {
+	if b.GiftCode == "" {
+		return models.GiftCertificate{}, nil
+	}
+	g, ok := r.gifts[b.GiftCode]
+	if !ok {
+		return g, ErrNotFound
+	}
+	if err := spendGift(&g, b.GiftAmount, now); err != nil {
+		return g, err
+	}
+	g.UpdatedAt = now
+	return g, nil
+}
+
+// shrinkGift lowers the booking's redemption to amount and gives the
+// difference back to the certificate.
+func (r *Memory) shrinkGift(bookingID string, amount int, now time.Time) {
+	red, ok := r.redeemed[bookingID]
+	if !ok || amount >= red.Amount {
+		return
+	}
+	if g, ok := r.gifts[red.Code]; ok {
+		returnGiftPart(&g, red.Amount-amount)
+		g.UpdatedAt = now
+		r.gifts[g.Code] = g
+	}
+	red.Amount = amount
+	r.redeemed[bookingID] = red
+}
+
+func (r *Memory) releaseGift(bookingID string, percent int, now time.Time) int {
+	red, ok := r.redeemed[bookingID]
+	if !ok {
+		return 0
+	}
+	back := 0
+	if g, ok := r.gifts[red.Code]; ok {
+		back = refundGift(&g, red.Amount, percent)
+		g.UpdatedAt = now
+		r.gifts[g.Code] = g
+	}
+	delete(r.redeemed, bookingID)
+	return back
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func (r *Memory) CreateRescheduleOffer(o *models.RescheduleOffer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.bookings[o.BookingID]; !ok {
+		return ErrNotFound
+	}
+	for _, existing := range r.offers {
+		if existing.BookingID == o.BookingID && existing.Status == models.OfferPending {
+			return ErrOfferExists
+		}
+	}
+	now := time.Now().UTC()
+	o.ID = id()
+	o.Token = id()
+	o.SlotIDs = append([]string{}, o.SlotIDs...)
+	o.Status = models.OfferPending
+	o.ChosenSlotID = ""
+	o.CreatedAt = now
+	o.UpdatedAt = now
+	r.offers[o.Token] = *o
+	return nil
+}
+func (r *Memory) GetRescheduleOffer(token string) (models.RescheduleOffer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	o, ok := r.offers[token]
+	if !ok {
+		return models.RescheduleOffer{}, ErrNotFound
+	}
+	return o, nil
+}
+func (r *Memory) ListRescheduleOffers(status string) ([]models.RescheduleOffer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []models.RescheduleOffer{}
+	for _, o := range r.offers {
+		if status == "" || o.Status == status {
+			out = append(out, o)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+func (r *Memory) SetRescheduleOfferStatus(token, from, to, slotID string) (models.RescheduleOffer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.offers[token]
+	if !ok {
+		return models.RescheduleOffer{}, ErrNotFound
+	}
+	if o.Status != from {
+		return models.RescheduleOffer{}, ErrOfferClosed
+	}
+	o.Status = to
+	o.ChosenSlotID = slotID
+	o.UpdatedAt = time.Now().UTC()
+	r.offers[token] = o
+	return o, nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func (r *Memory) ListOptions() ([]models.Option, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]models.Option, 0, len(r.options))
+	for _, o := range r.options {
+		out = append(out, o)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out, nil
+}
+func (r *Memory) GetOption(code string) (models.Option, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	o, ok := r.options[code]
+	if !ok {
+		return models.Option{}, ErrNotFound
+	}
+	return o, nil
+}
+func (r *Memory) UpsertOption(o *models.Option) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	if existing, ok := r.options[o.Code]; ok {
+		o.CreatedAt = existing.CreatedAt
+	} else {
+		o.CreatedAt = now
+	}
+	o.RouteIDs = append([]string{}, o.RouteIDs...)
+	o.UpdatedAt = now
+	r.options[o.Code] = *o
+	return nil
+}
+func (r *Memory) DeleteOption(code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.options[code]; !ok {
+		return ErrNotFound
+	}
+	delete(r.options, code)
+	return nil
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func (r *Memory) CreatePayment(p *models.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.bookings[p.BookingID]; !ok {
+		return ErrNotFound
+	}
+	now := time.Now().UTC()
+	p.ID = id()
+	p.CreatedAt = now
+	p.UpdatedAt = now
+	r.payments[p.ID] = *p
+	return nil
+}
+func (r *Memory) GetPayment(id string) (models.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.payments[id]
+	if !ok {
+		return models.Payment{}, ErrNotFound
+	}
+	return p, nil
+}
+func (r *Memory) FindPayment(provider, ref string) (models.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, p := range r.payments {
+		if p.Provider == provider && p.ProviderRef == ref {
+			return p, nil
+		}
+	}
+	return models.Payment{}, ErrNotFound
+}
+func (r *Memory) ListBookingPayments(bookingID string) ([]models.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []models.Payment{}
+	for _, p := range r.payments {
+		if p.BookingID == bookingID {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+func (r *Memory) SetPaymentStatus(id, from, to string) (models.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.payments[id]
+	if !ok {
+		return p, ErrNotFound
+	}
+	if p.Status != from {
+		return p, ErrPaymentClosed
+	}
+	p.Status = to
+	p.UpdatedAt = time.Now().UTC()
+	r.payments[id] = p
+	return p, nil
+}
+func (r *Memory) AddPaymentRefund(id string, amount int) (models.Payment, error) {
+	return r.changeRefund(id, amount, addRefund)
+}
+func (r *Memory) UndoPaymentRefund(id string, amount int) (models.Payment, error) {
+	return r.changeRefund(id, amount, undoRefund)
+}
+func (r *Memory) changeRefund(id string, amount int, change func(*models.Payment, int) error) (models.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.payments[id]
+	if !ok {
+		return p, ErrNotFound
+	}
+	if err := change(&p, amount); err != nil {
+		return r.payments[id], err
+	}
+	p.UpdatedAt = time.Now().UTC()
+	r.payments[id] = p
+	return p, nil
+}
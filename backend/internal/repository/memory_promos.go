@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func (r *Memory) ListPromoCodes() ([]models.PromoCode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]models.PromoCode, 0, len(r.promos))
+	for _, p := range r.promos {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out, nil
+}
+func (r *Memory) GetPromoCode(code string) (models.PromoCode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.promos[code]
+	if !ok {
+		return models.PromoCode{}, ErrNotFound
+	}
+	return p, nil
+}
+func (r *Memory) UpsertPromoCode(p *models.PromoCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	if existing, ok := r.promos[p.Code]; ok {
+		p.CreatedAt = existing.CreatedAt
+		p.Used = existing.Used
+	} else {
+		p.CreatedAt = now
+		p.Used = 0
+	}
+	p.RouteIDs = append([]string{}, p.RouteIDs...)
+	p.InstructorIDs = append([]string{}, p.InstructorIDs...)
+	p.UpdatedAt = now
+	r.promos[p.Code] = *p
+	return nil
+}
+func (r *Memory) DeletePromoCode(code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.promos[code]; !ok {
+		return ErrNotFound
+	}
+	delete(r.promos, code)
+	return nil
+}
+
+// usePromo returns the code with one more use counted, without saving it.
+// An empty code yields a zero PromoCode.
+func (r *Memory) usePromo(code string) (models.PromoCode, error) {
+	if code == "" {
+		return models.PromoCode{}, nil
+	}
+	p, ok := r.promos[code]
+	if !ok {
+		return p, ErrNotFound
+	}
+	if p.MaxUses > 0 && p.Used >= p.MaxUses {
+		return p, ErrPromoExhausted
+	}
+	p.Used++
+	p.UpdatedAt = time.Now().UTC()
+	return p, nil
+}
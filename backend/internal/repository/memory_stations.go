@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func (r *Memory) ListStations() ([]models.Station, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]models.Station, 0, len(r.stations))
+	for _, st := range r.stations {
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Title < out[j].Title })
+	return out, nil
+}
+func (r *Memory) GetStation(id string) (models.Station, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	st, ok := r.stations[id]
+	if !ok {
+		return models.Station{}, ErrNotFound
+	}
+	return st, nil
+}
+func (r *Memory) UpsertStation(st *models.Station) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	if existing, ok := r.stations[st.ID]; ok {
+		st.CreatedAt = existing.CreatedAt
+	} else {
+		if st.ID == "" {
+			st.ID = id()
+		}
+		st.CreatedAt = now
+	}
+	stock := map[string]int{}
+	for kind, n := range st.Stock {
+		stock[kind] = n
+	}
+	st.Stock = stock
+	st.UpdatedAt = now
+	r.stations[st.ID] = *st
+	return nil
+}
+func (r *Memory) FreeEquipment(stationID string, from, to time.Time) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	st, ok := r.stations[stationID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return freeStock(st, r.reservedEquipment(st.ID, from, to, "")), nil
+}
+
+// reservedEquipment sums what bookings other than exceptBooking hold at the
+// station during [from, to).
+func (r *Memory) reservedEquipment(stationID string, from, to time.Time, exceptBooking string) map[string]int {
+	out := map[string]int{}
+	for bookingID, list := range r.reserved {
+		if bookingID == exceptBooking {
+			continue
+		}
+		for _, e := range list {
+			if e.StationID == stationID && e.StartAt.Before(to) && from.Before(e.EndAt) {
+				out[e.Kind] += e.Quantity
+			}
+		}
+	}
+	return out
+}
+
+// reserveEquipment plans the reservations of booking b in slot s; routes
+// without a station reserve nothing.
+func (r *Memory) reserveEquipment(b models.Booking, s models.TimeSlot) ([]models.EquipmentReservation, error) {
+	st, ok := r.stations[r.routes[s.RouteID].StationID]
+	if !ok {
+		return nil, nil
+	}
+	return planReservations(b, s, st, r.reservedEquipment(st.ID, s.StartAt, s.EndAt, b.ID))
+}
+
+// slotFreeSeats is Remaining capped by the station's free boards and paddles.
+func (r *Memory) slotFreeSeats(s models.TimeSlot) int {
+	st, ok := r.stations[r.routes[s.RouteID].StationID]
+	if !ok {
+		return s.Remaining
+	}
+	return effectiveRemaining(s.Remaining, freeStock(st, r.reservedEquipment(st.ID, s.StartAt, s.EndAt, "")))
+}
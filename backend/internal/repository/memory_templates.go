@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func (r *Memory) ListTemplates() ([]models.ScheduleTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]models.ScheduleTemplate, 0, len(r.tpl))
+	for _, t := range r.tpl {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+func (r *Memory) GetTemplate(id string) (models.ScheduleTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tpl[id]
+	if !ok {
+		return models.ScheduleTemplate{}, ErrNotFound
+	}
+	return t, nil
+}
+func (r *Memory) UpsertTemplate(t *models.ScheduleTemplate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.inst[t.InstructorID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := r.routes[t.RouteID]; !ok {
+		return ErrNotFound
+	}
+	now := time.Now().UTC()
+	if existing, ok := r.tpl[t.ID]; ok {
+		t.CreatedAt = existing.CreatedAt
+	} else {
+		if t.ID == "" {
+			t.ID = id()
+		}
+		t.CreatedAt = now
+	}
+	t.Weekdays = append([]int{}, t.Weekdays...)
+	t.Times = append([]string{}, t.Times...)
+	t.UpdatedAt = now
+	r.tpl[t.ID] = *t
+	return nil
+}
+func (r *Memory) DeleteTemplate(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tpl[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.tpl, id)
+	return nil
+}
@@ -0,0 +1,12 @@
+package repository_test
+
+import (
+	"testing"
+
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/repository/storetest"
+)
+
+func TestMemory(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) repository.Repository { return repository.New() })
+}
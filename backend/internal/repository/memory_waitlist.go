@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func (r *Memory) JoinWaitlist(e *models.WaitlistEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.slots[e.SlotID]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now().UTC()
+	e.ID = id()
+	e.SlotID = s.ID
+	e.Status = models.WaitlistWaiting
+	e.HoldToken, e.OfferExpiresAt, e.BookingID = "", nil, ""
+	e.CreatedAt = now
+	e.UpdatedAt = now
+	r.waitlist[e.ID] = *e
+	return nil
+}
+func (r *Memory) GetWaitlistEntry(id string) (models.WaitlistEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.waitlist[id]
+	if !ok {
+		return models.WaitlistEntry{}, ErrNotFound
+	}
+	return e, nil
+}
+func (r *Memory) ListWaitlist(slotID string) ([]models.WaitlistEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.queue(slotID, ""), nil
+}
+func (r *Memory) CancelWaitlistEntry(id string) (models.WaitlistEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.waitlist[id]
+	if !ok {
+		return models.WaitlistEntry{}, ErrNotFound
+	}
+	if e.Status != models.WaitlistWaiting && e.Status != models.WaitlistOffered {
+		return models.WaitlistEntry{}, ErrWaitlistClosed
+	}
+	now := time.Now().UTC()
+	if h, ok := r.holds[e.HoldToken]; ok && e.Status == models.WaitlistOffered {
+		r.releaseHold(h, now)
+	}
+	e.Status = models.WaitlistCancelled
+	e.UpdatedAt = now
+	r.waitlist[id] = e
+	return e, nil
+}
+func (r *Memory) OfferWaitlist(slotID string, ttl time.Duration) ([]models.WaitlistEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []models.WaitlistEntry{}
+	for _, e := range r.queue(slotID, models.WaitlistWaiting) {
+		s := r.slots[e.SlotID]
+		if s.Status != "open" || s.Remaining <= 0 {
+			break
+		}
+		if e.Participants > s.Remaining {
+			continue
+		}
+		if err := r.offer(&e, ttl); err != nil {
+			return out, err
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+func (r *Memory) OfferWaitlistEntry(id string, ttl time.Duration) (models.WaitlistEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.waitlist[id]
+	if !ok {
+		return models.WaitlistEntry{}, ErrNotFound
+	}
+	if e.Status != models.WaitlistWaiting {
+		return models.WaitlistEntry{}, ErrWaitlistClosed
+	}
+	return e, r.offer(&e, ttl)
+}
+
+// offer holds seats for the entry; callers hold r.mu.
+func (r *Memory) offer(e *models.WaitlistEntry, ttl time.Duration) error {
+	s, ok := r.slots[e.SlotID]
+	if !ok {
+		return ErrSlotUnavailable
+	}
+	if err := takeSeats(&s, e.Participants); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	s.UpdatedAt = now
+	r.slots[s.ID] = s
+	h := models.SeatHold{Token: id(), SlotID: s.ID, Seats: e.Participants, ExpiresAt: now.Add(ttl), CreatedAt: now}
+	r.holds[h.Token] = h
+	e.Status = models.WaitlistOffered
+	e.HoldToken = h.Token
+	e.OfferExpiresAt = &h.ExpiresAt
+	e.UpdatedAt = now
+	r.waitlist[e.ID] = *e
+	return nil
+}
+
+// settleWaitlistHold moves an offered entry to its final status once its
+// hold is booked or released; callers hold r.mu.
+func (r *Memory) settleWaitlistHold(token, status string, b *models.Booking) {
+	for _, e := range r.waitlist {
+		if e.HoldToken != token || e.Status != models.WaitlistOffered {
+			continue
+		}
+		e.Status = status
+		if b != nil {
+			e.BookingID = b.ID
+		}
+		e.UpdatedAt = time.Now().UTC()
+		r.waitlist[e.ID] = e
+	}
+}
+
+// queue returns entries in FIFO order, optionally filtered by slot and status.
+func (r *Memory) queue(slotID, status string) []models.WaitlistEntry {
+	out := []models.WaitlistEntry{}
+	for _, e := range r.waitlist {
+		if (slotID == "" || e.SlotID == slotID) && (status == "" || e.Status == status) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].CreatedAt.Before(out[b].CreatedAt) })
+	return out
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"errors"
+
+	"sup-anapa/backend/internal/models"
+)
+
+var (
+	ErrPaymentClosed  = errors.New("payment is not in the expected status")
+	ErrRefundTooLarge = errors.New("refund exceeds the paid amount")
+)
+
+// addRefund records a refund on a succeeded payment.
+func addRefund(p *models.Payment, amount int) error {
+	if p.Status != models.PaymentSucceeded {
+		return ErrPaymentClosed
+	}
+	if amount < 1 || p.Refunded+amount > p.Amount {
+		return ErrRefundTooLarge
+	}
+	p.Refunded += amount
+	if p.Refunded == p.Amount {
+		p.Status = models.PaymentRefunded
+	}
+	return nil
+}
+
+// undoRefund takes back a refund the provider did not carry out.
+func undoRefund(p *models.Payment, amount int) error {
+	if p.Status != models.PaymentSucceeded && p.Status != models.PaymentRefunded {
+		return ErrPaymentClosed
+	}
+	if amount < 1 || amount > p.Refunded {
+		return ErrRefundTooLarge
+	}
+	p.Refunded -= amount
+	p.Status = models.PaymentSucceeded
+	return nil
+}
@@ -0,0 +1,896 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"sup-anapa/backend/internal/models"
+)
+
+// Postgres stores everything in the schema from migrations/.
+type Postgres struct {
+	pool    *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewPostgres(pool *pgxpool.Pool) *Postgres {
+	return &Postgres{pool: pool, timeout: 5 * time.Second}
+}
+
+func (p *Postgres) ctx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), p.timeout)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// notFound maps "no rows" and malformed UUID input to ErrNotFound.
+func notFound(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == "22P02") {
+		return ErrNotFound
+	}
+	return err
+}
+
+const instructorCols = `id::text, name, COALESCE(photo_url, ''), COALESCE(bio, ''), COALESCE(rating, 0), COALESCE(reviews_count, 0), COALESCE(experience_years, 0), tags, languages, base_price, is_active, created_at, updated_at`
+
+func scanInstructor(row scanner) (models.Instructor, error) {
+	var i models.Instructor
+	err := row.Scan(&i.ID, &i.Name, &i.PhotoURL, &i.Bio, &i.Rating, &i.ReviewsCount, &i.ExperienceYears, &i.Tags, &i.Languages, &i.BasePrice, &i.IsActive, &i.CreatedAt, &i.UpdatedAt)
+	return i, err
+}
+
+const routeCols = `id::text, title, duration_minutes, difficulty, base_price, COALESCE(description, ''), location_lat, location_lng, location_title, COALESCE(station_id::text, ''), shore_bearing, created_at, updated_at`
+
+func scanRoute(row scanner) (models.Route, error) {
+	var r models.Route
+	err := row.Scan(&r.ID, &r.Title, &r.DurationMinutes, &r.Difficulty, &r.BasePrice, &r.Description, &r.LocationLat, &r.LocationLng, &r.LocationTitle, &r.StationID, &r.ShoreBearing, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+const slotCols = `id::text, instructor_id::text, route_id::text, start_at, end_at, capacity, remaining, status, created_at, updated_at`
+
+func scanSlot(row scanner) (models.TimeSlot, error) {
+	var s models.TimeSlot
+	err := row.Scan(&s.ID, &s.InstructorID, &s.RouteID, &s.StartAt, &s.EndAt, &s.Capacity, &s.Remaining, &s.Status, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+func scanSlots(rows pgx.Rows) ([]models.TimeSlot, error) {
+	defer rows.Close()
+	out := []models.TimeSlot{}
+	for rows.Next() {
+		s, err := scanSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// lockSlot reads a slot FOR UPDATE; a missing slot is ErrSlotUnavailable.
+//
+// Writers that lock several rows take them in one order so they cannot
+// deadlock: the booking, its slots by id, the station, the gift
+// certificate, then the promo code.
+func lockSlot(ctx context.Context, tx pgx.Tx, id string) (models.TimeSlot, error) {
+	s, err := scanSlot(tx.QueryRow(ctx, `SELECT `+slotCols+` FROM time_slots WHERE id = $1::uuid FOR UPDATE`, id))
+	return s, slotErr(err)
+}
+
+// slotErr reports a missing or malformed slot id as an unavailable slot.
+func slotErr(err error) error {
+	if errors.Is(notFound(err), ErrNotFound) {
+		return ErrSlotUnavailable
+	}
+	return err
+}
+
+func saveSlotSeats(ctx context.Context, tx pgx.Tx, s models.TimeSlot) error {
+	_, err := tx.Exec(ctx, `UPDATE time_slots SET remaining = $2, status = $3, updated_at = now() WHERE id = $1::uuid`, s.ID, s.Remaining, s.Status)
+	return err
+}
+
+const bookingCols = `id::text, instructor_id::text, route_id::text, slot_id::text, customer_name, phone, COALESCE(messenger, ''), participants, options, option_lines, COALESCE(promo_code, ''), COALESCE(gift_code, ''), gift_amount, refund_amount, COALESCE(refund_rule, ''), gift_refund, price_total, price_breakdown, status, created_at, updated_at`
+
+func scanBooking(row scanner) (models.Booking, error) {
+	var b models.Booking
+	err := row.Scan(&b.ID, &b.InstructorID, &b.RouteID, &b.SlotID, &b.CustomerName, &b.Phone, &b.Messenger, &b.Participants, &b.Options, &b.OptionLines, &b.PromoCode, &b.GiftCode, &b.GiftAmount, &b.RefundAmount, &b.RefundRule, &b.GiftRefund, &b.PriceTotal, &b.Price, &b.Status, &b.CreatedAt, &b.UpdatedAt)
+	return b, err
+}
+
+func scanBookings(rows pgx.Rows) ([]models.Booking, error) {
+	defer rows.Close()
+	out := []models.Booking{}
+	for rows.Next() {
+		b, err := scanBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+const weatherCols = `id::text, location_lat, location_lng, time_from, time_to, temperature, wind_speed, wind_gusts, wind_direction, precipitation, cloud_cover, wave_height, wave_period, sea_temperature, provider, conditions_level, score, raw, fetched_at, created_at, updated_at`
+
+func scanWeather(row scanner) (models.WeatherSnapshot, error) {
+	var w models.WeatherSnapshot
+	err := row.Scan(&w.ID, &w.LocationLat, &w.LocationLng, &w.TimeFrom, &w.TimeTo, &w.Temperature, &w.WindSpeed, &w.WindGusts, &w.WindDirection, &w.Precipitation, &w.CloudCover, &w.WaveHeight, &w.WavePeriod, &w.SeaTemperature, &w.Provider, &w.ConditionsLevel, &w.Score, &w.Raw, &w.FetchedAt, &w.CreatedAt, &w.UpdatedAt)
+	return w, err
+}
+
+func (p *Postgres) ListInstructors(minPrice, maxPrice int, minRating float64, tag string) ([]models.Instructor, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+instructorCols+` FROM instructors
+		WHERE is_active
+		  AND ($1 = 0 OR base_price >= $1)
+		  AND ($2 = 0 OR base_price <= $2)
+		  AND ($3::float8 = 0 OR rating >= $3::float8)
+		  AND ($4 = '' OR EXISTS (SELECT 1 FROM jsonb_array_elements_text(tags) t WHERE lower(t) = lower($4)))
+		ORDER BY rating DESC`, minPrice, maxPrice, minRating, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.Instructor{}
+	for rows.Next() {
+		i, err := scanInstructor(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, i)
+	}
+	return out, rows.Err()
+}
+func (p *Postgres) GetInstructor(id string) (models.Instructor, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	i, err := scanInstructor(p.pool.QueryRow(ctx, `SELECT `+instructorCols+` FROM instructors WHERE id = $1::uuid`, id))
+	return i, notFound(err)
+}
+func (p *Postgres) UpsertInstructor(item *models.Instructor) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	if item.Tags == nil {
+		item.Tags = []string{}
+	}
+	if item.Languages == nil {
+		item.Languages = []string{}
+	}
+	return p.pool.QueryRow(ctx, `INSERT INTO instructors (id, name, photo_url, bio, rating, reviews_count, experience_years, tags, languages, base_price, is_active)
+		VALUES (COALESCE(NULLIF($1, '')::uuid, gen_random_uuid()), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, photo_url = EXCLUDED.photo_url, bio = EXCLUDED.bio, rating = EXCLUDED.rating,
+			reviews_count = EXCLUDED.reviews_count, experience_years = EXCLUDED.experience_years, tags = EXCLUDED.tags, languages = EXCLUDED.languages,
+			base_price = EXCLUDED.base_price, is_active = EXCLUDED.is_active, updated_at = now()
+		RETURNING id::text, created_at, updated_at`,
+		item.ID, item.Name, item.PhotoURL, item.Bio, item.Rating, item.ReviewsCount, item.ExperienceYears, item.Tags, item.Languages, item.BasePrice, item.IsActive,
+	).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
+}
+func (p *Postgres) ListRoutes() ([]models.Route, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+routeCols+` FROM routes ORDER BY title`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.Route{}
+	for rows.Next() {
+		r, err := scanRoute(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+func (p *Postgres) GetRoute(id string) (models.Route, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	r, err := scanRoute(p.pool.QueryRow(ctx, `SELECT `+routeCols+` FROM routes WHERE id = $1::uuid`, id))
+	return r, notFound(err)
+}
+func (p *Postgres) UpsertRoute(item *models.Route) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	err := p.pool.QueryRow(ctx, `INSERT INTO routes (id, title, duration_minutes, difficulty, base_price, description, location_lat, location_lng, location_title, station_id, shore_bearing)
+		VALUES (COALESCE(NULLIF($1, '')::uuid, gen_random_uuid()), $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')::uuid, $11)
+		ON CONFLICT (id) DO UPDATE SET title = EXCLUDED.title, duration_minutes = EXCLUDED.duration_minutes, difficulty = EXCLUDED.difficulty,
+			base_price = EXCLUDED.base_price, description = EXCLUDED.description, location_lat = EXCLUDED.location_lat,
+			location_lng = EXCLUDED.location_lng, location_title = EXCLUDED.location_title, station_id = EXCLUDED.station_id, shore_bearing = EXCLUDED.shore_bearing, updated_at = now()
+		RETURNING id::text, created_at, updated_at`,
+		item.ID, item.Title, item.DurationMinutes, item.Difficulty, item.BasePrice, item.Description, item.LocationLat, item.LocationLng, item.LocationTitle, item.StationID, item.ShoreBearing,
+	).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return ErrNotFound
+	}
+	return notFound(err)
+}
+func (p *Postgres) GetSlot(id string) (models.TimeSlot, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	s, err := scanSlot(p.pool.QueryRow(ctx, `SELECT `+slotCols+` FROM time_slots WHERE id = $1::uuid`, id))
+	return s, notFound(err)
+}
+func (p *Postgres) ListAvailability(date time.Time, routeID, instructorID string, includeFull bool) ([]models.TimeSlot, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	// remaining is capped by the least free seat kind at the route's
+	// station; MIN over no tracked kinds is NULL, which LEAST ignores.
+	rows, err := p.pool.Query(ctx, `SELECT `+slotCols+` FROM (
+		SELECT s.id, s.instructor_id, s.route_id, s.start_at, s.end_at, s.capacity, s.status, s.created_at, s.updated_at,
+			LEAST(s.remaining, (
+				SELECT MIN(GREATEST((st.stock ->> k.kind)::int - COALESCE((
+					SELECT SUM(q.quantity) FROM equipment_reservations q
+					WHERE q.station_id = st.id AND q.kind = k.kind AND q.start_at < s.end_at AND q.end_at > s.start_at), 0), 0))
+				FROM routes rt JOIN stations st ON st.id = rt.station_id, unnest($6::text[]) AS k(kind)
+				WHERE rt.id = s.route_id AND st.stock ? k.kind)) AS remaining
+		FROM time_slots s
+		WHERE s.start_at >= $1 AND s.start_at < $2
+		  AND ($3 = '' OR s.route_id = NULLIF($3, '')::uuid)
+		  AND ($4 = '' OR s.instructor_id = NULLIF($4, '')::uuid)
+	) slots
+	WHERE (status = 'open' AND remaining > 0) OR ($5 AND status IN ('open', 'closed'))
+	ORDER BY start_at`, start, start.Add(24*time.Hour), routeID, instructorID, includeFull, seatKinds)
+	if err != nil {
+		return nil, err
+	}
+	return scanSlots(rows)
+}
+func (p *Postgres) ListInstructorSlots(instructorID string, from, to time.Time) ([]models.TimeSlot, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+slotCols+` FROM time_slots
+		WHERE instructor_id = $1::uuid AND start_at >= $2 AND start_at < $3 ORDER BY start_at`, instructorID, from, to)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return scanSlots(rows)
+}
+func (p *Postgres) SetSlotBlocked(id string, blocked bool) (models.TimeSlot, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return models.TimeSlot{}, err
+	}
+	defer tx.Rollback(ctx)
+	s, err := scanSlot(tx.QueryRow(ctx, `SELECT `+slotCols+` FROM time_slots WHERE id = $1::uuid FOR UPDATE`, id))
+	if err != nil {
+		return models.TimeSlot{}, notFound(err)
+	}
+	setSlotBlocked(&s, blocked)
+	s, err = scanSlot(tx.QueryRow(ctx, `UPDATE time_slots SET status = $2, updated_at = now() WHERE id = $1::uuid RETURNING `+slotCols, s.ID, s.Status))
+	if err != nil {
+		return models.TimeSlot{}, err
+	}
+	return s, tx.Commit(ctx)
+}
+func (p *Postgres) BulkCreateSlots(slots []models.TimeSlot) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := lockInstructors(ctx, tx, slots); err != nil {
+		return err
+	}
+	for _, s := range slots {
+		if s.Status == "" {
+			s.Status = "open"
+		}
+		if s.Remaining == 0 {
+			s.Remaining = s.Capacity
+		}
+		var clash string
+		err := tx.QueryRow(ctx, `SELECT id::text FROM time_slots
+			WHERE instructor_id = $1::uuid AND start_at < $3 AND end_at > $2 AND id IS DISTINCT FROM NULLIF($4, '')::uuid LIMIT 1`,
+			s.InstructorID, s.StartAt, s.EndAt, s.ID).Scan(&clash)
+		if err == nil {
+			return fmt.Errorf("%w: %s at %s clashes with slot %s", ErrSlotOverlap, s.InstructorID, s.StartAt.Format(time.RFC3339), clash)
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if s.ID != "" {
+			old, err := scanSlot(tx.QueryRow(ctx, `SELECT `+slotCols+` FROM time_slots WHERE id = $1::uuid FOR UPDATE`, s.ID))
+			if err == nil {
+				if s, err = updateSlot(old, s); err != nil {
+					return err
+				}
+				if _, err := tx.Exec(ctx, `UPDATE time_slots SET instructor_id = $2, route_id = $3, start_at = $4, end_at = $5, capacity = $6, remaining = $7, status = $8, updated_at = now() WHERE id = $1::uuid`,
+					s.ID, s.InstructorID, s.RouteID, s.StartAt, s.EndAt, s.Capacity, s.Remaining, s.Status); err != nil {
+					return err
+				}
+				continue
+			}
+			if !errors.Is(err, pgx.ErrNoRows) {
+				return err
+			}
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO time_slots (id, instructor_id, route_id, start_at, end_at, capacity, remaining, status)
+			VALUES (COALESCE(NULLIF($1, '')::uuid, gen_random_uuid()), $2, $3, $4, $5, $6, $7, $8)`,
+			s.ID, s.InstructorID, s.RouteID, s.StartAt, s.EndAt, s.Capacity, s.Remaining, s.Status); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// lockInstructors serialises slot writers per instructor (in id order, so
+// batches cannot deadlock) while overlaps are checked.
+func lockInstructors(ctx context.Context, tx pgx.Tx, slots []models.TimeSlot) error {
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, s := range slots {
+		if !seen[s.InstructorID] {
+			seen[s.InstructorID] = true
+			ids = append(ids, s.InstructorID)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM instructors WHERE id = $1::uuid FOR UPDATE`, id); err != nil {
+			return notFound(err)
+		}
+	}
+	return nil
+}
+
+func (p *Postgres) CreateSlotsIfAbsent(slots []models.TimeSlot) ([]models.TimeSlot, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	if err := lockInstructors(ctx, tx, slots); err != nil {
+		return nil, err
+	}
+	out := []models.TimeSlot{}
+	for _, s := range slots {
+		created, err := scanSlot(tx.QueryRow(ctx, `INSERT INTO time_slots (instructor_id, route_id, start_at, end_at, capacity, remaining, status)
+			SELECT $1::uuid, $2::uuid, $3, $4, $5, $5, 'open'
+			WHERE NOT EXISTS (SELECT 1 FROM time_slots WHERE instructor_id = $1::uuid AND start_at < $4 AND end_at > $3)
+			RETURNING `+slotCols, s.InstructorID, s.RouteID, s.StartAt, s.EndAt, s.Capacity))
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, created)
+	}
+	return out, tx.Commit(ctx)
+}
+func (p *Postgres) SuggestedSlots(target time.Time, routeID, instructorID string, limit int) ([]models.TimeSlot, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+slotCols+` FROM time_slots
+		WHERE status = 'open' AND remaining > 0 AND start_at BETWEEN $1 AND $2
+		  AND ($3 = '' OR route_id = NULLIF($3, '')::uuid)
+		  AND ($4 = '' OR instructor_id = NULLIF($4, '')::uuid)
+		ORDER BY abs(extract(epoch FROM start_at - $5::timestamptz))
+		LIMIT $6`, target.Add(-4*time.Hour), target.Add(8*time.Hour), routeID, instructorID, target, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanSlots(rows)
+}
+func (p *Postgres) CreateBooking(b *models.Booking) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	s, err := lockSlot(ctx, tx, b.SlotID)
+	if err != nil {
+		return err
+	}
+	if err := bindSlot(b, s); err != nil {
+		return err
+	}
+	if b.HoldToken != "" {
+		h, err := scanHold(tx.QueryRow(ctx, `DELETE FROM seat_holds WHERE token = $1 RETURNING `+holdCols, b.HoldToken))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrHoldNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := applyHold(h, b, &s, time.Now().UTC()); err != nil {
+			return err
+		}
+	} else if err := takeSeats(&s, b.Participants); err != nil {
+		return err
+	}
+	if err := saveSlotSeats(ctx, tx, s); err != nil {
+		return err
+	}
+	if b.Options == nil {
+		b.Options = map[string]any{}
+	}
+	if b.OptionLines == nil {
+		b.OptionLines = []models.OptionLine{}
+	}
+	b.Status = models.BookingPending
+	err = tx.QueryRow(ctx, `INSERT INTO bookings (instructor_id, route_id, slot_id, customer_name, phone, messenger, participants, options, option_lines, promo_code, gift_code, gift_amount, price_total, price_breakdown, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''), $12, $13, $14, $15)
+		RETURNING id::text, created_at, updated_at`,
+		b.InstructorID, b.RouteID, b.SlotID, b.CustomerName, b.Phone, b.Messenger, b.Participants, b.Options, b.OptionLines, b.PromoCode, b.GiftCode, b.GiftAmount, b.PriceTotal, b.Price, b.Status,
+	).Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := reserveEquipment(ctx, tx, *b, s); err != nil {
+		return err
+	}
+	if err := useGift(ctx, tx, *b); err != nil {
+		return err
+	}
+	if err := usePromo(ctx, tx, b.PromoCode); err != nil {
+		return err
+	}
+	if err := recordStatus(ctx, tx, b.ID, "", b.Status, "created"); err != nil {
+		return err
+	}
+	if b.HoldToken != "" {
+		if _, err := tx.Exec(ctx, `UPDATE waitlist_entries SET status = $2, booking_id = $3, updated_at = now() WHERE hold_token = $1 AND status = 'offered'`,
+			b.HoldToken, models.WaitlistAccepted, b.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+func recordStatus(ctx context.Context, tx pgx.Tx, bookingID, from, to, reason string) error {
+	_, err := tx.Exec(ctx, `INSERT INTO booking_status_history (booking_id, from_status, to_status, reason) VALUES ($1, $2, $3, $4)`, bookingID, from, to, reason)
+	return err
+}
+func (p *Postgres) GetBooking(id string) (models.Booking, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	b, err := scanBooking(p.pool.QueryRow(ctx, `SELECT `+bookingCols+` FROM bookings WHERE id = $1::uuid`, id))
+	return b, notFound(err)
+}
+func (p *Postgres) ListBookingMismatches() ([]models.BookingMismatch, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `
+		SELECT b.id::text, b.slot_id::text, 'instructor_id', b.instructor_id::text, s.instructor_id::text
+		FROM bookings b JOIN time_slots s ON s.id = b.slot_id WHERE b.instructor_id <> s.instructor_id
+		UNION ALL
+		SELECT b.id::text, b.slot_id::text, 'route_id', b.route_id::text, s.route_id::text
+		FROM bookings b JOIN time_slots s ON s.id = b.slot_id WHERE b.route_id <> s.route_id
+		ORDER BY 1, 3`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.BookingMismatch{}
+	for rows.Next() {
+		var m models.BookingMismatch
+		if err := rows.Scan(&m.BookingID, &m.SlotID, &m.Field, &m.BookingValue, &m.SlotValue); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+func (p *Postgres) PatchBookingStatus(id, status, reason string) (models.Booking, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	defer tx.Rollback(ctx)
+	b, err := scanBooking(tx.QueryRow(ctx, `SELECT `+bookingCols+` FROM bookings WHERE id = $1::uuid FOR UPDATE`, id))
+	if err != nil {
+		return models.Booking{}, notFound(err)
+	}
+	if err := checkTransition(b.Status, status); err != nil {
+		return models.Booking{}, err
+	}
+	if b.Status == status {
+		return b, nil
+	}
+	if status == models.BookingCancelled {
+		if b.GiftRefund, err = releaseBooking(ctx, tx, b, 100); err != nil {
+			return models.Booking{}, err
+		}
+	}
+	if err := recordStatus(ctx, tx, b.ID, b.Status, status, reason); err != nil {
+		return models.Booking{}, err
+	}
+	b.Status = status
+	if err := tx.QueryRow(ctx, `UPDATE bookings SET status = $2, gift_refund = $3, updated_at = now() WHERE id = $1::uuid RETURNING updated_at`, b.ID, b.Status, b.GiftRefund).Scan(&b.UpdatedAt); err != nil {
+		return models.Booking{}, err
+	}
+	return b, tx.Commit(ctx)
+}
+
+func (p *Postgres) CancelBooking(id, reason string, refund models.Refund) (models.Booking, bool, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return models.Booking{}, false, err
+	}
+	defer tx.Rollback(ctx)
+	b, err := scanBooking(tx.QueryRow(ctx, `SELECT `+bookingCols+` FROM bookings WHERE id = $1::uuid FOR UPDATE`, id))
+	if err != nil {
+		return models.Booking{}, false, notFound(err)
+	}
+	if b.Status == models.BookingCancelled {
+		return b, false, nil
+	}
+	if err := checkTransition(b.Status, models.BookingCancelled); err != nil {
+		return models.Booking{}, false, err
+	}
+	if b.GiftRefund, err = releaseBooking(ctx, tx, b, refund.GiftPercent); err != nil {
+		return models.Booking{}, false, err
+	}
+	if err := recordStatus(ctx, tx, b.ID, b.Status, models.BookingCancelled, reason); err != nil {
+		return models.Booking{}, false, err
+	}
+	b.Status, b.RefundAmount, b.RefundRule = models.BookingCancelled, refund.Amount, refund.Rule
+	if err := tx.QueryRow(ctx, `UPDATE bookings SET status = $2, refund_amount = $3, refund_rule = $4, gift_refund = $5, updated_at = now() WHERE id = $1::uuid RETURNING updated_at`, b.ID, b.Status, b.RefundAmount, b.RefundRule, b.GiftRefund).Scan(&b.UpdatedAt); err != nil {
+		return models.Booking{}, false, err
+	}
+	return b, true, tx.Commit(ctx)
+}
+
+// releaseBooking gives back the seats and equipment held by a booking being
+// cancelled and giftPercent of its gift certificate amount, returning the
+// latter.
+func releaseBooking(ctx context.Context, tx pgx.Tx, b models.Booking, giftPercent int) (int, error) {
+	s, err := lockSlot(ctx, tx, b.SlotID)
+	if err != nil {
+		return 0, err
+	}
+	releaseSeats(&s, b.Participants)
+	if err := saveSlotSeats(ctx, tx, s); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM equipment_reservations WHERE booking_id = $1::uuid`, b.ID); err != nil {
+		return 0, err
+	}
+	return releaseGift(ctx, tx, b.ID, giftPercent)
+}
+
+func (p *Postgres) RescheduleBooking(id, slotID string, price *models.PriceBreakdown, giftAmount int, reason string) (models.Booking, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	defer tx.Rollback(ctx)
+	b, err := scanBooking(tx.QueryRow(ctx, `SELECT `+bookingCols+` FROM bookings WHERE id = $1::uuid FOR UPDATE`, id))
+	if err != nil {
+		return models.Booking{}, notFound(err)
+	}
+	if err := checkReschedulable(b.Status); err != nil {
+		return models.Booking{}, err
+	}
+	if sameID(slotID, b.SlotID) {
+		return b, nil
+	}
+	// Lock both slots in id order so two opposite moves cannot deadlock.
+	rows, err := tx.Query(ctx, `SELECT `+slotCols+` FROM time_slots WHERE id IN ($1::uuid, $2::uuid) ORDER BY id FOR UPDATE`, b.SlotID, slotID)
+	if err != nil {
+		return models.Booking{}, slotErr(err)
+	}
+	var from, to *models.TimeSlot
+	for rows.Next() {
+		s, err := scanSlot(rows)
+		if err != nil {
+			rows.Close()
+			return models.Booking{}, err
+		}
+		if sameID(s.ID, b.SlotID) {
+			from = &s
+		} else {
+			to = &s
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return models.Booking{}, slotErr(err)
+	}
+	if to == nil {
+		return models.Booking{}, ErrSlotUnavailable
+	}
+	if err := takeSeats(to, b.Participants); err != nil {
+		return models.Booking{}, err
+	}
+	if err := saveSlotSeats(ctx, tx, *to); err != nil {
+		return models.Booking{}, err
+	}
+	if from != nil {
+		releaseSeats(from, b.Participants)
+		if err := saveSlotSeats(ctx, tx, *from); err != nil {
+			return models.Booking{}, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO booking_status_history (booking_id, from_status, to_status, from_slot_id, to_slot_id, reason) VALUES ($1, $2, $2, $3, $4, $5)`,
+		b.ID, b.Status, b.SlotID, to.ID, reason); err != nil {
+		return models.Booking{}, err
+	}
+	if err := reserveEquipment(ctx, tx, b, *to); err != nil {
+		return models.Booking{}, err
+	}
+	if price != nil {
+		b.PriceTotal = price.Total
+		b.Price = price
+		if giftAmount < b.GiftAmount {
+			if err := shrinkGift(ctx, tx, b.ID, giftAmount); err != nil {
+				return models.Booking{}, err
+			}
+			b.GiftAmount = max(giftAmount, 0)
+		}
+	}
+	b, err = scanBooking(tx.QueryRow(ctx, `UPDATE bookings SET slot_id = $2, instructor_id = $3, route_id = $4, price_total = $5, price_breakdown = $6, gift_amount = $7, updated_at = now()
+		WHERE id = $1::uuid RETURNING `+bookingCols, b.ID, to.ID, to.InstructorID, to.RouteID, b.PriceTotal, b.Price, b.GiftAmount))
+	if err != nil {
+		return models.Booking{}, err
+	}
+	return b, tx.Commit(ctx)
+}
+func (p *Postgres) ListBookingsStarting(from, to time.Time, status string) ([]models.Booking, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+bookingCols+` FROM bookings
+		WHERE status = $1 AND slot_id IN (SELECT id FROM time_slots WHERE start_at >= $2 AND start_at < $3)
+		ORDER BY (SELECT start_at FROM time_slots WHERE time_slots.id = bookings.slot_id), created_at`, status, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return scanBookings(rows)
+}
+func (p *Postgres) ListSlotBookings(slotIDs []string) ([]models.Booking, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+bookingCols+` FROM bookings WHERE slot_id = ANY($1::uuid[]) ORDER BY created_at`, slotIDs)
+	if err != nil {
+		return nil, err
+	}
+	return scanBookings(rows)
+}
+func (p *Postgres) ListBookingHistory(id string) ([]models.BookingStatusChange, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	if _, err := p.GetBooking(id); err != nil {
+		return nil, err
+	}
+	rows, err := p.pool.Query(ctx, `SELECT id::text, booking_id::text, from_status, to_status, COALESCE(from_slot_id::text, ''), COALESCE(to_slot_id::text, ''), reason, created_at
+		FROM booking_status_history WHERE booking_id = $1::uuid ORDER BY created_at, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.BookingStatusChange{}
+	for rows.Next() {
+		var c models.BookingStatusChange
+		if err := rows.Scan(&c.ID, &c.BookingID, &c.From, &c.To, &c.FromSlotID, &c.ToSlotID, &c.Reason, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+const holdCols = `token, slot_id::text, seats, expires_at, created_at`
+
+func scanHold(row scanner) (models.SeatHold, error) {
+	var h models.SeatHold
+	err := row.Scan(&h.Token, &h.SlotID, &h.Seats, &h.ExpiresAt, &h.CreatedAt)
+	return h, err
+}
+
+func (p *Postgres) CreateHold(slotID string, seats int, ttl time.Duration) (models.SeatHold, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return models.SeatHold{}, err
+	}
+	defer tx.Rollback(ctx)
+	s, err := lockSlot(ctx, tx, slotID)
+	if err != nil {
+		return models.SeatHold{}, err
+	}
+	if err := takeSeats(&s, seats); err != nil {
+		return models.SeatHold{}, err
+	}
+	if err := saveSlotSeats(ctx, tx, s); err != nil {
+		return models.SeatHold{}, err
+	}
+	h, err := insertHold(ctx, tx, s.ID, seats, ttl)
+	if err != nil {
+		return models.SeatHold{}, err
+	}
+	return h, tx.Commit(ctx)
+}
+func insertHold(ctx context.Context, tx pgx.Tx, slotID string, seats int, ttl time.Duration) (models.SeatHold, error) {
+	return scanHold(tx.QueryRow(ctx, `INSERT INTO seat_holds (token, slot_id, seats, expires_at) VALUES ($1, $2, $3, $4) RETURNING `+holdCols,
+		id(), slotID, seats, time.Now().UTC().Add(ttl)))
+}
+func (p *Postgres) GetHold(token string) (models.SeatHold, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	h, err := scanHold(p.pool.QueryRow(ctx, `SELECT `+holdCols+` FROM seat_holds WHERE token = $1`, token))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return h, ErrHoldNotFound
+	}
+	return h, err
+}
+func (p *Postgres) ReleaseHold(token string) error {
+	released, err := p.releaseHolds(`token = $1`, token)
+	if err == nil && len(released) == 0 {
+		return ErrHoldNotFound
+	}
+	return err
+}
+func (p *Postgres) ReleaseExpiredHolds(now time.Time) ([]models.SeatHold, error) {
+	return p.releaseHolds(`expires_at <= $1`, now)
+}
+
+// releaseHolds deletes the holds matched by the where condition on $1 and
+// returns their seats to the slots in the same transaction. The slots are
+// locked first, in id order, and only holds of locked slots are deleted, so
+// it takes locks in the same order as CreateBooking and the waitlist.
+func (p *Postgres) releaseHolds(where string, arg any) ([]models.SeatHold, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	rows, err := tx.Query(ctx, `SELECT DISTINCT slot_id::text FROM seat_holds WHERE `+where+` ORDER BY 1`, arg)
+	if err != nil {
+		return nil, err
+	}
+	slotIDs := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		slotIDs = append(slotIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slots := map[string]models.TimeSlot{}
+	for _, id := range slotIDs {
+		s, err := lockSlot(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		slots[id] = s
+	}
+	rows, err = tx.Query(ctx, `DELETE FROM seat_holds WHERE (`+where+`) AND slot_id = ANY($2::uuid[]) RETURNING `+holdCols, arg, slotIDs)
+	if err != nil {
+		return nil, err
+	}
+	out := []models.SeatHold{}
+	for rows.Next() {
+		h, err := scanHold(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].SlotID < out[b].SlotID })
+	tokens := make([]string, 0, len(out))
+	for _, h := range out {
+		tokens = append(tokens, h.Token)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE waitlist_entries SET status = $2, updated_at = now() WHERE hold_token = ANY($1) AND status = 'offered'`,
+		tokens, models.WaitlistExpired); err != nil {
+		return nil, err
+	}
+	for _, h := range out {
+		s := slots[h.SlotID]
+		releaseSeats(&s, h.Seats)
+		slots[h.SlotID] = s
+	}
+	for _, id := range slotIDs {
+		if err := saveSlotSeats(ctx, tx, slots[id]); err != nil {
+			return nil, err
+		}
+	}
+	return out, tx.Commit(ctx)
+}
+func (p *Postgres) ReserveIdempotencyKey(key, requestHash string, retention time.Duration) (models.IdempotencyKey, bool, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	var k models.IdempotencyKey
+	scan := func(row pgx.Row) error {
+		return row.Scan(&k.Key, &k.RequestHash, &k.BookingID, &k.CreatedAt, &k.ExpiresAt)
+	}
+	err := scan(p.pool.QueryRow(ctx, `INSERT INTO idempotency_keys (key, request_hash, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET request_hash = EXCLUDED.request_hash, booking_id = NULL, created_at = now(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= now()
+		RETURNING key, request_hash, COALESCE(booking_id::text, ''), created_at, expires_at`, key, requestHash, time.Now().UTC().Add(retention)))
+	if err == nil {
+		return k, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return k, false, err
+	}
+	err = scan(p.pool.QueryRow(ctx, `SELECT key, request_hash, COALESCE(booking_id::text, ''), created_at, expires_at FROM idempotency_keys WHERE key = $1`, key))
+	return k, false, err
+}
+func (p *Postgres) CompleteIdempotencyKey(key, bookingID string) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tag, err := p.pool.Exec(ctx, `UPDATE idempotency_keys SET booking_id = $2::uuid WHERE key = $1`, key, bookingID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+func (p *Postgres) ReleaseIdempotencyKey(key string) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	_, err := p.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE key = $1`, key)
+	return err
+}
+func (p *Postgres) PurgeIdempotencyKeys(now time.Time) (int, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tag, err := p.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+	return int(tag.RowsAffected()), err
+}
+func (p *Postgres) FindWeatherSnapshot(lat, lng float64, timeFrom time.Time, ttl time.Duration) (models.WeatherSnapshot, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	w, err := scanWeather(p.pool.QueryRow(ctx, `SELECT `+weatherCols+` FROM weather_snapshots
+		WHERE location_lat = $1 AND location_lng = $2 AND time_from = $3 AND fetched_at > $4
+		ORDER BY fetched_at DESC LIMIT 1`, lat, lng, timeFrom, time.Now().UTC().Add(-ttl)))
+	return w, notFound(err)
+}
+func (p *Postgres) SaveWeatherSnapshot(s *models.WeatherSnapshot) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	if s.Raw == nil {
+		s.Raw = map[string]any{}
+	}
+	return p.pool.QueryRow(ctx, `INSERT INTO weather_snapshots (location_lat, location_lng, time_from, time_to, temperature, wind_speed, wind_gusts, wind_direction, precipitation, cloud_cover, wave_height, wave_period, sea_temperature, provider, conditions_level, score, raw, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		RETURNING id::text, created_at, updated_at`,
+		s.LocationLat, s.LocationLng, s.TimeFrom, s.TimeTo, s.Temperature, s.WindSpeed, s.WindGusts, s.WindDirection, s.Precipitation, s.CloudCover, s.WaveHeight, s.WavePeriod, s.SeaTemperature, s.Provider, s.ConditionsLevel, s.Score, s.Raw, s.FetchedAt,
+	).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+}
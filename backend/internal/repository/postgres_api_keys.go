@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"sup-anapa/backend/internal/models"
+)
+
+const apiKeyCols = `id::text, name, role, COALESCE(instructor_id::text, ''), key_hash, created_at, revoked_at`
+
+func scanAPIKey(row scanner) (models.APIKey, error) {
+	var k models.APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.Role, &k.InstructorID, &k.KeyHash, &k.CreatedAt, &k.RevokedAt)
+	return k, err
+}
+
+func (p *Postgres) CreateAPIKey(k *models.APIKey) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	got, err := scanAPIKey(p.pool.QueryRow(ctx, `INSERT INTO api_keys (name, role, instructor_id, key_hash) VALUES ($1, $2, NULLIF($3, '')::uuid, $4) RETURNING `+apiKeyCols,
+		k.Name, k.Role, k.InstructorID, k.KeyHash))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return notFound(err)
+	}
+	*k = got
+	return nil
+}
+func (p *Postgres) FindAPIKey(hash string) (models.APIKey, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	k, err := scanAPIKey(p.pool.QueryRow(ctx, `SELECT `+apiKeyCols+` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`, hash))
+	return k, notFound(err)
+}
+func (p *Postgres) ListAPIKeys() ([]models.APIKey, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+apiKeyCols+` FROM api_keys ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+func (p *Postgres) RevokeAPIKey(id string) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tag, err := p.pool.Exec(ctx, `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1::uuid`, id)
+	if err != nil {
+		return notFound(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"sup-anapa/backend/internal/models"
+)
+
+const giftCols = `code, kind, amount, balance, COALESCE(route_id::text, ''), participants, COALESCE(recipient, ''), COALESCE(note, ''), status, expires_at, created_at, updated_at`
+
+func scanGift(row scanner) (models.GiftCertificate, error) {
+	var g models.GiftCertificate
+	err := row.Scan(&g.Code, &g.Kind, &g.Amount, &g.Balance, &g.RouteID, &g.Participants, &g.Recipient, &g.Note, &g.Status, &g.ExpiresAt, &g.CreatedAt, &g.UpdatedAt)
+	return g, err
+}
+
+// CreateGift retries on the unlikely clash of a generated code.
+func (p *Postgres) CreateGift(g *models.GiftCertificate) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	for {
+		got, err := scanGift(p.pool.QueryRow(ctx, `INSERT INTO gift_certificates (code, kind, amount, balance, route_id, participants, recipient, note, expires_at)
+			VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, $6, NULLIF($7, ''), NULLIF($8, ''), $9)
+			ON CONFLICT (code) DO NOTHING RETURNING `+giftCols,
+			giftCode(), g.Kind, g.Amount, g.Balance, g.RouteID, g.Participants, g.Recipient, g.Note, g.ExpiresAt))
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				return ErrNotFound
+			}
+			return notFound(err)
+		}
+		*g = got
+		return nil
+	}
+}
+func (p *Postgres) GetGift(code string) (models.GiftCertificate, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	g, err := scanGift(p.pool.QueryRow(ctx, `SELECT `+giftCols+` FROM gift_certificates WHERE code = $1`, code))
+	return g, notFound(err)
+}
+func (p *Postgres) ListGifts(status string) ([]models.GiftCertificate, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+giftCols+` FROM gift_certificates WHERE ($1 = '' OR status = $1) ORDER BY created_at DESC`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.GiftCertificate{}
+	for rows.Next() {
+		g, err := scanGift(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+func (p *Postgres) VoidGift(code string) (models.GiftCertificate, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	g, err := scanGift(p.pool.QueryRow(ctx, `UPDATE gift_certificates SET status = $2, updated_at = now()
+		WHERE code = $1 AND status = $3 RETURNING `+giftCols, code, models.GiftVoided, models.GiftActive))
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return g, err
+	}
+	if g, err = p.GetGift(code); err != nil {
+		return g, err
+	}
+	return g, ErrGiftClosed
+}
+func (p *Postgres) ListGiftRedemptions(code string) ([]models.GiftRedemption, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT booking_id::text, code, amount, created_at FROM gift_redemptions WHERE code = $1 ORDER BY created_at`, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.GiftRedemption{}
+	for rows.Next() {
+		var red models.GiftRedemption
+		if err := rows.Scan(&red.BookingID, &red.Code, &red.Amount, &red.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, red)
+	}
+	return out, rows.Err()
+}
+
+// useGift spends the booking's gift amount; the certificate row is locked
+// so two bookings cannot spend the same balance.
+func useGift(ctx context.Context, tx pgx.Tx, b models.Booking) error {
+	if b.GiftCode == "" {
+		return nil
+	}
+	g, err := scanGift(tx.QueryRow(ctx, `SELECT `+giftCols+` FROM gift_certificates WHERE code = $1 FOR UPDATE`, b.GiftCode))
+	if err != nil {
+		return notFound(err)
+	}
+	if err := spendGift(&g, b.GiftAmount, time.Now()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE gift_certificates SET balance = $2, status = $3, updated_at = now() WHERE code = $1`, g.Code, g.Balance, g.Status); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO gift_redemptions (booking_id, code, amount) VALUES ($1::uuid, $2, $3)`, b.ID, g.Code, b.GiftAmount)
+	return err
+}
+
+// shrinkGift lowers the booking's redemption to amount and gives the
+// difference back to the certificate.
+func shrinkGift(ctx context.Context, tx pgx.Tx, bookingID string, amount int) error {
+	var red models.GiftRedemption
+	err := tx.QueryRow(ctx, `SELECT code, amount FROM gift_redemptions WHERE booking_id = $1::uuid FOR UPDATE`, bookingID).Scan(&red.Code, &red.Amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if amount >= red.Amount {
+		return nil
+	}
+	g, err := scanGift(tx.QueryRow(ctx, `SELECT `+giftCols+` FROM gift_certificates WHERE code = $1 FOR UPDATE`, red.Code))
+	if err != nil {
+		return err
+	}
+	returnGiftPart(&g, red.Amount-amount)
+	if _, err := tx.Exec(ctx, `UPDATE gift_certificates SET balance = $2, status = $3, updated_at = now() WHERE code = $1`, g.Code, g.Balance, g.Status); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE gift_redemptions SET amount = $2 WHERE booking_id = $1::uuid`, bookingID, amount)
+	return err
+}
+
+func releaseGift(ctx context.Context, tx pgx.Tx, bookingID string, percent int) (int, error) {
+	var red models.GiftRedemption
+	err := tx.QueryRow(ctx, `DELETE FROM gift_redemptions WHERE booking_id = $1::uuid RETURNING code, amount`, bookingID).Scan(&red.Code, &red.Amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	g, err := scanGift(tx.QueryRow(ctx, `SELECT `+giftCols+` FROM gift_certificates WHERE code = $1 FOR UPDATE`, red.Code))
+	if err != nil {
+		return 0, err
+	}
+	back := refundGift(&g, red.Amount, percent)
+	_, err = tx.Exec(ctx, `UPDATE gift_certificates SET balance = $2, status = $3, updated_at = now() WHERE code = $1`, g.Code, g.Balance, g.Status)
+	return back, err
+}
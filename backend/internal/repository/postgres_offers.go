@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"sup-anapa/backend/internal/models"
+)
+
+const offerCols = `id::text, booking_id::text, token, slot_ids::text[], conditions_level, score, explanation, status, COALESCE(chosen_slot_id::text, ''), expires_at, created_at, updated_at`
+
+func scanOffer(row scanner) (models.RescheduleOffer, error) {
+	var o models.RescheduleOffer
+	err := row.Scan(&o.ID, &o.BookingID, &o.Token, &o.SlotIDs, &o.ConditionsLevel, &o.Score, &o.Explanation, &o.Status, &o.ChosenSlotID, &o.ExpiresAt, &o.CreatedAt, &o.UpdatedAt)
+	return o, err
+}
+
+func (p *Postgres) CreateRescheduleOffer(o *models.RescheduleOffer) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	got, err := scanOffer(p.pool.QueryRow(ctx, `INSERT INTO reschedule_offers (booking_id, token, slot_ids, conditions_level, score, explanation, status, expires_at)
+		VALUES ($1::uuid, $2, $3::uuid[], $4, $5, $6, $7, $8) RETURNING `+offerCols,
+		o.BookingID, id(), o.SlotIDs, o.ConditionsLevel, o.Score, o.Explanation, models.OfferPending, o.ExpiresAt))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrOfferExists
+		}
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return notFound(err)
+	}
+	*o = got
+	return nil
+}
+func (p *Postgres) GetRescheduleOffer(token string) (models.RescheduleOffer, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	o, err := scanOffer(p.pool.QueryRow(ctx, `SELECT `+offerCols+` FROM reschedule_offers WHERE token = $1`, token))
+	return o, notFound(err)
+}
+func (p *Postgres) ListRescheduleOffers(status string) ([]models.RescheduleOffer, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+offerCols+` FROM reschedule_offers WHERE $1 = '' OR status = $1 ORDER BY created_at`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.RescheduleOffer{}
+	for rows.Next() {
+		o, err := scanOffer(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+func (p *Postgres) SetRescheduleOfferStatus(token, from, to, slotID string) (models.RescheduleOffer, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	o, err := scanOffer(p.pool.QueryRow(ctx, `UPDATE reschedule_offers SET status = $3, chosen_slot_id = NULLIF($4, '')::uuid, updated_at = now()
+		WHERE token = $1 AND status = $2 RETURNING `+offerCols, token, from, to, slotID))
+	if errors.Is(notFound(err), ErrNotFound) {
+		if _, err := p.GetRescheduleOffer(token); err != nil {
+			return models.RescheduleOffer{}, err
+		}
+		return models.RescheduleOffer{}, ErrOfferClosed
+	}
+	return o, err
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"sup-anapa/backend/internal/models"
+)
+
+const optionCols = `code, title, price, price_type, COALESCE(equipment, ''), route_ids, is_active, created_at, updated_at`
+
+func scanOption(row scanner) (models.Option, error) {
+	var o models.Option
+	err := row.Scan(&o.Code, &o.Title, &o.Price, &o.PriceType, &o.Equipment, &o.RouteIDs, &o.IsActive, &o.CreatedAt, &o.UpdatedAt)
+	return o, err
+}
+
+func (p *Postgres) ListOptions() ([]models.Option, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+optionCols+` FROM options ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.Option{}
+	for rows.Next() {
+		o, err := scanOption(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+func (p *Postgres) GetOption(code string) (models.Option, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	o, err := scanOption(p.pool.QueryRow(ctx, `SELECT `+optionCols+` FROM options WHERE code = $1`, code))
+	return o, notFound(err)
+}
+func (p *Postgres) UpsertOption(o *models.Option) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	if o.RouteIDs == nil {
+		o.RouteIDs = []string{}
+	}
+	got, err := scanOption(p.pool.QueryRow(ctx, `INSERT INTO options (code, title, price, price_type, equipment, route_ids, is_active)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+		ON CONFLICT (code) DO UPDATE SET title = EXCLUDED.title, price = EXCLUDED.price, price_type = EXCLUDED.price_type,
+			equipment = EXCLUDED.equipment, route_ids = EXCLUDED.route_ids, is_active = EXCLUDED.is_active, updated_at = now()
+		RETURNING `+optionCols, o.Code, o.Title, o.Price, o.PriceType, o.Equipment, o.RouteIDs, o.IsActive))
+	if err != nil {
+		return err
+	}
+	*o = got
+	return nil
+}
+func (p *Postgres) DeleteOption(code string) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tag, err := p.pool.Exec(ctx, `DELETE FROM options WHERE code = $1`, code)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"sup-anapa/backend/internal/models"
+)
+
+const paymentCols = `id::text, booking_id::text, provider, provider_ref, mode, amount, refunded, status, COALESCE(confirmation_url, ''), created_at, updated_at`
+
+func scanPayment(row scanner) (models.Payment, error) {
+	var p models.Payment
+	err := row.Scan(&p.ID, &p.BookingID, &p.Provider, &p.ProviderRef, &p.Mode, &p.Amount, &p.Refunded, &p.Status, &p.ConfirmationURL, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+func (p *Postgres) CreatePayment(pay *models.Payment) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	got, err := scanPayment(p.pool.QueryRow(ctx, `INSERT INTO payments (booking_id, provider, provider_ref, mode, amount, status, confirmation_url)
+		VALUES ($1::uuid, $2, $3, $4, $5, $6, NULLIF($7, '')) RETURNING `+paymentCols,
+		pay.BookingID, pay.Provider, pay.ProviderRef, pay.Mode, pay.Amount, pay.Status, pay.ConfirmationURL))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return notFound(err)
+	}
+	*pay = got
+	return nil
+}
+func (p *Postgres) GetPayment(id string) (models.Payment, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	pay, err := scanPayment(p.pool.QueryRow(ctx, `SELECT `+paymentCols+` FROM payments WHERE id = $1::uuid`, id))
+	return pay, notFound(err)
+}
+func (p *Postgres) FindPayment(provider, ref string) (models.Payment, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	pay, err := scanPayment(p.pool.QueryRow(ctx, `SELECT `+paymentCols+` FROM payments WHERE provider = $1 AND provider_ref = $2`, provider, ref))
+	return pay, notFound(err)
+}
+func (p *Postgres) ListBookingPayments(bookingID string) ([]models.Payment, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+paymentCols+` FROM payments WHERE booking_id = $1::uuid ORDER BY created_at`, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.Payment{}
+	for rows.Next() {
+		pay, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, pay)
+	}
+	return out, rows.Err()
+}
+func (p *Postgres) SetPaymentStatus(id, from, to string) (models.Payment, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	pay, err := scanPayment(p.pool.QueryRow(ctx, `UPDATE payments SET status = $3, updated_at = now()
+		WHERE id = $1::uuid AND status = $2 RETURNING `+paymentCols, id, from, to))
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return pay, notFound(err)
+	}
+	if pay, err = p.GetPayment(id); err != nil {
+		return pay, err
+	}
+	return pay, ErrPaymentClosed
+}
+func (p *Postgres) AddPaymentRefund(id string, amount int) (models.Payment, error) {
+	return p.changeRefund(id, amount, addRefund)
+}
+func (p *Postgres) UndoPaymentRefund(id string, amount int) (models.Payment, error) {
+	return p.changeRefund(id, amount, undoRefund)
+}
+func (p *Postgres) changeRefund(id string, amount int, change func(*models.Payment, int) error) (models.Payment, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	defer tx.Rollback(ctx)
+	pay, err := scanPayment(tx.QueryRow(ctx, `SELECT `+paymentCols+` FROM payments WHERE id = $1::uuid FOR UPDATE`, id))
+	if err != nil {
+		return pay, notFound(err)
+	}
+	current := pay
+	if err := change(&pay, amount); err != nil {
+		return current, err
+	}
+	if err := tx.QueryRow(ctx, `UPDATE payments SET refunded = $2, status = $3, updated_at = now() WHERE id = $1::uuid RETURNING updated_at`,
+		pay.ID, pay.Refunded, pay.Status).Scan(&pay.UpdatedAt); err != nil {
+		return pay, err
+	}
+	return pay, tx.Commit(ctx)
+}
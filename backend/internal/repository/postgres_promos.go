@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"sup-anapa/backend/internal/models"
+)
+
+const promoCols = `code, kind, value, valid_from, valid_to, max_uses, used, min_participants, route_ids, instructor_ids, is_active, created_at, updated_at`
+
+func scanPromo(row scanner) (models.PromoCode, error) {
+	var p models.PromoCode
+	err := row.Scan(&p.Code, &p.Kind, &p.Value, &p.ValidFrom, &p.ValidTo, &p.MaxUses, &p.Used, &p.MinParticipants, &p.RouteIDs, &p.InstructorIDs, &p.IsActive, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+func (p *Postgres) ListPromoCodes() ([]models.PromoCode, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+promoCols+` FROM promo_codes ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.PromoCode{}
+	for rows.Next() {
+		promo, err := scanPromo(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, promo)
+	}
+	return out, rows.Err()
+}
+func (p *Postgres) GetPromoCode(code string) (models.PromoCode, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	promo, err := scanPromo(p.pool.QueryRow(ctx, `SELECT `+promoCols+` FROM promo_codes WHERE code = $1`, code))
+	return promo, notFound(err)
+}
+func (p *Postgres) UpsertPromoCode(promo *models.PromoCode) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	if promo.RouteIDs == nil {
+		promo.RouteIDs = []string{}
+	}
+	if promo.InstructorIDs == nil {
+		promo.InstructorIDs = []string{}
+	}
+	got, err := scanPromo(p.pool.QueryRow(ctx, `INSERT INTO promo_codes (code, kind, value, valid_from, valid_to, max_uses, min_participants, route_ids, instructor_ids, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (code) DO UPDATE SET kind = EXCLUDED.kind, value = EXCLUDED.value, valid_from = EXCLUDED.valid_from, valid_to = EXCLUDED.valid_to,
+			max_uses = EXCLUDED.max_uses, min_participants = EXCLUDED.min_participants, route_ids = EXCLUDED.route_ids,
+			instructor_ids = EXCLUDED.instructor_ids, is_active = EXCLUDED.is_active, updated_at = now()
+		RETURNING `+promoCols,
+		promo.Code, promo.Kind, promo.Value, promo.ValidFrom, promo.ValidTo, promo.MaxUses, promo.MinParticipants, promo.RouteIDs, promo.InstructorIDs, promo.IsActive))
+	if err != nil {
+		return err
+	}
+	*promo = got
+	return nil
+}
+func (p *Postgres) DeletePromoCode(code string) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tag, err := p.pool.Exec(ctx, `DELETE FROM promo_codes WHERE code = $1`, code)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// usePromo counts one use of the code; the conditional update keeps
+// concurrent bookings from going past max_uses.
+func usePromo(ctx context.Context, tx pgx.Tx, code string) error {
+	if code == "" {
+		return nil
+	}
+	var used int
+	err := tx.QueryRow(ctx, `UPDATE promo_codes SET used = used + 1, updated_at = now()
+		WHERE code = $1 AND (max_uses = 0 OR used < max_uses) RETURNING used`, code).Scan(&used)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM promo_codes WHERE code = $1)`, code).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrPromoExhausted
+}
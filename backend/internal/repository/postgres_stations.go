@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"sup-anapa/backend/internal/models"
+)
+
+const stationCols = `id::text, title, stock, created_at, updated_at`
+
+func scanStation(row scanner) (models.Station, error) {
+	var st models.Station
+	err := row.Scan(&st.ID, &st.Title, &st.Stock, &st.CreatedAt, &st.UpdatedAt)
+	return st, err
+}
+
+type queryer interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func (p *Postgres) ListStations() ([]models.Station, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+stationCols+` FROM stations ORDER BY title`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.Station{}
+	for rows.Next() {
+		st, err := scanStation(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
+func (p *Postgres) GetStation(id string) (models.Station, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	st, err := scanStation(p.pool.QueryRow(ctx, `SELECT `+stationCols+` FROM stations WHERE id = $1::uuid`, id))
+	return st, notFound(err)
+}
+func (p *Postgres) UpsertStation(st *models.Station) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	if st.Stock == nil {
+		st.Stock = map[string]int{}
+	}
+	got, err := scanStation(p.pool.QueryRow(ctx, `INSERT INTO stations (id, title, stock)
+		VALUES (COALESCE(NULLIF($1, '')::uuid, gen_random_uuid()), $2, $3)
+		ON CONFLICT (id) DO UPDATE SET title = EXCLUDED.title, stock = EXCLUDED.stock, updated_at = now()
+		RETURNING `+stationCols, st.ID, st.Title, st.Stock))
+	if err != nil {
+		return notFound(err)
+	}
+	*st = got
+	return nil
+}
+func (p *Postgres) FreeEquipment(stationID string, from, to time.Time) (map[string]int, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	st, err := scanStation(p.pool.QueryRow(ctx, `SELECT `+stationCols+` FROM stations WHERE id = $1::uuid`, stationID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	reserved, err := reservedEquipment(ctx, p.pool, st.ID, from, to, "")
+	if err != nil {
+		return nil, err
+	}
+	return freeStock(st, reserved), nil
+}
+
+func reservedEquipment(ctx context.Context, q queryer, stationID string, from, to time.Time, exceptBooking string) (map[string]int, error) {
+	rows, err := q.Query(ctx, `SELECT kind, SUM(quantity) FROM equipment_reservations
+		WHERE station_id = $1::uuid AND start_at < $3 AND end_at > $2 AND booking_id::text <> $4
+		GROUP BY kind`, stationID, from, to, exceptBooking)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]int{}
+	for rows.Next() {
+		var kind string
+		var n int
+		if err := rows.Scan(&kind, &n); err != nil {
+			return nil, err
+		}
+		out[kind] = n
+	}
+	return out, rows.Err()
+}
+
+// reserveEquipment replaces the reservations of booking b with what it needs
+// in slot s. The station row is locked so concurrent bookings cannot take
+// the same pieces.
+func reserveEquipment(ctx context.Context, tx pgx.Tx, b models.Booking, s models.TimeSlot) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM equipment_reservations WHERE booking_id = $1::uuid`, b.ID); err != nil {
+		return err
+	}
+	st, err := scanStation(tx.QueryRow(ctx, `SELECT `+stationCols+` FROM stations
+		WHERE id = (SELECT station_id FROM routes WHERE id = $1::uuid) FOR UPDATE`, s.RouteID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	reserved, err := reservedEquipment(ctx, tx, st.ID, s.StartAt, s.EndAt, b.ID)
+	if err != nil {
+		return err
+	}
+	plan, err := planReservations(b, s, st, reserved)
+	if err != nil {
+		return err
+	}
+	for _, e := range plan {
+		if _, err := tx.Exec(ctx, `INSERT INTO equipment_reservations (booking_id, station_id, kind, quantity, start_at, end_at)
+			VALUES ($1::uuid, $2::uuid, $3, $4, $5, $6)`, e.BookingID, e.StationID, e.Kind, e.Quantity, e.StartAt, e.EndAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"sup-anapa/backend/internal/models"
+)
+
+const templateCols = `id::text, instructor_id::text, route_id::text, weekdays, times, capacity, COALESCE(valid_from::text, ''), COALESCE(valid_to::text, ''), timezone, is_active, created_at, updated_at`
+
+func scanTemplate(row scanner) (models.ScheduleTemplate, error) {
+	var t models.ScheduleTemplate
+	err := row.Scan(&t.ID, &t.InstructorID, &t.RouteID, &t.Weekdays, &t.Times, &t.Capacity, &t.ValidFrom, &t.ValidTo, &t.Timezone, &t.IsActive, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+func (p *Postgres) ListTemplates() ([]models.ScheduleTemplate, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+templateCols+` FROM schedule_templates ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []models.ScheduleTemplate{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+func (p *Postgres) GetTemplate(id string) (models.ScheduleTemplate, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	t, err := scanTemplate(p.pool.QueryRow(ctx, `SELECT `+templateCols+` FROM schedule_templates WHERE id = $1::uuid`, id))
+	return t, notFound(err)
+}
+func (p *Postgres) UpsertTemplate(t *models.ScheduleTemplate) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	got, err := scanTemplate(p.pool.QueryRow(ctx, `INSERT INTO schedule_templates (id, instructor_id, route_id, weekdays, times, capacity, valid_from, valid_to, timezone, is_active)
+		VALUES (COALESCE(NULLIF($1, '')::uuid, gen_random_uuid()), $2::uuid, $3::uuid, $4, $5, $6, NULLIF($7, '')::date, NULLIF($8, '')::date, $9, $10)
+		ON CONFLICT (id) DO UPDATE SET instructor_id = EXCLUDED.instructor_id, route_id = EXCLUDED.route_id, weekdays = EXCLUDED.weekdays, times = EXCLUDED.times,
+			capacity = EXCLUDED.capacity, valid_from = EXCLUDED.valid_from, valid_to = EXCLUDED.valid_to, timezone = EXCLUDED.timezone,
+			is_active = EXCLUDED.is_active, updated_at = now()
+		RETURNING `+templateCols,
+		t.ID, t.InstructorID, t.RouteID, t.Weekdays, t.Times, t.Capacity, t.ValidFrom, t.ValidTo, t.Timezone, t.IsActive))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return notFound(err)
+	}
+	*t = got
+	return nil
+}
+func (p *Postgres) DeleteTemplate(id string) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tag, err := p.pool.Exec(ctx, `DELETE FROM schedule_templates WHERE id = $1::uuid`, id)
+	if err != nil {
+		return notFound(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"sup-anapa/backend/internal/db"
+	"sup-anapa/backend/internal/repository"
+	"sup-anapa/backend/internal/repository/storetest"
+)
+
+// TestPostgres runs the conformance suite against DATABASE_URL, which must
+// point at a migrated database. Every case creates its own rows, so a
+// seeded development database works too.
+func TestPostgres(t *testing.T) {
+	url := os.Getenv("DATABASE_URL")
+	if url == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	pool, err := db.Connect(context.Background(), url, 10)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer pool.Close()
+	store := repository.NewPostgres(pool)
+	storetest.Run(t, func(t *testing.T) repository.Repository { return store })
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"sup-anapa/backend/internal/models"
+)
+
+const waitlistCols = `id::text, slot_id::text, customer_name, phone, messenger, participants, status, COALESCE(hold_token, ''), offer_expires_at, COALESCE(booking_id::text, ''), created_at, updated_at`
+
+func scanWaitlist(row scanner) (models.WaitlistEntry, error) {
+	var e models.WaitlistEntry
+	err := row.Scan(&e.ID, &e.SlotID, &e.CustomerName, &e.Phone, &e.Messenger, &e.Participants, &e.Status, &e.HoldToken, &e.OfferExpiresAt, &e.BookingID, &e.CreatedAt, &e.UpdatedAt)
+	return e, err
+}
+
+func scanWaitlistRows(rows pgx.Rows) ([]models.WaitlistEntry, error) {
+	defer rows.Close()
+	out := []models.WaitlistEntry{}
+	for rows.Next() {
+		e, err := scanWaitlist(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (p *Postgres) JoinWaitlist(e *models.WaitlistEntry) error {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	got, err := scanWaitlist(p.pool.QueryRow(ctx, `INSERT INTO waitlist_entries (slot_id, customer_name, phone, messenger, participants, status)
+		VALUES ($1::uuid, $2, $3, $4, $5, $6) RETURNING `+waitlistCols,
+		e.SlotID, e.CustomerName, e.Phone, e.Messenger, e.Participants, models.WaitlistWaiting))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return notFound(err)
+	}
+	*e = got
+	return nil
+}
+func (p *Postgres) GetWaitlistEntry(id string) (models.WaitlistEntry, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	e, err := scanWaitlist(p.pool.QueryRow(ctx, `SELECT `+waitlistCols+` FROM waitlist_entries WHERE id = $1::uuid`, id))
+	return e, notFound(err)
+}
+func (p *Postgres) ListWaitlist(slotID string) ([]models.WaitlistEntry, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	rows, err := p.pool.Query(ctx, `SELECT `+waitlistCols+` FROM waitlist_entries
+		WHERE $1 = '' OR slot_id = NULLIF($1, '')::uuid ORDER BY created_at`, slotID)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return scanWaitlistRows(rows)
+}
+func (p *Postgres) CancelWaitlistEntry(id string) (models.WaitlistEntry, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	defer tx.Rollback(ctx)
+	s, e, err := lockSlotEntry(ctx, tx, id)
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	if e.Status != models.WaitlistWaiting && e.Status != models.WaitlistOffered {
+		return models.WaitlistEntry{}, ErrWaitlistClosed
+	}
+	if e.Status == models.WaitlistOffered {
+		h, err := scanHold(tx.QueryRow(ctx, `DELETE FROM seat_holds WHERE token = $1 RETURNING `+holdCols, e.HoldToken))
+		if err == nil {
+			releaseSeats(&s, h.Seats)
+			if err := saveSlotSeats(ctx, tx, s); err != nil {
+				return models.WaitlistEntry{}, err
+			}
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return models.WaitlistEntry{}, err
+		}
+	}
+	e, err = scanWaitlist(tx.QueryRow(ctx, `UPDATE waitlist_entries SET status = $2, updated_at = now() WHERE id = $1::uuid RETURNING `+waitlistCols, id, models.WaitlistCancelled))
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	return e, tx.Commit(ctx)
+}
+func (p *Postgres) OfferWaitlist(slotID string, ttl time.Duration) ([]models.WaitlistEntry, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	s, err := lockSlot(ctx, tx, slotID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, `SELECT `+waitlistCols+` FROM waitlist_entries WHERE slot_id = $1::uuid AND status = $2 ORDER BY created_at FOR UPDATE`, s.ID, models.WaitlistWaiting)
+	if err != nil {
+		return nil, err
+	}
+	queue, err := scanWaitlistRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	out := []models.WaitlistEntry{}
+	for _, e := range queue {
+		if s.Status != "open" || s.Remaining <= 0 {
+			break
+		}
+		if e.Participants > s.Remaining {
+			continue
+		}
+		if err := offerEntry(ctx, tx, &s, &e, ttl); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	if err := saveSlotSeats(ctx, tx, s); err != nil {
+		return nil, err
+	}
+	return out, tx.Commit(ctx)
+}
+func (p *Postgres) OfferWaitlistEntry(id string, ttl time.Duration) (models.WaitlistEntry, error) {
+	ctx, cancel := p.ctx()
+	defer cancel()
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	defer tx.Rollback(ctx)
+	s, e, err := lockSlotEntry(ctx, tx, id)
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	if e.Status != models.WaitlistWaiting {
+		return models.WaitlistEntry{}, ErrWaitlistClosed
+	}
+	if err := offerEntry(ctx, tx, &s, &e, ttl); err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	if err := saveSlotSeats(ctx, tx, s); err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	return e, tx.Commit(ctx)
+}
+
+// lockSlotEntry locks a waitlist entry and its slot. Like every
+// transaction that touches both, it locks the slot row first, so it cannot
+// deadlock with OfferWaitlist, CreateBooking or releaseHolds.
+func lockSlotEntry(ctx context.Context, tx pgx.Tx, id string) (models.TimeSlot, models.WaitlistEntry, error) {
+	var slotID string
+	if err := tx.QueryRow(ctx, `SELECT slot_id::text FROM waitlist_entries WHERE id = $1::uuid`, id).Scan(&slotID); err != nil {
+		return models.TimeSlot{}, models.WaitlistEntry{}, notFound(err)
+	}
+	s, err := lockSlot(ctx, tx, slotID)
+	if err != nil {
+		return models.TimeSlot{}, models.WaitlistEntry{}, err
+	}
+	e, err := scanWaitlist(tx.QueryRow(ctx, `SELECT `+waitlistCols+` FROM waitlist_entries WHERE id = $1::uuid FOR UPDATE`, id))
+	return s, e, notFound(err)
+}
+
+// offerEntry takes seats from the locked slot (saved by the caller) and
+// creates the hold the entry is offered.
+func offerEntry(ctx context.Context, tx pgx.Tx, s *models.TimeSlot, e *models.WaitlistEntry, ttl time.Duration) error {
+	if err := takeSeats(s, e.Participants); err != nil {
+		return err
+	}
+	h, err := insertHold(ctx, tx, s.ID, e.Participants, ttl)
+	if err != nil {
+		return err
+	}
+	got, err := scanWaitlist(tx.QueryRow(ctx, `UPDATE waitlist_entries SET status = $2, hold_token = $3, offer_expires_at = $4, updated_at = now()
+		WHERE id = $1::uuid RETURNING `+waitlistCols, e.ID, models.WaitlistOffered, h.Token, h.ExpiresAt))
+	if err != nil {
+		return err
+	}
+	*e = got
+	return nil
+}
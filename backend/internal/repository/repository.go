@@ -4,265 +4,264 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
-	"sort"
+	"fmt"
 	"strings"
-	"sync"
 	"time"
 
 	"sup-anapa/backend/internal/models"
 )
 
-type Repository struct {
-	mu       sync.RWMutex
-	inst     map[string]models.Instructor
-	routes   map[string]models.Route
-	slots    map[string]models.TimeSlot
-	bookings map[string]models.Booking
-	weather  []models.WeatherSnapshot
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrSlotUnavailable = errors.New("slot unavailable")
+	ErrSlotMismatch    = errors.New("booking does not match slot")
+	ErrWaitlistClosed  = errors.New("waitlist entry is no longer active")
+	ErrSlotOverlap     = errors.New("slot overlaps another slot of the instructor")
+	ErrCapacityBooked  = errors.New("capacity is below the seats already taken")
+	ErrSlotBooked      = errors.New("slot with taken seats cannot move")
+	ErrOfferExists     = errors.New("booking already has a pending reschedule offer")
+	ErrOfferClosed     = errors.New("reschedule offer is no longer pending")
+	ErrPromoExhausted  = errors.New("promo code usage limit reached")
+)
+
+type InstructorStore interface {
+	ListInstructors(minPrice, maxPrice int, minRating float64, tag string) ([]models.Instructor, error)
+	GetInstructor(id string) (models.Instructor, error)
+	UpsertInstructor(item *models.Instructor) error
 }
 
-func New() *Repository {
-	r := &Repository{
-		inst:     map[string]models.Instructor{},
-		routes:   map[string]models.Route{},
-		slots:    map[string]models.TimeSlot{},
-		bookings: map[string]models.Booking{},
-		weather:  []models.WeatherSnapshot{},
-	}
-	r.seed()
-	return r
+type RouteStore interface {
+	ListRoutes() ([]models.Route, error)
+	GetRoute(id string) (models.Route, error)
+	UpsertRoute(item *models.Route) error
 }
 
-func id() string { b := make([]byte, 16); _, _ = rand.Read(b); return hex.EncodeToString(b) }
+type SlotStore interface {
+	GetSlot(id string) (models.TimeSlot, error)
+	// ListAvailability reports Remaining capped by the free boards and
+	// paddles of the route's station.
+	ListAvailability(date time.Time, routeID, instructorID string, includeFull bool) ([]models.TimeSlot, error)
+	// BulkCreateSlots writes all slots or none; a slot overlapping another
+	// slot of the same instructor fails the batch with ErrSlotOverlap. A slot
+	// whose id is already stored is updated: its taken seats are kept, a
+	// capacity below them fails the batch with ErrCapacityBooked, and so
+	// does a new instructor, route or time with ErrSlotBooked.
+	BulkCreateSlots(slots []models.TimeSlot) error
+	SuggestedSlots(target time.Time, routeID, instructorID string, limit int) ([]models.TimeSlot, error)
+	// ListInstructorSlots returns every slot of the instructor starting in
+	// [from, to), whatever its status.
+	ListInstructorSlots(instructorID string, from, to time.Time) ([]models.TimeSlot, error)
+	SetSlotBlocked(id string, blocked bool) (models.TimeSlot, error)
+	// CreateSlotsIfAbsent inserts the slots that do not overlap any slot of
+	// their instructor and returns the ones it created.
+	CreateSlotsIfAbsent(slots []models.TimeSlot) ([]models.TimeSlot, error)
+}
 
-func (r *Repository) seed() {
-	now := time.Now().UTC()
-	i1 := models.Instructor{ID: "11111111111111111111111111111111", Name: "Алексей Морев", PhotoURL: "https://images.unsplash.com/photo-1500648767791-00dcc994a43e", Bio: "Спокойные прогулки для новичков и семей.", Rating: 4.9, ReviewsCount: 132, ExperienceYears: 7, Tags: []string{"новички", "дети", "закат"}, Languages: []string{"RU", "EN"}, BasePrice: 3000, IsActive: true, CreatedAt: now, UpdatedAt: now}
-	i2 := models.Instructor{ID: "22222222222222222222222222222222", Name: "Мария Волна", PhotoURL: "https://images.unsplash.com/photo-1494790108377-be9c29b29330", Bio: "Тренировки и SUP-фитнес на реке.", Rating: 4.8, ReviewsCount: 96, ExperienceYears: 5, Tags: []string{"спорт", "новички"}, Languages: []string{"RU"}, BasePrice: 3200, IsActive: true, CreatedAt: now, UpdatedAt: now}
-	r.inst[i1.ID] = i1
-	r.inst[i2.ID] = i2
-	r1 := models.Route{ID: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", Title: "Река у Анапы — спокойная вода", DurationMinutes: 90, Difficulty: "easy", BasePrice: 2500, Description: "Идеально для первого SUP", LocationLat: 45.092, LocationLng: 37.268, LocationTitle: "Старт: река у Анапы", CreatedAt: now, UpdatedAt: now}
-	r.routes[r1.ID] = r1
-	for d := 0; d < 7; d++ {
-		s := models.TimeSlot{ID: id(), InstructorID: i1.ID, RouteID: r1.ID, StartAt: time.Date(now.Year(), now.Month(), now.Day()+d, 9, 0, 0, 0, time.UTC), EndAt: time.Date(now.Year(), now.Month(), now.Day()+d, 10, 30, 0, 0, time.UTC), Capacity: 6, Remaining: 6, Status: "open", CreatedAt: now, UpdatedAt: now}
-		r.slots[s.ID] = s
-	}
+type TemplateStore interface {
+	ListTemplates() ([]models.ScheduleTemplate, error)
+	GetTemplate(id string) (models.ScheduleTemplate, error)
+	UpsertTemplate(t *models.ScheduleTemplate) error
+	DeleteTemplate(id string) error
 }
 
-func (r *Repository) ListInstructors(minPrice, maxPrice int, minRating float64, tag string) ([]models.Instructor, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	out := []models.Instructor{}
-	for _, i := range r.inst {
-		if !i.IsActive {
-			continue
-		}
-		if minPrice > 0 && i.BasePrice < minPrice {
-			continue
-		}
-		if maxPrice > 0 && i.BasePrice > maxPrice {
-			continue
-		}
-		if minRating > 0 && i.Rating < minRating {
-			continue
-		}
-		if tag != "" {
-			ok := false
-			for _, t := range i.Tags {
-				if strings.EqualFold(t, tag) {
-					ok = true
-					break
-				}
-			}
-			if !ok {
-				continue
-			}
-		}
-		out = append(out, i)
-	}
-	sort.Slice(out, func(a, b int) bool { return out[a].Rating > out[b].Rating })
-	return out, nil
+// InventoryStore keeps stations and their equipment stock. FreeEquipment
+// reports the stock not reserved by bookings for any part of [from, to).
+type InventoryStore interface {
+	ListStations() ([]models.Station, error)
+	GetStation(id string) (models.Station, error)
+	UpsertStation(st *models.Station) error
+	FreeEquipment(stationID string, from, to time.Time) (map[string]int, error)
 }
-func (r *Repository) GetInstructor(id string) (models.Instructor, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	i, ok := r.inst[id]
-	if !ok {
-		return models.Instructor{}, errors.New("not found")
-	}
-	return i, nil
+
+// OptionStore is the add-on catalog, keyed by option code.
+type OptionStore interface {
+	ListOptions() ([]models.Option, error)
+	GetOption(code string) (models.Option, error)
+	UpsertOption(o *models.Option) error
+	DeleteOption(code string) error
 }
-func (r *Repository) ListRoutes() ([]models.Route, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	out := []models.Route{}
-	for _, v := range r.routes {
-		out = append(out, v)
-	}
-	return out, nil
+
+// PromoStore keeps promo codes, keyed by their upper-case code. Upserts
+// never touch Used; CreateBooking counts a use of Booking.PromoCode in the
+// same transaction and fails with ErrPromoExhausted past MaxUses.
+type PromoStore interface {
+	ListPromoCodes() ([]models.PromoCode, error)
+	GetPromoCode(code string) (models.PromoCode, error)
+	UpsertPromoCode(p *models.PromoCode) error
+	DeletePromoCode(code string) error
 }
-func (r *Repository) ListAvailability(date time.Time, routeID, instructorID string) ([]models.TimeSlot, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	out := []models.TimeSlot{}
-	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
-	end := start.Add(24 * time.Hour)
-	for _, s := range r.slots {
-		if s.StartAt.Before(start) || !s.StartAt.Before(end) || s.Status != "open" || s.Remaining <= 0 {
-			continue
-		}
-		if routeID != "" && s.RouteID != routeID {
-			continue
-		}
-		if instructorID != "" && s.InstructorID != instructorID {
-			continue
-		}
-		out = append(out, s)
-	}
-	sort.Slice(out, func(a, b int) bool { return out[a].StartAt.Before(out[b].StartAt) })
-	return out, nil
+
+// GiftStore keeps gift certificates. CreateBooking redeems
+// Booking.GiftAmount from Booking.GiftCode in the same transaction
+// (ErrGiftClosed, ErrGiftBalance); cancelling the booking gives it back.
+type GiftStore interface {
+	// CreateGift assigns a new unique code and makes the certificate active.
+	CreateGift(g *models.GiftCertificate) error
+	GetGift(code string) (models.GiftCertificate, error)
+	ListGifts(status string) ([]models.GiftCertificate, error)
+	VoidGift(code string) (models.GiftCertificate, error)
+	ListGiftRedemptions(code string) ([]models.GiftRedemption, error)
 }
-func (r *Repository) CreateBooking(b *models.Booking) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	s, ok := r.slots[b.SlotID]
-	if !ok || s.Status != "open" || s.Remaining < b.Participants {
-		return errors.New("slot unavailable")
-	}
-	s.Remaining -= b.Participants
-	if s.Remaining == 0 {
-		s.Status = "closed"
-	}
-	s.UpdatedAt = time.Now().UTC()
-	r.slots[s.ID] = s
-	now := time.Now().UTC()
-	b.ID = id()
-	b.Status = "pending"
-	b.CreatedAt = now
-	b.UpdatedAt = now
-	r.bookings[b.ID] = *b
-	return nil
+
+// PaymentStore keeps provider payments of bookings. SetPaymentStatus only
+// moves a payment that is still in the expected status (ErrPaymentClosed
+// otherwise), so a webhook delivered twice is applied once.
+type PaymentStore interface {
+	CreatePayment(p *models.Payment) error
+	GetPayment(id string) (models.Payment, error)
+	FindPayment(provider, ref string) (models.Payment, error)
+	ListBookingPayments(bookingID string) ([]models.Payment, error)
+	SetPaymentStatus(id, from, to string) (models.Payment, error)
+	// AddPaymentRefund adds amount to Refunded of a succeeded payment
+	// (ErrRefundTooLarge past Amount).
+	AddPaymentRefund(id string, amount int) (models.Payment, error)
+	// UndoPaymentRefund takes amount back off Refunded, reopening a fully
+	// refunded payment.
+	UndoPaymentRefund(id string, amount int) (models.Payment, error)
 }
-func (r *Repository) GetBooking(id string) (models.Booking, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	b, ok := r.bookings[id]
-	if !ok {
-		return models.Booking{}, errors.New("not found")
-	}
-	return b, nil
+
+type BookingStore interface {
+	// CreateBooking takes the seats and, when the route starts at a station,
+	// reserves its equipment for the slot time (ErrEquipmentUnavailable if
+	// short). Cancelling releases the equipment, rescheduling moves it.
+	CreateBooking(b *models.Booking) error
+	GetBooking(id string) (models.Booking, error)
+	PatchBookingStatus(id, status, reason string) (models.Booking, error)
+	// RescheduleBooking moves a pending or confirmed booking to another slot
+	// in one step. A nil price keeps the current price and gift amount;
+	// otherwise giftAmount is what the gift certificate now pays, at most the
+	// current GiftAmount, and the difference goes back to the certificate.
+	RescheduleBooking(id, slotID string, price *models.PriceBreakdown, giftAmount int, reason string) (models.Booking, error)
+	// CancelBooking cancels the booking, gives back refund.GiftPercent of its
+	// gift certificate amount and records the refund in one step. changed is
+	// false when the booking was already cancelled; nothing is recorded
+	// then, so the refund is paid only once. PatchBookingStatus cancellations
+	// give the whole gift certificate amount back.
+	CancelBooking(id, reason string, refund models.Refund) (b models.Booking, changed bool, err error)
+	ListBookingHistory(id string) ([]models.BookingStatusChange, error)
+	// ListBookingsStarting returns bookings in the given status whose slot
+	// starts in [from, to), earliest first.
+	ListBookingsStarting(from, to time.Time, status string) ([]models.Booking, error)
+	ListSlotBookings(slotIDs []string) ([]models.Booking, error)
+	ListBookingMismatches() ([]models.BookingMismatch, error)
 }
-func (r *Repository) UpsertInstructor(item *models.Instructor) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if item.ID == "" {
-		item.ID = id()
-	}
-	item.UpdatedAt = time.Now().UTC()
-	if item.CreatedAt.IsZero() {
-		item.CreatedAt = item.UpdatedAt
-	}
-	r.inst[item.ID] = *item
-	return nil
+
+// HoldStore reserves seats for the duration of checkout. A hold takes seats
+// from the slot right away; CreateBooking with the hold token turns them
+// into a booking, otherwise ReleaseExpiredHolds gives them back.
+type HoldStore interface {
+	CreateHold(slotID string, seats int, ttl time.Duration) (models.SeatHold, error)
+	GetHold(token string) (models.SeatHold, error)
+	ReleaseHold(token string) error
+	ReleaseExpiredHolds(now time.Time) ([]models.SeatHold, error)
 }
-func (r *Repository) UpsertRoute(item *models.Route) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if item.ID == "" {
-		item.ID = id()
-	}
-	item.UpdatedAt = time.Now().UTC()
-	if item.CreatedAt.IsZero() {
-		item.CreatedAt = item.UpdatedAt
-	}
-	r.routes[item.ID] = *item
-	return nil
+
+// IdempotencyStore backs the Idempotency-Key header of POST /api/bookings.
+// ReserveIdempotencyKey claims a key atomically: it returns reserved=true
+// for a new (or expired) key, otherwise the stored entry.
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(key, requestHash string, retention time.Duration) (existing models.IdempotencyKey, reserved bool, err error)
+	CompleteIdempotencyKey(key, bookingID string) error
+	ReleaseIdempotencyKey(key string) error
+	PurgeIdempotencyKeys(now time.Time) (int, error)
 }
-func (r *Repository) BulkCreateSlots(slots []models.TimeSlot) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	now := time.Now().UTC()
-	for _, s := range slots {
-		if s.ID == "" {
-			s.ID = id()
-		}
-		if s.Status == "" {
-			s.Status = "open"
-		}
-		if s.Remaining == 0 {
-			s.Remaining = s.Capacity
-		}
-		s.CreatedAt = now
-		s.UpdatedAt = now
-		r.slots[s.ID] = s
-	}
-	return nil
+
+// WaitlistStore queues customers for full slots. OfferWaitlist hands freed
+// seats to waiting entries in FIFO order by creating seat holds for them;
+// the hold lifecycle then drives the entry (booked → accepted, hold expired
+// or released → expired).
+type WaitlistStore interface {
+	JoinWaitlist(e *models.WaitlistEntry) error
+	GetWaitlistEntry(id string) (models.WaitlistEntry, error)
+	ListWaitlist(slotID string) ([]models.WaitlistEntry, error)
+	CancelWaitlistEntry(id string) (models.WaitlistEntry, error)
+	OfferWaitlist(slotID string, ttl time.Duration) ([]models.WaitlistEntry, error)
+	OfferWaitlistEntry(id string, ttl time.Duration) (models.WaitlistEntry, error)
 }
-func (r *Repository) PatchBookingStatus(id, status string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	b, ok := r.bookings[id]
-	if !ok {
-		return errors.New("not found")
-	}
-	b.Status = status
-	b.UpdatedAt = time.Now().UTC()
-	r.bookings[id] = b
-	return nil
+
+// RescheduleOfferStore keeps weather-driven reschedule offers. A booking has
+// at most one pending offer; SetRescheduleOfferStatus only moves an offer
+// that is still in the expected status.
+type RescheduleOfferStore interface {
+	CreateRescheduleOffer(o *models.RescheduleOffer) error
+	GetRescheduleOffer(token string) (models.RescheduleOffer, error)
+	ListRescheduleOffers(status string) ([]models.RescheduleOffer, error)
+	SetRescheduleOfferStatus(token, from, to, slotID string) (models.RescheduleOffer, error)
 }
-func (r *Repository) FindWeatherSnapshot(lat, lng float64, timeFrom time.Time, ttl time.Duration) (models.WeatherSnapshot, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	threshold := time.Now().UTC().Add(-ttl)
-	for i := len(r.weather) - 1; i >= 0; i-- {
-		w := r.weather[i]
-		if w.LocationLat == lat && w.LocationLng == lng && w.TimeFrom.Equal(timeFrom) && w.FetchedAt.After(threshold) {
-			return w, nil
-		}
-	}
-	return models.WeatherSnapshot{}, errors.New("not found")
+
+// APIKeyStore looks keys up by hash. FindAPIKey reports revoked keys as
+// ErrNotFound; they stay in ListAPIKeys.
+type APIKeyStore interface {
+	CreateAPIKey(k *models.APIKey) error
+	FindAPIKey(hash string) (models.APIKey, error)
+	ListAPIKeys() ([]models.APIKey, error)
+	RevokeAPIKey(id string) error
 }
-func (r *Repository) SaveWeatherSnapshot(s *models.WeatherSnapshot) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if s.ID == "" {
-		s.ID = id()
+
+type WeatherCache interface {
+	FindWeatherSnapshot(lat, lng float64, timeFrom time.Time, ttl time.Duration) (models.WeatherSnapshot, error)
+	SaveWeatherSnapshot(s *models.WeatherSnapshot) error
+}
+
+// Repository is the full storage contract; Memory and Postgres implement it
+// and storetest.Run checks that they behave the same.
+type Repository interface {
+	InstructorStore
+	RouteStore
+	SlotStore
+	TemplateStore
+	InventoryStore
+	OptionStore
+	PromoStore
+	GiftStore
+	PaymentStore
+	BookingStore
+	HoldStore
+	IdempotencyStore
+	WaitlistStore
+	RescheduleOfferStore
+	APIKeyStore
+	WeatherCache
+}
+
+var (
+	_ Repository = (*Memory)(nil)
+	_ Repository = (*Postgres)(nil)
+)
+
+func id() string { b := make([]byte, 16); _, _ = rand.Read(b); return hex.EncodeToString(b) }
+
+func sameID(a, b string) bool {
+	norm := func(v string) string { return strings.ReplaceAll(strings.ToLower(v), "-", "") }
+	return norm(a) == norm(b)
+}
+
+func overlaps(a, b models.TimeSlot) bool {
+	return sameID(a.InstructorID, b.InstructorID) && a.StartAt.Before(b.EndAt) && b.StartAt.Before(a.EndAt)
+}
+
+// bindSlot makes the booking inherit instructor and route from its slot.
+// Values sent by the client are allowed only if they agree with the slot.
+func bindSlot(b *models.Booking, s models.TimeSlot) error {
+	if b.InstructorID != "" && !sameID(b.InstructorID, s.InstructorID) {
+		return fmt.Errorf("%w: slot %s belongs to instructor %s, not %s", ErrSlotMismatch, s.ID, s.InstructorID, b.InstructorID)
+	}
+	if b.RouteID != "" && !sameID(b.RouteID, s.RouteID) {
+		return fmt.Errorf("%w: slot %s is on route %s, not %s", ErrSlotMismatch, s.ID, s.RouteID, b.RouteID)
 	}
-	r.weather = append(r.weather, *s)
+	b.InstructorID = s.InstructorID
+	b.RouteID = s.RouteID
 	return nil
 }
-func (r *Repository) SuggestedSlots(target time.Time, routeID, instructorID string, limit int) ([]models.TimeSlot, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	out := []models.TimeSlot{}
-	for _, s := range r.slots {
-		if s.Status != "open" || s.Remaining <= 0 {
-			continue
-		}
-		if routeID != "" && s.RouteID != routeID {
-			continue
-		}
-		if instructorID != "" && s.InstructorID != instructorID {
-			continue
-		}
-		if s.StartAt.Before(target.Add(-4*time.Hour)) || s.StartAt.After(target.Add(8*time.Hour)) {
-			continue
-		}
-		out = append(out, s)
+
+// slotMismatches compares a stored booking with its slot.
+func slotMismatches(b models.Booking, s models.TimeSlot) []models.BookingMismatch {
+	out := []models.BookingMismatch{}
+	if !sameID(b.InstructorID, s.InstructorID) {
+		out = append(out, models.BookingMismatch{BookingID: b.ID, SlotID: b.SlotID, Field: "instructor_id", BookingValue: b.InstructorID, SlotValue: s.InstructorID})
 	}
-	sort.Slice(out, func(a, b int) bool {
-		da := out[a].StartAt.Sub(target)
-		if da < 0 {
-			da = -da
-		}
-		db := out[b].StartAt.Sub(target)
-		if db < 0 {
-			db = -db
-		}
-		return da < db
-	})
-	if len(out) > limit {
-		out = out[:limit]
+	if !sameID(b.RouteID, s.RouteID) {
+		out = append(out, models.BookingMismatch{BookingID: b.ID, SlotID: b.SlotID, Field: "route_id", BookingValue: b.RouteID, SlotValue: s.RouteID})
 	}
-	return out, nil
+	return out
 }
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"sup-anapa/backend/internal/models"
+)
+
+var (
+	ErrUnknownStatus     = errors.New("unknown booking status")
+	ErrInvalidTransition = errors.New("status transition not allowed")
+)
+
+// bookingTransitions is the booking lifecycle: pending → confirmed →
+// completed, with cancelled and no_show as the other terminal states.
+var bookingTransitions = map[string][]string{
+	models.BookingPending:   {models.BookingConfirmed, models.BookingCancelled},
+	models.BookingConfirmed: {models.BookingCompleted, models.BookingCancelled, models.BookingNoShow},
+	models.BookingCompleted: {},
+	models.BookingCancelled: {},
+	models.BookingNoShow:    {},
+}
+
+func CanTransition(from, to string) bool {
+	for _, s := range bookingTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTransition returns nil for an allowed change and for a no-op
+// (from == to).
+func checkTransition(from, to string) error {
+	if _, ok := bookingTransitions[to]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownStatus, to)
+	}
+	if from == to || CanTransition(from, to) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s → %s", ErrInvalidTransition, from, to)
+}
+
+func checkReschedulable(status string) error {
+	if status != models.BookingPending && status != models.BookingConfirmed {
+		return fmt.Errorf("%w: cannot reschedule a %s booking", ErrInvalidTransition, status)
+	}
+	return nil
+}
+
+func takeSeats(s *models.TimeSlot, seats int) error {
+	if s.Status != "open" || s.Remaining < seats {
+		return ErrSlotUnavailable
+	}
+	s.Remaining -= seats
+	if s.Remaining == 0 {
+		s.Status = "closed"
+	}
+	return nil
+}
+
+// releaseSeats gives seats back to a slot and reopens it if it was closed
+// only because it had filled up.
+func releaseSeats(s *models.TimeSlot, seats int) {
+	s.Remaining += seats
+	if s.Remaining > s.Capacity {
+		s.Remaining = s.Capacity
+	}
+	if s.Status == "closed" && s.Remaining > 0 {
+		s.Status = "open"
+	}
+}
+
+// updateSlot applies an edited slot over the stored one, keeping the seats
+// already taken and a blocked or cancelled status. A slot with taken seats
+// keeps its instructor, route and time: the customers booked those.
+func updateSlot(old, s models.TimeSlot) (models.TimeSlot, error) {
+	taken := old.Capacity - old.Remaining
+	if s.Capacity < taken {
+		return old, fmt.Errorf("%w: slot %s has %d seats taken, capacity %d", ErrCapacityBooked, old.ID, taken, s.Capacity)
+	}
+	if taken > 0 && (!sameID(s.InstructorID, old.InstructorID) || !sameID(s.RouteID, old.RouteID) || !s.StartAt.Equal(old.StartAt) || !s.EndAt.Equal(old.EndAt)) {
+		return old, fmt.Errorf("%w: slot %s has %d seats taken", ErrSlotBooked, old.ID, taken)
+	}
+	s.ID = old.ID
+	s.Remaining = s.Capacity - taken
+	s.Status = old.Status
+	if s.Status == "open" || s.Status == "closed" {
+		s.Status = "open"
+		if s.Remaining == 0 {
+			s.Status = "closed"
+		}
+	}
+	s.CreatedAt = old.CreatedAt
+	return s, nil
+}
+
+// setSlotBlocked takes a slot off sale or puts it back; seats already booked
+// are kept either way.
+func setSlotBlocked(s *models.TimeSlot, blocked bool) {
+	if blocked {
+		if s.Status == "open" || s.Status == "closed" {
+			s.Status = "blocked"
+		}
+		return
+	}
+	if s.Status == "blocked" {
+		s.Status = "open"
+		if s.Remaining <= 0 {
+			s.Status = "closed"
+		}
+	}
+}
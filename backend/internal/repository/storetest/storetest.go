@@ -0,0 +1,1508 @@
+// Package storetest is the conformance suite shared by repository
+// implementations. A backend's tests call Run with a constructor that
+// returns a ready store (seeded data is fine: every case creates its own
+// instructors, routes and slots and only looks at those).
+package storetest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+// MissingID is a well-formed id that no store should contain.
+const MissingID = "00000000000000000000000000000000"
+
+func Run(t *testing.T, newStore func(t *testing.T) repository.Repository) {
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, s repository.Repository)
+	}{
+		{"Instructors", testInstructors},
+		{"Routes", testRoutes},
+		{"Availability", testAvailability},
+		{"Bookings", testBookings},
+		{"BookingInheritsSlot", testBookingInheritsSlot},
+		{"BookingLifecycle", testBookingLifecycle},
+		{"CancelBookingOnce", testCancelBookingOnce},
+		{"BookingReschedule", testBookingReschedule},
+		{"InstructorSlots", testInstructorSlots},
+		{"SlotOverlap", testSlotOverlap},
+		{"SlotUpdate", testSlotUpdate},
+		{"Equipment", testEquipment},
+		{"Options", testOptions},
+		{"PromoCodes", testPromoCodes},
+		{"GiftCertificates", testGiftCertificates},
+		{"Payments", testPayments},
+		{"ScheduleTemplates", testScheduleTemplates},
+		{"SeatHolds", testSeatHolds},
+		{"IdempotencyKeys", testIdempotencyKeys},
+		{"Waitlist", testWaitlist},
+		{"WaitlistConcurrent", testWaitlistConcurrent},
+		{"BookingsConcurrent", testBookingsConcurrent},
+		{"RescheduleOffers", testRescheduleOffers},
+		{"APIKeys", testAPIKeys},
+		{"SuggestedSlots", testSuggestedSlots},
+		{"WeatherCache", testWeatherCache},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) { c.fn(t, newStore(t)) })
+	}
+}
+
+func token() string { b := make([]byte, 6); _, _ = rand.Read(b); return hex.EncodeToString(b) }
+
+// day returns a UTC midnight far enough ahead not to collide with seed data.
+func day(offset int) time.Time {
+	d := time.Now().UTC().AddDate(0, 0, 300+offset)
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+type fixture struct {
+	instructor models.Instructor
+	route      models.Route
+}
+
+func newFixture(t *testing.T, s repository.Repository) fixture {
+	t.Helper()
+	i := models.Instructor{Name: "Test " + token(), Rating: 4.5, BasePrice: 3000, IsActive: true, Tags: []string{"tag-" + token()}, Languages: []string{"RU"}}
+	if err := s.UpsertInstructor(&i); err != nil {
+		t.Fatalf("UpsertInstructor: %v", err)
+	}
+	r := models.Route{Title: "Route " + token(), DurationMinutes: 90, Difficulty: "easy", BasePrice: 2500, LocationLat: 45.092, LocationLng: 37.268, LocationTitle: "Test start"}
+	if err := s.UpsertRoute(&r); err != nil {
+		t.Fatalf("UpsertRoute: %v", err)
+	}
+	return fixture{instructor: i, route: r}
+}
+
+func (f fixture) slot(start time.Time, capacity int) models.TimeSlot {
+	return models.TimeSlot{InstructorID: f.instructor.ID, RouteID: f.route.ID, StartAt: start, EndAt: start.Add(90 * time.Minute), Capacity: capacity}
+}
+
+func (f fixture) availability(t *testing.T, s repository.Repository, date time.Time) []models.TimeSlot {
+	t.Helper()
+	out, err := s.ListAvailability(date, f.route.ID, f.instructor.ID, false)
+	if err != nil {
+		t.Fatalf("ListAvailability: %v", err)
+	}
+	return out
+}
+
+func testInstructors(t *testing.T, s repository.Repository) {
+	tag := "tag-" + token()
+	top := models.Instructor{Name: "Top", Rating: 4.9, BasePrice: 3500, IsActive: true, Tags: []string{tag}}
+	cheap := models.Instructor{Name: "Cheap", Rating: 4.1, BasePrice: 2000, IsActive: true, Tags: []string{tag}}
+	hidden := models.Instructor{Name: "Hidden", Rating: 5, BasePrice: 3000, IsActive: false, Tags: []string{tag}}
+	for _, i := range []*models.Instructor{&cheap, &top, &hidden} {
+		if err := s.UpsertInstructor(i); err != nil {
+			t.Fatalf("UpsertInstructor: %v", err)
+		}
+		if i.ID == "" || i.CreatedAt.IsZero() {
+			t.Fatalf("UpsertInstructor did not assign id/created_at: %+v", i)
+		}
+	}
+	list, err := s.ListInstructors(0, 0, 0, tag)
+	if err != nil {
+		t.Fatalf("ListInstructors: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != top.ID || list[1].ID != cheap.ID {
+		t.Fatalf("ListInstructors by tag: want [Top Cheap] sorted by rating, got %+v", list)
+	}
+	if list, _ := s.ListInstructors(3000, 0, 0, tag); len(list) != 1 || list[0].ID != top.ID {
+		t.Fatalf("ListInstructors min_price: got %+v", list)
+	}
+	if list, _ := s.ListInstructors(0, 2500, 0, tag); len(list) != 1 || list[0].ID != cheap.ID {
+		t.Fatalf("ListInstructors max_price: got %+v", list)
+	}
+	if list, _ := s.ListInstructors(0, 0, 4.5, tag); len(list) != 1 || list[0].ID != top.ID {
+		t.Fatalf("ListInstructors min_rating: got %+v", list)
+	}
+
+	top.BasePrice = 3700
+	if err := s.UpsertInstructor(&top); err != nil {
+		t.Fatalf("UpsertInstructor update: %v", err)
+	}
+	got, err := s.GetInstructor(top.ID)
+	if err != nil {
+		t.Fatalf("GetInstructor: %v", err)
+	}
+	if got.BasePrice != 3700 || got.Name != "Top" || len(got.Tags) != 1 || got.Tags[0] != tag {
+		t.Fatalf("GetInstructor after update: %+v", got)
+	}
+	if _, err := s.GetInstructor(MissingID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetInstructor missing: want ErrNotFound, got %v", err)
+	}
+}
+
+func testRoutes(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	routes, err := s.ListRoutes()
+	if err != nil {
+		t.Fatalf("ListRoutes: %v", err)
+	}
+	found := false
+	for _, r := range routes {
+		if r.ID == f.route.ID {
+			found = r.Title == f.route.Title && r.DurationMinutes == 90
+		}
+	}
+	if !found {
+		t.Fatalf("ListRoutes does not contain %+v", f.route)
+	}
+	got, err := s.GetRoute(f.route.ID)
+	if err != nil || got.BasePrice != 2500 || got.LocationTitle != "Test start" || got.ShoreBearing != nil {
+		t.Fatalf("GetRoute: %+v, %v", got, err)
+	}
+	bearing := 135
+	got.ShoreBearing = &bearing
+	if err := s.UpsertRoute(&got); err != nil {
+		t.Fatalf("UpsertRoute shore bearing: %v", err)
+	}
+	if got, _ := s.GetRoute(f.route.ID); got.ShoreBearing == nil || *got.ShoreBearing != 135 {
+		t.Fatalf("shore bearing not persisted: %+v", got)
+	}
+	if _, err := s.GetRoute(MissingID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetRoute missing: want ErrNotFound, got %v", err)
+	}
+}
+
+func testAvailability(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	d := day(0)
+	closed := f.slot(d.Add(15*time.Hour), 4)
+	closed.Status = "closed"
+	slots := []models.TimeSlot{f.slot(d.Add(12*time.Hour), 4), f.slot(d.Add(9*time.Hour), 6), closed, f.slot(d.Add(33*time.Hour), 4)}
+	if err := s.BulkCreateSlots(slots); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	got := f.availability(t, s, d)
+	if len(got) != 2 {
+		t.Fatalf("ListAvailability: want 2 open slots on %s, got %+v", d.Format("2006-01-02"), got)
+	}
+	if !got[0].StartAt.Equal(d.Add(9*time.Hour)) || !got[1].StartAt.Equal(d.Add(12*time.Hour)) {
+		t.Fatalf("ListAvailability: want slots sorted by start, got %v, %v", got[0].StartAt, got[1].StartAt)
+	}
+	if got[0].Remaining != 6 || got[0].Status != "open" || got[0].ID == "" {
+		t.Fatalf("BulkCreateSlots defaults: %+v", got[0])
+	}
+	if other, _ := s.ListAvailability(d, f.route.ID, MissingID, false); len(other) != 0 {
+		t.Fatalf("ListAvailability instructor filter: got %+v", other)
+	}
+	slot, err := s.GetSlot(got[0].ID)
+	if err != nil || slot.InstructorID != f.instructor.ID || slot.RouteID != f.route.ID || slot.Capacity != 6 {
+		t.Fatalf("GetSlot: %+v, %v", slot, err)
+	}
+	if _, err := s.GetSlot(MissingID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetSlot missing: want ErrNotFound, got %v", err)
+	}
+}
+
+func testBookings(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	d := day(2)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 3)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slot := f.availability(t, s, d)[0]
+	price := &models.PriceBreakdown{Participants: 2, PerPerson: 5500, Lines: []models.PriceLine{{Code: "instructor", Amount: 6000}, {Code: "route", Amount: 5000}}, Total: 11000}
+	b := models.Booking{InstructorID: f.instructor.ID, RouteID: f.route.ID, SlotID: slot.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 2, Options: map[string]any{"photo": true}, OptionLines: []models.OptionLine{{Code: "photo", Title: "Photo", Quantity: 2, UnitPrice: 700, Amount: 1400}}, PriceTotal: 11000, Price: price}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	if b.ID == "" || b.Status != "pending" || b.CreatedAt.IsZero() {
+		t.Fatalf("CreateBooking did not fill id/status/created_at: %+v", b)
+	}
+	if got := f.availability(t, s, d); len(got) != 1 || got[0].Remaining != 1 {
+		t.Fatalf("slot after booking 2 of 3 seats: %+v", got)
+	}
+	over := b
+	over.ID, over.Participants = "", 2
+	if err := s.CreateBooking(&over); !errors.Is(err, repository.ErrSlotUnavailable) {
+		t.Fatalf("overbooking: want ErrSlotUnavailable, got %v", err)
+	}
+	last := b
+	last.ID, last.Participants = "", 1
+	if err := s.CreateBooking(&last); err != nil {
+		t.Fatalf("CreateBooking last seat: %v", err)
+	}
+	if got := f.availability(t, s, d); len(got) != 0 {
+		t.Fatalf("full slot is still listed: %+v", got)
+	}
+
+	got, err := s.GetBooking(b.ID)
+	if err != nil {
+		t.Fatalf("GetBooking: %v", err)
+	}
+	if got.SlotID != slot.ID || got.Participants != 2 || got.PriceTotal != 11000 || got.Options["photo"] != true || len(got.OptionLines) != 1 || got.OptionLines[0].Amount != 1400 {
+		t.Fatalf("GetBooking round trip: %+v", got)
+	}
+	if got.Price == nil || got.Price.Total != 11000 || len(got.Price.Lines) != 2 {
+		t.Fatalf("GetBooking price breakdown: %+v", got.Price)
+	}
+	if _, err := s.PatchBookingStatus(b.ID, "confirmed", ""); err != nil {
+		t.Fatalf("PatchBookingStatus: %v", err)
+	}
+	if got, _ := s.GetBooking(b.ID); got.Status != "confirmed" {
+		t.Fatalf("PatchBookingStatus not persisted: %+v", got)
+	}
+	if got.InstructorID != f.instructor.ID || got.RouteID != f.route.ID {
+		t.Fatalf("booking did not keep slot instructor/route: %+v", got)
+	}
+	bare := models.Booking{SlotID: slot.ID, CustomerName: "Olga", Phone: "+79990000001", Participants: 1}
+	if err := s.CreateBooking(&bare); !errors.Is(err, repository.ErrSlotUnavailable) {
+		t.Fatalf("booking a full slot: want ErrSlotUnavailable, got %v", err)
+	}
+	if _, err := s.GetBooking(MissingID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetBooking missing: want ErrNotFound, got %v", err)
+	}
+	if _, err := s.PatchBookingStatus(MissingID, "confirmed", ""); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("PatchBookingStatus missing: want ErrNotFound, got %v", err)
+	}
+}
+
+func testBookingInheritsSlot(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	other := newFixture(t, s)
+	d := day(3)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 4)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slot := f.availability(t, s, d)[0]
+	b := models.Booking{SlotID: slot.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 1}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking without instructor/route: %v", err)
+	}
+	if b.InstructorID != f.instructor.ID || b.RouteID != f.route.ID {
+		t.Fatalf("CreateBooking did not copy instructor/route from slot: %+v", b)
+	}
+	for _, bad := range []models.Booking{
+		{SlotID: slot.ID, InstructorID: other.instructor.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 1},
+		{SlotID: slot.ID, RouteID: other.route.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 1},
+	} {
+		if err := s.CreateBooking(&bad); !errors.Is(err, repository.ErrSlotMismatch) {
+			t.Fatalf("CreateBooking with foreign instructor/route: want ErrSlotMismatch, got %v", err)
+		}
+	}
+	if got := f.availability(t, s, d); got[0].Remaining != 3 {
+		t.Fatalf("rejected bookings consumed seats: %+v", got[0])
+	}
+	mismatches, err := s.ListBookingMismatches()
+	if err != nil {
+		t.Fatalf("ListBookingMismatches: %v", err)
+	}
+	for _, m := range mismatches {
+		if m.BookingID == b.ID {
+			t.Fatalf("consistent booking reported: %+v", m)
+		}
+	}
+}
+
+func testBookingLifecycle(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	d := day(5)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 2)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slot := f.availability(t, s, d)[0]
+	b := models.Booking{SlotID: slot.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 2}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	if got, _ := s.GetSlot(slot.ID); got.Remaining != 0 || got.Status != "closed" {
+		t.Fatalf("slot after filling: %+v", got)
+	}
+	if _, err := s.PatchBookingStatus(b.ID, "completed", ""); !errors.Is(err, repository.ErrInvalidTransition) {
+		t.Fatalf("pending → completed: want ErrInvalidTransition, got %v", err)
+	}
+	if _, err := s.PatchBookingStatus(b.ID, "paid", ""); !errors.Is(err, repository.ErrUnknownStatus) {
+		t.Fatalf("unknown status: want ErrUnknownStatus, got %v", err)
+	}
+	if _, err := s.PatchBookingStatus(b.ID, models.BookingConfirmed, "called back"); err != nil {
+		t.Fatalf("pending → confirmed: %v", err)
+	}
+	got, err := s.PatchBookingStatus(b.ID, models.BookingCancelled, "client request")
+	if err != nil || got.Status != models.BookingCancelled {
+		t.Fatalf("confirmed → cancelled: %+v, %v", got, err)
+	}
+	if slot, _ := s.GetSlot(slot.ID); slot.Remaining != 2 || slot.Status != "open" {
+		t.Fatalf("cancellation did not return seats and reopen the slot: %+v", slot)
+	}
+	if _, err := s.PatchBookingStatus(b.ID, models.BookingConfirmed, ""); !errors.Is(err, repository.ErrInvalidTransition) {
+		t.Fatalf("cancelled → confirmed: want ErrInvalidTransition, got %v", err)
+	}
+	if got, changed, err := s.CancelBooking(b.ID, "again", models.Refund{Amount: 2750, Rule: "late"}); err != nil || changed || got.RefundAmount != 0 {
+		t.Fatalf("CancelBooking of a cancelled booking must not record a refund: %+v, %v, %v", got, changed, err)
+	}
+	if _, _, err := s.CancelBooking(MissingID, "", models.Refund{Amount: 1, Rule: "late"}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("CancelBooking missing: want ErrNotFound, got %v", err)
+	}
+	history, err := s.ListBookingHistory(b.ID)
+	if err != nil {
+		t.Fatalf("ListBookingHistory: %v", err)
+	}
+	want := [][2]string{{"", "pending"}, {"pending", "confirmed"}, {"confirmed", "cancelled"}}
+	if len(history) != len(want) {
+		t.Fatalf("ListBookingHistory: want %d entries, got %+v", len(want), history)
+	}
+	for i, w := range want {
+		if history[i].From != w[0] || history[i].To != w[1] {
+			t.Fatalf("history[%d]: want %s → %s, got %+v", i, w[0], w[1], history[i])
+		}
+	}
+	if history[2].Reason != "client request" {
+		t.Fatalf("history reason not stored: %+v", history[2])
+	}
+	if _, err := s.ListBookingHistory(MissingID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("ListBookingHistory missing: want ErrNotFound, got %v", err)
+	}
+}
+
+func testCancelBookingOnce(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	d := day(6)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 3)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slot := f.availability(t, s, d)[0]
+	b := models.Booking{SlotID: slot.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 2}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	const callers = 8
+	changed := make(chan bool, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, ok, err := s.CancelBooking(b.ID, "client request", models.Refund{Amount: 1000 + i, Rule: "late"})
+			if err != nil {
+				t.Errorf("CancelBooking: %v", err)
+			}
+			changed <- ok
+		}(i)
+	}
+	wg.Wait()
+	close(changed)
+	n := 0
+	for ok := range changed {
+		if ok {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Fatalf("%d concurrent cancellations reported a change, want 1", n)
+	}
+	got, _ := s.GetBooking(b.ID)
+	if got.Status != models.BookingCancelled || got.RefundAmount < 1000 || got.RefundRule != "late" {
+		t.Fatalf("cancelled booking: %+v", got)
+	}
+	if slot, _ := s.GetSlot(slot.ID); slot.Remaining != 3 {
+		t.Fatalf("seats returned more than once: %+v", slot)
+	}
+	history, _ := s.ListBookingHistory(b.ID)
+	if len(history) != 2 || history[1].To != models.BookingCancelled {
+		t.Fatalf("want created and one cancellation in history, got %+v", history)
+	}
+}
+
+func testBookingReschedule(t *testing.T, s repository.Repository) {
+	f, other := newFixture(t, s), newFixture(t, s)
+	d := day(9)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 4), f.slot(d.Add(12*time.Hour), 2), other.slot(d.Add(15*time.Hour), 1)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slots := f.availability(t, s, d)
+	from, to, foreign := slots[0], slots[1], other.availability(t, s, d)[0]
+	b := models.Booking{SlotID: from.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 2, PriceTotal: 11000}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	if _, err := s.RescheduleBooking(MissingID, to.ID, nil, 0, ""); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("reschedule missing booking: want ErrNotFound, got %v", err)
+	}
+	if _, err := s.RescheduleBooking(b.ID, MissingID, nil, 0, ""); !errors.Is(err, repository.ErrSlotUnavailable) {
+		t.Fatalf("reschedule to missing slot: want ErrSlotUnavailable, got %v", err)
+	}
+	if _, err := s.RescheduleBooking(b.ID, foreign.ID, nil, 0, ""); !errors.Is(err, repository.ErrSlotUnavailable) {
+		t.Fatalf("reschedule into a slot without room: want ErrSlotUnavailable, got %v", err)
+	}
+	if got, _ := s.GetSlot(from.ID); got.Remaining != 2 {
+		t.Fatalf("failed reschedule changed the old slot: %+v", got)
+	}
+
+	moved, err := s.RescheduleBooking(b.ID, to.ID, nil, 0, "client asked")
+	if err != nil || moved.ID != b.ID || moved.SlotID != to.ID || moved.PriceTotal != 11000 {
+		t.Fatalf("RescheduleBooking: %+v, %v", moved, err)
+	}
+	if got, _ := s.GetSlot(from.ID); got.Remaining != 4 {
+		t.Fatalf("old slot did not get seats back: %+v", got)
+	}
+	if got, _ := s.GetSlot(to.ID); got.Remaining != 0 || got.Status != "closed" {
+		t.Fatalf("new slot did not lose seats: %+v", got)
+	}
+	history, err := s.ListBookingHistory(b.ID)
+	if err != nil || len(history) == 0 {
+		t.Fatalf("ListBookingHistory: %+v, %v", history, err)
+	}
+	last := history[len(history)-1]
+	if last.FromSlotID != from.ID || last.ToSlotID != to.ID || last.Reason != "client asked" {
+		t.Fatalf("reschedule not recorded: %+v", last)
+	}
+
+	if _, err := s.PatchBookingStatus(b.ID, models.BookingCancelled, ""); err != nil {
+		t.Fatalf("PatchBookingStatus: %v", err)
+	}
+	repriced := models.PriceBreakdown{Participants: 2, PerPerson: 1000, Total: 2000}
+	if _, err := s.RescheduleBooking(b.ID, from.ID, &repriced, 0, ""); !errors.Is(err, repository.ErrInvalidTransition) {
+		t.Fatalf("reschedule cancelled booking: want ErrInvalidTransition, got %v", err)
+	}
+
+	c := models.Booking{SlotID: from.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 1}
+	if err := s.CreateBooking(&c); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	moved, err = s.RescheduleBooking(c.ID, foreign.ID, &repriced, 0, "")
+	if err != nil || moved.InstructorID != other.instructor.ID || moved.RouteID != other.route.ID || moved.PriceTotal != 2000 || moved.Price == nil {
+		t.Fatalf("reschedule to another instructor: %+v, %v", moved, err)
+	}
+	if got, _ := s.GetBooking(c.ID); got.PriceTotal != 2000 || got.SlotID != foreign.ID {
+		t.Fatalf("rescheduled booking not stored: %+v", got)
+	}
+}
+
+func testInstructorSlots(t *testing.T, s repository.Repository) {
+	f, other := newFixture(t, s), newFixture(t, s)
+	d := day(11)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 2), f.slot(d.Add(12*time.Hour), 2), other.slot(d.Add(9*time.Hour), 2)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slots, err := s.ListInstructorSlots(f.instructor.ID, d, d.Add(24*time.Hour))
+	if err != nil || len(slots) != 2 || !slots[0].StartAt.Before(slots[1].StartAt) {
+		t.Fatalf("ListInstructorSlots: %+v, %v", slots, err)
+	}
+	b := models.Booking{SlotID: slots[0].ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 2}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	if _, err := s.SetSlotBlocked(MissingID, true); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("block missing slot: want ErrNotFound, got %v", err)
+	}
+	for _, slot := range slots {
+		if got, err := s.SetSlotBlocked(slot.ID, true); err != nil || got.Status != "blocked" {
+			t.Fatalf("SetSlotBlocked: %+v, %v", got, err)
+		}
+	}
+	if err := s.CreateBooking(&models.Booking{SlotID: slots[1].ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 1}); !errors.Is(err, repository.ErrSlotUnavailable) {
+		t.Fatalf("booking a blocked slot: want ErrSlotUnavailable, got %v", err)
+	}
+	if got := f.availability(t, s, d); len(got) != 0 {
+		t.Fatalf("blocked slots listed as available: %+v", got)
+	}
+	if got, _ := s.ListInstructorSlots(f.instructor.ID, d, d.Add(24*time.Hour)); len(got) != 2 {
+		t.Fatalf("blocked slots missing from the instructor schedule: %+v", got)
+	}
+	if got, err := s.SetSlotBlocked(slots[0].ID, false); err != nil || got.Status != "closed" || got.Remaining != 0 {
+		t.Fatalf("unblock full slot: %+v, %v", got, err)
+	}
+	if got, err := s.SetSlotBlocked(slots[1].ID, false); err != nil || got.Status != "open" || got.Remaining != 2 {
+		t.Fatalf("unblock free slot: %+v, %v", got, err)
+	}
+	booked, err := s.ListSlotBookings([]string{slots[0].ID, slots[1].ID})
+	if err != nil || len(booked) != 1 || booked[0].ID != b.ID {
+		t.Fatalf("ListSlotBookings: %+v, %v", booked, err)
+	}
+}
+
+func testSlotOverlap(t *testing.T, s repository.Repository) {
+	f, other := newFixture(t, s), newFixture(t, s)
+	d := day(13)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 4)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(12*time.Hour), 4), f.slot(d.Add(10*time.Hour), 4)}); !errors.Is(err, repository.ErrSlotOverlap) {
+		t.Fatalf("overlapping stored slot: want ErrSlotOverlap, got %v", err)
+	}
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(14*time.Hour), 4), f.slot(d.Add(15*time.Hour), 4)}); !errors.Is(err, repository.ErrSlotOverlap) {
+		t.Fatalf("overlap within the batch: want ErrSlotOverlap, got %v", err)
+	}
+	if got, _ := s.ListInstructorSlots(f.instructor.ID, d, d.Add(24*time.Hour)); len(got) != 1 {
+		t.Fatalf("rejected batch left slots behind: %+v", got)
+	}
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(10*time.Hour+30*time.Minute), 4), other.slot(d.Add(9*time.Hour), 4)}); err != nil {
+		t.Fatalf("back-to-back slot and another instructor: %v", err)
+	}
+}
+
+func testSlotUpdate(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	d := day(15)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 4)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slot := f.availability(t, s, d)[0]
+	b := models.Booking{SlotID: slot.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 2}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	moved := f.slot(d.Add(10*time.Hour), 6)
+	moved.ID = slot.ID
+	if err := s.BulkCreateSlots([]models.TimeSlot{moved}); !errors.Is(err, repository.ErrSlotBooked) {
+		t.Fatalf("move a booked slot: want ErrSlotBooked, got %v", err)
+	}
+	other := newFixture(t, s)
+	moved = other.slot(d.Add(9*time.Hour), 6)
+	moved.ID, moved.InstructorID = slot.ID, f.instructor.ID
+	if err := s.BulkCreateSlots([]models.TimeSlot{moved}); !errors.Is(err, repository.ErrSlotBooked) {
+		t.Fatalf("move a booked slot to another route: want ErrSlotBooked, got %v", err)
+	}
+	// Clients may send ids without hyphens.
+	edit := f.slot(d.Add(9*time.Hour), 6)
+	edit.ID = strings.ReplaceAll(slot.ID, "-", "")
+	if err := s.BulkCreateSlots([]models.TimeSlot{edit}); err != nil {
+		t.Fatalf("update slot: %v", err)
+	}
+	got, err := s.GetSlot(slot.ID)
+	if err != nil || got.Capacity != 6 || got.Remaining != 4 || got.Status != "open" || !got.StartAt.Equal(edit.StartAt) || !got.CreatedAt.Equal(slot.CreatedAt) {
+		t.Fatalf("updated slot: %+v, %v", got, err)
+	}
+	edit.Capacity = 1
+	if err := s.BulkCreateSlots([]models.TimeSlot{edit}); !errors.Is(err, repository.ErrCapacityBooked) {
+		t.Fatalf("capacity below booked seats: want ErrCapacityBooked, got %v", err)
+	}
+	if got, _ := s.GetSlot(slot.ID); got.Capacity != 6 || got.Remaining != 4 {
+		t.Fatalf("rejected update changed the slot: %+v", got)
+	}
+	edit.Capacity = 2
+	if err := s.BulkCreateSlots([]models.TimeSlot{edit}); err != nil {
+		t.Fatalf("shrink to booked seats: %v", err)
+	}
+	if got, _ := s.GetSlot(slot.ID); got.Remaining != 0 || got.Status != "closed" {
+		t.Fatalf("slot shrunk to its bookings: %+v", got)
+	}
+	if list, _ := s.ListInstructorSlots(f.instructor.ID, d, d.Add(24*time.Hour)); len(list) != 1 {
+		t.Fatalf("update created a new slot: %+v", list)
+	}
+}
+
+func testEquipment(t *testing.T, s repository.Repository) {
+	f, other := newFixture(t, s), newFixture(t, s)
+	st := models.Station{Title: "Station " + token(), Stock: map[string]int{models.EquipmentBoard: 3, models.EquipmentPaddle: 5, models.EquipmentVest: 2}}
+	if err := s.UpsertStation(&st); err != nil || st.ID == "" {
+		t.Fatalf("UpsertStation: %+v, %v", st, err)
+	}
+	if got, err := s.GetStation(st.ID); err != nil || got.Stock[models.EquipmentBoard] != 3 {
+		t.Fatalf("GetStation: %+v, %v", got, err)
+	}
+	orphan := models.Route{Title: "Route " + token(), DurationMinutes: 90, Difficulty: "easy", StationID: MissingID}
+	if err := s.UpsertRoute(&orphan); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("route at a missing station: want ErrNotFound, got %v", err)
+	}
+	for _, fx := range []*fixture{&f, &other} {
+		fx.route.StationID = st.ID
+		if err := s.UpsertRoute(&fx.route); err != nil {
+			t.Fatalf("UpsertRoute: %v", err)
+		}
+	}
+	d := day(14)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 4), other.slot(d.Add(9*time.Hour+30*time.Minute), 4), f.slot(d.Add(15*time.Hour), 4)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	first, later := f.availability(t, s, d)[0], f.availability(t, s, d)[1]
+	if first.Remaining != 3 {
+		t.Fatalf("remaining not capped by 3 boards: %+v", first)
+	}
+	b := models.Booking{SlotID: first.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 2, OptionLines: []models.OptionLine{{Code: "drybag", Quantity: 2, Equipment: models.EquipmentDrybag}, {Code: "vest", Quantity: 2, Equipment: models.EquipmentVest}}}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	free, err := s.FreeEquipment(st.ID, first.StartAt, first.EndAt)
+	if err != nil || free[models.EquipmentBoard] != 1 || free[models.EquipmentPaddle] != 3 || free[models.EquipmentVest] != 0 {
+		t.Fatalf("FreeEquipment after booking: %+v, %v", free, err)
+	}
+	overlapping := other.availability(t, s, d)[0]
+	if overlapping.Remaining != 1 {
+		t.Fatalf("overlapping slot of another instructor not capped: %+v", overlapping)
+	}
+	if err := s.CreateBooking(&models.Booking{SlotID: overlapping.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 1, OptionLines: []models.OptionLine{{Code: "vest", Quantity: 1, Equipment: models.EquipmentVest}}}); !errors.Is(err, repository.ErrEquipmentUnavailable) {
+		t.Fatalf("booking without vests left: want ErrEquipmentUnavailable, got %v", err)
+	}
+	if err := s.CreateBooking(&models.Booking{SlotID: overlapping.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 2}); !errors.Is(err, repository.ErrEquipmentUnavailable) {
+		t.Fatalf("booking more boards than free: want ErrEquipmentUnavailable, got %v", err)
+	}
+	if got, _ := s.GetSlot(overlapping.ID); got.Remaining != 4 {
+		t.Fatalf("failed booking took seats: %+v", got)
+	}
+	if got := f.availability(t, s, d); len(got) != 2 || got[1].Remaining != 3 {
+		t.Fatalf("later slot should keep all seats: %+v", got)
+	}
+	if _, err := s.RescheduleBooking(b.ID, later.ID, nil, 0, "test"); err != nil {
+		t.Fatalf("RescheduleBooking: %v", err)
+	}
+	if got := other.availability(t, s, d); len(got) != 1 || got[0].Remaining != 3 {
+		t.Fatalf("reschedule did not free the equipment: %+v", got)
+	}
+	if got := f.availability(t, s, d); got[1].Remaining != 1 {
+		t.Fatalf("reschedule did not move the equipment: %+v", got)
+	}
+	if _, err := s.PatchBookingStatus(b.ID, models.BookingCancelled, "test"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if free, _ := s.FreeEquipment(st.ID, later.StartAt, later.EndAt); free[models.EquipmentBoard] != 3 || free[models.EquipmentVest] != 2 {
+		t.Fatalf("cancel did not release the equipment: %+v", free)
+	}
+	if _, err := s.FreeEquipment(MissingID, d, d.Add(time.Hour)); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("FreeEquipment of a missing station: want ErrNotFound, got %v", err)
+	}
+}
+
+func testOptions(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	code := "opt_" + token()
+	o := models.Option{Code: code, Title: "Thermos", Price: 300, PriceType: models.OptionPerBooking, RouteIDs: []string{f.route.ID}, IsActive: true}
+	if err := s.UpsertOption(&o); err != nil || o.CreatedAt.IsZero() {
+		t.Fatalf("UpsertOption: %+v, %v", o, err)
+	}
+	o.Price, o.Equipment = 350, models.EquipmentDrybag
+	if err := s.UpsertOption(&o); err != nil {
+		t.Fatalf("UpsertOption update: %v", err)
+	}
+	got, err := s.GetOption(code)
+	if err != nil || got.Price != 350 || got.Equipment != models.EquipmentDrybag || got.PriceType != models.OptionPerBooking || len(got.RouteIDs) != 1 || !got.IsActive {
+		t.Fatalf("GetOption: %+v, %v", got, err)
+	}
+	all, err := s.ListOptions()
+	found := false
+	for _, item := range all {
+		found = found || item.Code == code
+	}
+	if err != nil || !found {
+		t.Fatalf("ListOptions: %+v, %v", all, err)
+	}
+	if err := s.DeleteOption(code); err != nil {
+		t.Fatalf("DeleteOption: %v", err)
+	}
+	if _, err := s.GetOption(code); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("deleted option: want ErrNotFound, got %v", err)
+	}
+	if err := s.DeleteOption(code); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("delete missing option: want ErrNotFound, got %v", err)
+	}
+}
+
+func testPromoCodes(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	code := "PROMO_" + strings.ToUpper(token())
+	until := day(30)
+	p := models.PromoCode{Code: code, Kind: models.PromoPercent, Value: 10, ValidTo: &until, MaxUses: 1, RouteIDs: []string{f.route.ID}, IsActive: true}
+	if err := s.UpsertPromoCode(&p); err != nil || p.CreatedAt.IsZero() || p.Used != 0 {
+		t.Fatalf("UpsertPromoCode: %+v, %v", p, err)
+	}
+	d := day(4)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 4)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slot := f.availability(t, s, d)[0]
+	b := models.Booking{SlotID: slot.ID, CustomerName: "Promo", Phone: "+79990000000", Participants: 1, PromoCode: code, PriceTotal: 4950}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking with promo: %v", err)
+	}
+	if got, err := s.GetBooking(b.ID); err != nil || got.PromoCode != code {
+		t.Fatalf("GetBooking promo_code: %+v, %v", got, err)
+	}
+	second := models.Booking{SlotID: slot.ID, CustomerName: "Late", Phone: "+79990000001", Participants: 1, PromoCode: code}
+	if err := s.CreateBooking(&second); !errors.Is(err, repository.ErrPromoExhausted) {
+		t.Fatalf("promo past max_uses: want ErrPromoExhausted, got %v", err)
+	}
+	if got := f.availability(t, s, d)[0]; got.Remaining != 3 {
+		t.Fatalf("rejected promo booking took seats: remaining %d", got.Remaining)
+	}
+	missing := models.Booking{SlotID: slot.ID, CustomerName: "Ghost", Phone: "+79990000002", Participants: 1, PromoCode: "NO_" + code}
+	if err := s.CreateBooking(&missing); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("unknown promo: want ErrNotFound, got %v", err)
+	}
+	p.MaxUses, p.Value = 5, 15
+	if err := s.UpsertPromoCode(&p); err != nil {
+		t.Fatalf("UpsertPromoCode update: %v", err)
+	}
+	got, err := s.GetPromoCode(code)
+	if err != nil || got.Used != 1 || got.MaxUses != 5 || got.Value != 15 || got.ValidTo == nil || !got.ValidTo.Equal(until) || len(got.RouteIDs) != 1 || len(got.InstructorIDs) != 0 {
+		t.Fatalf("GetPromoCode: %+v, %v", got, err)
+	}
+	all, err := s.ListPromoCodes()
+	found := false
+	for _, item := range all {
+		found = found || item.Code == code
+	}
+	if err != nil || !found {
+		t.Fatalf("ListPromoCodes: %+v, %v", all, err)
+	}
+	if err := s.DeletePromoCode(code); err != nil {
+		t.Fatalf("DeletePromoCode: %v", err)
+	}
+	if _, err := s.GetPromoCode(code); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("deleted promo: want ErrNotFound, got %v", err)
+	}
+	if got, err := s.GetBooking(b.ID); err != nil || got.PromoCode != code {
+		t.Fatalf("booking lost promo_code after delete: %+v, %v", got, err)
+	}
+}
+
+func testGiftCertificates(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	if err := s.CreateGift(&models.GiftCertificate{Kind: models.GiftRoute, RouteID: MissingID, Participants: 1, ExpiresAt: day(60)}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("gift for missing route: want ErrNotFound, got %v", err)
+	}
+	value := models.GiftCertificate{Kind: models.GiftValue, Amount: 1000, Balance: 1000, Recipient: "Anna", ExpiresAt: day(60)}
+	route := models.GiftCertificate{Kind: models.GiftRoute, RouteID: f.route.ID, Participants: 2, ExpiresAt: day(60)}
+	for _, g := range []*models.GiftCertificate{&value, &route} {
+		if err := s.CreateGift(g); err != nil || g.Code == "" || g.Status != models.GiftActive || g.CreatedAt.IsZero() {
+			t.Fatalf("CreateGift: %+v, %v", g, err)
+		}
+	}
+	if value.Code == route.Code {
+		t.Fatalf("CreateGift reused code %s", value.Code)
+	}
+	d := day(5)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 6)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slot := f.availability(t, s, d)[0]
+	book := func(name, code string, amount int) (models.Booking, error) {
+		b := models.Booking{SlotID: slot.ID, CustomerName: name, Phone: "+79990000000", Participants: 1, PriceTotal: 5500, GiftCode: code, GiftAmount: amount}
+		return b, s.CreateBooking(&b)
+	}
+	first, err := book("First", value.Code, 600)
+	if err != nil {
+		t.Fatalf("CreateBooking with gift: %v", err)
+	}
+	if got, err := s.GetBooking(first.ID); err != nil || got.GiftCode != value.Code || got.GiftAmount != 600 {
+		t.Fatalf("GetBooking gift: %+v, %v", got, err)
+	}
+	if got, _ := s.GetGift(value.Code); got.Balance != 400 || got.Status != models.GiftActive || got.Recipient != "Anna" {
+		t.Fatalf("gift after partial redemption: %+v", got)
+	}
+	if _, err := book("Short", value.Code, 500); !errors.Is(err, repository.ErrGiftBalance) {
+		t.Fatalf("redeem past balance: want ErrGiftBalance, got %v", err)
+	}
+	if got := f.availability(t, s, d)[0]; got.Remaining != 5 {
+		t.Fatalf("rejected gift booking took seats: remaining %d", got.Remaining)
+	}
+	if list, err := s.ListGiftRedemptions(value.Code); err != nil || len(list) != 1 || list[0].BookingID != first.ID || list[0].Amount != 600 {
+		t.Fatalf("ListGiftRedemptions: %+v, %v", list, err)
+	}
+	if _, err := s.PatchBookingStatus(first.ID, models.BookingCancelled, "test"); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if got, _ := s.GetGift(value.Code); got.Balance != 1000 || got.Status != models.GiftActive {
+		t.Fatalf("gift after cancelled booking: %+v", got)
+	}
+	if list, _ := s.ListGiftRedemptions(value.Code); len(list) != 0 {
+		t.Fatalf("redemption kept after cancel: %+v", list)
+	}
+	late, err := book("Late", value.Code, 600)
+	if err != nil {
+		t.Fatalf("CreateBooking with gift: %v", err)
+	}
+	if got, _, err := s.CancelBooking(late.ID, "late", models.Refund{GiftPercent: 50, Rule: "late"}); err != nil || got.GiftRefund != 300 {
+		t.Fatalf("CancelBooking at 50%%: %+v, %v", got, err)
+	}
+	if got, _ := s.GetGift(value.Code); got.Balance != 700 || got.Status != models.GiftActive {
+		t.Fatalf("gift after a 50%% cancellation: %+v", got)
+	}
+	if got, _ := s.GetBooking(late.ID); got.GiftRefund != 300 || got.RefundRule != "late" {
+		t.Fatalf("gift refund not persisted: %+v", got)
+	}
+	moved, err := book("Moved", value.Code, 600)
+	if err != nil {
+		t.Fatalf("CreateBooking with gift: %v", err)
+	}
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(13*time.Hour), 6)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	later := f.availability(t, s, d)[1]
+	cheaper := models.PriceBreakdown{Participants: 1, PerPerson: 400, Total: 400}
+	if got, err := s.RescheduleBooking(moved.ID, later.ID, &cheaper, 400, "cheaper"); err != nil || got.GiftAmount != 400 || got.PriceTotal != 400 {
+		t.Fatalf("RescheduleBooking with a smaller gift amount: %+v, %v", got, err)
+	}
+	if got, _ := s.GetGift(value.Code); got.Balance != 300 {
+		t.Fatalf("gift after a cheaper reschedule: balance %d, want 300", got.Balance)
+	}
+	if list, _ := s.ListGiftRedemptions(value.Code); len(list) != 1 || list[0].Amount != 400 {
+		t.Fatalf("redemption after a cheaper reschedule: %+v", list)
+	}
+	routeLate, err := book("RouteLate", route.Code, 11000)
+	if err != nil {
+		t.Fatalf("CreateBooking with route gift: %v", err)
+	}
+	if got, _, err := s.CancelBooking(routeLate.ID, "late", models.Refund{GiftPercent: 50, Rule: "late"}); err != nil || got.GiftRefund != 0 {
+		t.Fatalf("CancelBooking of a route gift at 50%%: %+v, %v", got, err)
+	}
+	if got, _ := s.GetGift(route.Code); got.Status != models.GiftRedeemed {
+		t.Fatalf("route gift came back on a partial refund: %+v", got)
+	}
+	if _, err := book("Again", route.Code, 11000); !errors.Is(err, repository.ErrGiftClosed) {
+		t.Fatalf("reuse route gift: want ErrGiftClosed, got %v", err)
+	}
+	if _, err := s.VoidGift(route.Code); !errors.Is(err, repository.ErrGiftClosed) {
+		t.Fatalf("void redeemed gift: want ErrGiftClosed, got %v", err)
+	}
+	if got, err := s.VoidGift(value.Code); err != nil || got.Status != models.GiftVoided {
+		t.Fatalf("VoidGift: %+v, %v", got, err)
+	}
+	if _, err := book("Void", value.Code, 100); !errors.Is(err, repository.ErrGiftClosed) {
+		t.Fatalf("redeem voided gift: want ErrGiftClosed, got %v", err)
+	}
+	if _, err := book("Ghost", "GIFT-NONE-0000", 100); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("unknown gift: want ErrNotFound, got %v", err)
+	}
+	voided, err := s.ListGifts(models.GiftVoided)
+	found := false
+	for _, g := range voided {
+		found = found || g.Code == value.Code
+		if g.Status != models.GiftVoided {
+			t.Fatalf("ListGifts(voided) returned %+v", g)
+		}
+	}
+	if err != nil || !found {
+		t.Fatalf("ListGifts: %+v, %v", voided, err)
+	}
+	if _, err := s.GetGift("GIFT-NONE-0000"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetGift missing: want ErrNotFound, got %v", err)
+	}
+}
+
+func testPayments(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	if err := s.CreatePayment(&models.Payment{BookingID: MissingID, Provider: "fake", ProviderRef: "ref-" + token(), Mode: models.PaymentFull, Amount: 100, Status: models.PaymentPending}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("payment for missing booking: want ErrNotFound, got %v", err)
+	}
+	d := day(6)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 4)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	b := models.Booking{SlotID: f.availability(t, s, d)[0].ID, CustomerName: "Payer", Phone: "+79990000000", Participants: 1, PriceTotal: 5500}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	ref := "ref-" + token()
+	p := models.Payment{BookingID: b.ID, Provider: "fake", ProviderRef: ref, Mode: models.PaymentDeposit, Amount: 1000, Status: models.PaymentPending, ConfirmationURL: "https://pay.example/" + ref}
+	if err := s.CreatePayment(&p); err != nil || p.ID == "" || p.CreatedAt.IsZero() {
+		t.Fatalf("CreatePayment: %+v, %v", p, err)
+	}
+	if got, err := s.FindPayment("fake", ref); err != nil || got.ID != p.ID || got.ConfirmationURL != p.ConfirmationURL || got.Mode != models.PaymentDeposit {
+		t.Fatalf("FindPayment: %+v, %v", got, err)
+	}
+	if _, err := s.FindPayment("other", ref); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("FindPayment other provider: want ErrNotFound, got %v", err)
+	}
+	if _, err := s.AddPaymentRefund(p.ID, 100); !errors.Is(err, repository.ErrPaymentClosed) {
+		t.Fatalf("refund pending payment: want ErrPaymentClosed, got %v", err)
+	}
+	if got, err := s.SetPaymentStatus(p.ID, models.PaymentPending, models.PaymentSucceeded); err != nil || got.Status != models.PaymentSucceeded {
+		t.Fatalf("SetPaymentStatus: %+v, %v", got, err)
+	}
+	if got, err := s.SetPaymentStatus(p.ID, models.PaymentPending, models.PaymentFailed); !errors.Is(err, repository.ErrPaymentClosed) || got.Status != models.PaymentSucceeded {
+		t.Fatalf("repeated SetPaymentStatus: want ErrPaymentClosed with current payment, got %+v, %v", got, err)
+	}
+	if got, err := s.AddPaymentRefund(p.ID, 300); err != nil || got.Refunded != 300 || got.Status != models.PaymentSucceeded {
+		t.Fatalf("partial refund: %+v, %v", got, err)
+	}
+	if _, err := s.AddPaymentRefund(p.ID, 800); !errors.Is(err, repository.ErrRefundTooLarge) {
+		t.Fatalf("refund past amount: want ErrRefundTooLarge, got %v", err)
+	}
+	if got, err := s.AddPaymentRefund(p.ID, 700); err != nil || got.Refunded != 1000 || got.Status != models.PaymentRefunded {
+		t.Fatalf("full refund: %+v, %v", got, err)
+	}
+	if got, err := s.UndoPaymentRefund(p.ID, 700); err != nil || got.Refunded != 300 || got.Status != models.PaymentSucceeded {
+		t.Fatalf("undo refund: %+v, %v", got, err)
+	}
+	if _, err := s.UndoPaymentRefund(p.ID, 400); !errors.Is(err, repository.ErrRefundTooLarge) {
+		t.Fatalf("undo past refunded: want ErrRefundTooLarge, got %v", err)
+	}
+	if got, err := s.AddPaymentRefund(p.ID, 700); err != nil || got.Refunded != 1000 || got.Status != models.PaymentRefunded {
+		t.Fatalf("refund again: %+v, %v", got, err)
+	}
+	list, err := s.ListBookingPayments(b.ID)
+	if err != nil || len(list) != 1 || list[0].ID != p.ID || list[0].Refunded != 1000 {
+		t.Fatalf("ListBookingPayments: %+v, %v", list, err)
+	}
+	if _, err := s.GetPayment(MissingID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetPayment missing: want ErrNotFound, got %v", err)
+	}
+}
+
+func testScheduleTemplates(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	if err := s.UpsertTemplate(&models.ScheduleTemplate{InstructorID: MissingID, RouteID: f.route.ID, Weekdays: []int{1}, Times: []string{"09:00"}, Capacity: 4, Timezone: "UTC"}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("template for missing instructor: want ErrNotFound, got %v", err)
+	}
+	tpl := models.ScheduleTemplate{InstructorID: f.instructor.ID, RouteID: f.route.ID, Weekdays: []int{1, 2, 3}, Times: []string{"09:00", "17:00"}, Capacity: 6, ValidFrom: "2030-05-01", ValidTo: "2030-09-30", Timezone: "Europe/Moscow", IsActive: true}
+	if err := s.UpsertTemplate(&tpl); err != nil || tpl.ID == "" {
+		t.Fatalf("UpsertTemplate: %+v, %v", tpl, err)
+	}
+	tpl.Capacity = 8
+	tpl.ValidTo = ""
+	if err := s.UpsertTemplate(&tpl); err != nil {
+		t.Fatalf("UpsertTemplate update: %v", err)
+	}
+	got, err := s.GetTemplate(tpl.ID)
+	if err != nil || got.Capacity != 8 || got.ValidFrom != "2030-05-01" || got.ValidTo != "" || len(got.Weekdays) != 3 || got.Times[1] != "17:00" {
+		t.Fatalf("GetTemplate: %+v, %v", got, err)
+	}
+	all, err := s.ListTemplates()
+	found := false
+	for _, item := range all {
+		found = found || item.ID == tpl.ID
+	}
+	if err != nil || !found {
+		t.Fatalf("ListTemplates: %+v, %v", all, err)
+	}
+	if err := s.DeleteTemplate(tpl.ID); err != nil {
+		t.Fatalf("DeleteTemplate: %v", err)
+	}
+	if _, err := s.GetTemplate(tpl.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("deleted template: want ErrNotFound, got %v", err)
+	}
+
+	d := day(12)
+	batch := []models.TimeSlot{f.slot(d.Add(9*time.Hour), 6), f.slot(d.Add(17*time.Hour), 6)}
+	created, err := s.CreateSlotsIfAbsent(batch)
+	if err != nil || len(created) != 2 || created[0].ID == "" || created[0].Remaining != 6 || created[0].Status != "open" {
+		t.Fatalf("CreateSlotsIfAbsent: %+v, %v", created, err)
+	}
+	again, err := s.CreateSlotsIfAbsent(append(batch, f.slot(d.Add(12*time.Hour), 6)))
+	if err != nil || len(again) != 1 || !again[0].StartAt.Equal(d.Add(12*time.Hour)) {
+		t.Fatalf("CreateSlotsIfAbsent rerun: %+v, %v", again, err)
+	}
+	if got, _ := s.ListInstructorSlots(f.instructor.ID, d, d.Add(24*time.Hour)); len(got) != 3 {
+		t.Fatalf("slots after two runs: %+v", got)
+	}
+	if clashing, err := s.CreateSlotsIfAbsent([]models.TimeSlot{f.slot(d.Add(10*time.Hour), 6)}); err != nil || len(clashing) != 0 {
+		t.Fatalf("CreateSlotsIfAbsent over an overlapping slot: %+v, %v", clashing, err)
+	}
+}
+
+func testSeatHolds(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	d := day(6)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 4)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slot := f.availability(t, s, d)[0]
+	if _, err := s.CreateHold(slot.ID, 5, time.Minute); !errors.Is(err, repository.ErrSlotUnavailable) {
+		t.Fatalf("hold above capacity: want ErrSlotUnavailable, got %v", err)
+	}
+	h, err := s.CreateHold(slot.ID, 3, time.Minute)
+	if err != nil || h.Token == "" || h.Seats != 3 {
+		t.Fatalf("CreateHold: %+v, %v", h, err)
+	}
+	if got, _ := s.GetSlot(slot.ID); got.Remaining != 1 {
+		t.Fatalf("hold did not take seats: %+v", got)
+	}
+	if got, err := s.GetHold(h.Token); err != nil || got.SlotID != slot.ID {
+		t.Fatalf("GetHold: %+v, %v", got, err)
+	}
+	tooMany := models.Booking{SlotID: slot.ID, HoldToken: h.Token, CustomerName: "Ivan", Phone: "+79990000000", Participants: 4}
+	if err := s.CreateBooking(&tooMany); !errors.Is(err, repository.ErrHoldMismatch) {
+		t.Fatalf("booking more seats than held: want ErrHoldMismatch, got %v", err)
+	}
+	b := models.Booking{SlotID: slot.ID, HoldToken: h.Token, CustomerName: "Ivan", Phone: "+79990000000", Participants: 2}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking with hold: %v", err)
+	}
+	if got, _ := s.GetSlot(slot.ID); got.Remaining != 2 {
+		t.Fatalf("unused held seat was not returned: %+v", got)
+	}
+	if err := s.CreateBooking(&models.Booking{SlotID: slot.ID, HoldToken: h.Token, CustomerName: "Ivan", Phone: "+79990000000", Participants: 1}); !errors.Is(err, repository.ErrHoldNotFound) {
+		t.Fatalf("reusing a consumed hold: want ErrHoldNotFound, got %v", err)
+	}
+
+	expiring, err := s.CreateHold(slot.ID, 2, time.Millisecond)
+	if err != nil {
+		t.Fatalf("CreateHold: %v", err)
+	}
+	if got, _ := s.GetSlot(slot.ID); got.Remaining != 0 || got.Status != "closed" {
+		t.Fatalf("slot fully held: %+v", got)
+	}
+	time.Sleep(5 * time.Millisecond)
+	late := models.Booking{SlotID: slot.ID, HoldToken: expiring.Token, CustomerName: "Ivan", Phone: "+79990000000", Participants: 2}
+	if err := s.CreateBooking(&late); !errors.Is(err, repository.ErrHoldExpired) {
+		t.Fatalf("booking with expired hold: want ErrHoldExpired, got %v", err)
+	}
+	released, err := s.ReleaseExpiredHolds(time.Now().UTC())
+	if err != nil {
+		t.Fatalf("ReleaseExpiredHolds: %v", err)
+	}
+	found := false
+	for _, r := range released {
+		found = found || r.Token == expiring.Token
+	}
+	if !found {
+		t.Fatalf("ReleaseExpiredHolds did not release %s: %+v", expiring.Token, released)
+	}
+	if got, _ := s.GetSlot(slot.ID); got.Remaining != 2 || got.Status != "open" {
+		t.Fatalf("expired hold seats not returned: %+v", got)
+	}
+
+	manual, err := s.CreateHold(slot.ID, 1, time.Minute)
+	if err != nil {
+		t.Fatalf("CreateHold: %v", err)
+	}
+	if err := s.ReleaseHold(manual.Token); err != nil {
+		t.Fatalf("ReleaseHold: %v", err)
+	}
+	if got, _ := s.GetSlot(slot.ID); got.Remaining != 2 {
+		t.Fatalf("ReleaseHold did not return seats: %+v", got)
+	}
+	if err := s.ReleaseHold(manual.Token); !errors.Is(err, repository.ErrHoldNotFound) {
+		t.Fatalf("ReleaseHold twice: want ErrHoldNotFound, got %v", err)
+	}
+}
+
+func testIdempotencyKeys(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	d := day(7)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 4)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	b := models.Booking{SlotID: f.availability(t, s, d)[0].ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 1}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	key := "key-" + token()
+	k, reserved, err := s.ReserveIdempotencyKey(key, "hash-a", time.Hour)
+	if err != nil || !reserved || k.BookingID != "" {
+		t.Fatalf("first ReserveIdempotencyKey: %+v, %v, %v", k, reserved, err)
+	}
+	k, reserved, err = s.ReserveIdempotencyKey(key, "hash-b", time.Hour)
+	if err != nil || reserved || k.RequestHash != "hash-a" || k.BookingID != "" {
+		t.Fatalf("in-flight ReserveIdempotencyKey: %+v, %v, %v", k, reserved, err)
+	}
+	if err := s.CompleteIdempotencyKey(key, b.ID); err != nil {
+		t.Fatalf("CompleteIdempotencyKey: %v", err)
+	}
+	k, reserved, err = s.ReserveIdempotencyKey(key, "hash-a", time.Hour)
+	if err != nil || reserved || k.BookingID != b.ID {
+		t.Fatalf("replayed ReserveIdempotencyKey: %+v, %v, %v", k, reserved, err)
+	}
+
+	failed := "key-" + token()
+	if _, _, err := s.ReserveIdempotencyKey(failed, "hash-a", time.Hour); err != nil {
+		t.Fatalf("ReserveIdempotencyKey: %v", err)
+	}
+	if err := s.ReleaseIdempotencyKey(failed); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey: %v", err)
+	}
+	if _, reserved, _ := s.ReserveIdempotencyKey(failed, "hash-b", time.Hour); !reserved {
+		t.Fatalf("released key could not be reserved again")
+	}
+
+	short := "key-" + token()
+	if _, _, err := s.ReserveIdempotencyKey(short, "hash-a", time.Millisecond); err != nil {
+		t.Fatalf("ReserveIdempotencyKey: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, reserved, _ := s.ReserveIdempotencyKey(short, "hash-b", time.Millisecond); !reserved {
+		t.Fatalf("expired key was not reusable")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if n, err := s.PurgeIdempotencyKeys(time.Now().UTC()); err != nil || n < 1 {
+		t.Fatalf("PurgeIdempotencyKeys: %d, %v", n, err)
+	}
+	if k, _, _ := s.ReserveIdempotencyKey(key, "hash-a", time.Hour); k.BookingID != b.ID {
+		t.Fatalf("purge removed a live key: %+v", k)
+	}
+}
+
+func testWaitlist(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	d := day(8)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 3)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slot := f.availability(t, s, d)[0]
+	full := models.Booking{SlotID: slot.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 3}
+	if err := s.CreateBooking(&full); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	if got := f.availability(t, s, d); len(got) != 0 {
+		t.Fatalf("full slot listed without include_full: %+v", got)
+	}
+	if got, err := s.ListAvailability(d, f.route.ID, f.instructor.ID, true); err != nil || len(got) != 1 || got[0].Remaining != 0 {
+		t.Fatalf("ListAvailability include_full: %+v, %v", got, err)
+	}
+	if err := s.JoinWaitlist(&models.WaitlistEntry{SlotID: MissingID, CustomerName: "X", Phone: "+7", Participants: 1}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("join missing slot: want ErrNotFound, got %v", err)
+	}
+	join := func(name string, participants int) models.WaitlistEntry {
+		t.Helper()
+		e := models.WaitlistEntry{SlotID: slot.ID, CustomerName: name, Phone: "+79990000001", Participants: participants}
+		if err := s.JoinWaitlist(&e); err != nil || e.ID == "" || e.Status != models.WaitlistWaiting {
+			t.Fatalf("JoinWaitlist: %+v, %v", e, err)
+		}
+		return e
+	}
+	a, b, c := join("A", 2), join("B", 1), join("C", 3)
+	if got, err := s.OfferWaitlist(slot.ID, time.Minute); err != nil || len(got) != 0 {
+		t.Fatalf("offer on a full slot: %+v, %v", got, err)
+	}
+	if _, err := s.PatchBookingStatus(full.ID, models.BookingCancelled, ""); err != nil {
+		t.Fatalf("PatchBookingStatus: %v", err)
+	}
+	offered, err := s.OfferWaitlist(slot.ID, time.Minute)
+	if err != nil || len(offered) != 2 || offered[0].ID != a.ID || offered[1].ID != b.ID {
+		t.Fatalf("OfferWaitlist: want A then B, got %+v, %v", offered, err)
+	}
+	for _, e := range offered {
+		if e.Status != models.WaitlistOffered || e.HoldToken == "" || e.OfferExpiresAt == nil {
+			t.Fatalf("offered entry: %+v", e)
+		}
+	}
+	if got, _ := s.GetSlot(slot.ID); got.Remaining != 0 {
+		t.Fatalf("offers did not hold seats: %+v", got)
+	}
+
+	accepted := models.Booking{SlotID: slot.ID, HoldToken: offered[0].HoldToken, CustomerName: "A", Phone: "+79990000001", Participants: 2}
+	if err := s.CreateBooking(&accepted); err != nil {
+		t.Fatalf("CreateBooking with offer hold: %v", err)
+	}
+	if got, _ := s.GetWaitlistEntry(a.ID); got.Status != models.WaitlistAccepted || got.BookingID != accepted.ID {
+		t.Fatalf("booked offer not accepted: %+v", got)
+	}
+	if _, err := s.CancelWaitlistEntry(a.ID); !errors.Is(err, repository.ErrWaitlistClosed) {
+		t.Fatalf("cancel accepted entry: want ErrWaitlistClosed, got %v", err)
+	}
+
+	if got, err := s.CancelWaitlistEntry(b.ID); err != nil || got.Status != models.WaitlistCancelled {
+		t.Fatalf("CancelWaitlistEntry: %+v, %v", got, err)
+	}
+	if got, _ := s.GetSlot(slot.ID); got.Remaining != 1 || got.Status != "open" {
+		t.Fatalf("cancelled offer did not return seats: %+v", got)
+	}
+
+	dd := join("D", 1)
+	offered, err = s.OfferWaitlist(slot.ID, time.Millisecond)
+	if err != nil || len(offered) != 1 || offered[0].ID != dd.ID {
+		t.Fatalf("OfferWaitlist: want D only (C does not fit), got %+v, %v", offered, err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := s.ReleaseExpiredHolds(time.Now().UTC()); err != nil {
+		t.Fatalf("ReleaseExpiredHolds: %v", err)
+	}
+	if got, _ := s.GetWaitlistEntry(dd.ID); got.Status != models.WaitlistExpired {
+		t.Fatalf("expired offer: %+v", got)
+	}
+
+	queue, err := s.ListWaitlist(slot.ID)
+	if err != nil || len(queue) != 4 || queue[0].ID != a.ID || queue[2].ID != c.ID {
+		t.Fatalf("ListWaitlist: %+v, %v", queue, err)
+	}
+	if got, err := s.OfferWaitlistEntry(c.ID, time.Minute); !errors.Is(err, repository.ErrSlotUnavailable) {
+		t.Fatalf("offer more seats than free: want ErrSlotUnavailable, got %+v, %v", got, err)
+	}
+}
+
+// testWaitlistConcurrent races offers, cancellations and hold releases on
+// one slot: none may fail with anything but a closed entry or a full slot
+// (a deadlock would), and once every hold is gone all seats are back.
+func testWaitlistConcurrent(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	d := day(9)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 4)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slot := f.availability(t, s, d)[0]
+	full := models.Booking{SlotID: slot.ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 4}
+	if err := s.CreateBooking(&full); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	entries := []models.WaitlistEntry{}
+	for i := 0; i < 6; i++ {
+		e := models.WaitlistEntry{SlotID: slot.ID, CustomerName: "W", Phone: "+79990000001", Participants: 1}
+		if err := s.JoinWaitlist(&e); err != nil {
+			t.Fatalf("JoinWaitlist: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	if _, err := s.PatchBookingStatus(full.ID, models.BookingCancelled, ""); err != nil {
+		t.Fatalf("PatchBookingStatus: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	run := func(fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(); err != nil && !errors.Is(err, repository.ErrWaitlistClosed) && !errors.Is(err, repository.ErrSlotUnavailable) {
+				errs <- err
+			}
+		}()
+	}
+	for i, e := range entries {
+		id := e.ID
+		run(func() error { _, err := s.OfferWaitlist(slot.ID, time.Millisecond); return err })
+		run(func() error { _, err := s.OfferWaitlistEntry(id, time.Minute); return err })
+		if i%2 == 0 {
+			run(func() error { _, err := s.CancelWaitlistEntry(id); return err })
+		}
+		run(func() error { _, err := s.ReleaseExpiredHolds(time.Now().UTC()); return err })
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent waitlist call: %v", err)
+	}
+	if _, err := s.ReleaseExpiredHolds(time.Now().UTC().Add(time.Hour)); err != nil {
+		t.Fatalf("ReleaseExpiredHolds: %v", err)
+	}
+	if got, _ := s.GetSlot(slot.ID); got.Remaining != 4 || got.Status != "open" {
+		t.Fatalf("seats after every hold is released: %+v, want 4 free", got)
+	}
+}
+
+// testBookingsConcurrent races bookings, reschedules and cancels that share
+// two slots, a station, a gift certificate and a promo code. A store taking
+// those locks in different orders deadlocks here.
+func testBookingsConcurrent(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	st := models.Station{Title: "Station " + token(), Stock: map[string]int{models.EquipmentBoard: 100}}
+	if err := s.UpsertStation(&st); err != nil {
+		t.Fatalf("UpsertStation: %v", err)
+	}
+	f.route.StationID = st.ID
+	if err := s.UpsertRoute(&f.route); err != nil {
+		t.Fatalf("UpsertRoute: %v", err)
+	}
+	gift := models.GiftCertificate{Kind: models.GiftValue, Amount: 100000, Balance: 100000, ExpiresAt: day(60)}
+	if err := s.CreateGift(&gift); err != nil {
+		t.Fatalf("CreateGift: %v", err)
+	}
+	promo := models.PromoCode{Code: "RACE_" + strings.ToUpper(token()), Kind: models.PromoPercent, Value: 10, IsActive: true}
+	if err := s.UpsertPromoCode(&promo); err != nil {
+		t.Fatalf("UpsertPromoCode: %v", err)
+	}
+	d := day(11)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 40), f.slot(d.Add(12*time.Hour), 40)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slots := f.availability(t, s, d)
+	book := func(slotID string) (models.Booking, error) {
+		b := models.Booking{SlotID: slotID, CustomerName: "Race", Phone: "+79990000000", Participants: 1, PriceTotal: 4950, PromoCode: promo.Code, GiftCode: gift.Code, GiftAmount: 1000}
+		return b, s.CreateBooking(&b)
+	}
+	existing := []models.Booking{}
+	for i := 0; i < 8; i++ {
+		b, err := book(slots[i%2].ID)
+		if err != nil {
+			t.Fatalf("CreateBooking: %v", err)
+		}
+		existing = append(existing, b)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	run := func(fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(); err != nil && !errors.Is(err, repository.ErrInvalidTransition) {
+				errs <- err
+			}
+		}()
+	}
+	for i, b := range existing {
+		id, other := b.ID, slots[(i+1)%2].ID
+		run(func() error { _, err := book(slots[i%2].ID); return err })
+		run(func() error {
+			_, err := s.RescheduleBooking(id, other, &models.PriceBreakdown{Total: 4950}, 500, "race")
+			return err
+		})
+		if i%2 == 0 {
+			run(func() error { _, err := s.PatchBookingStatus(id, models.BookingCancelled, "race"); return err })
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent booking call: %v", err)
+	}
+
+	taken := map[string]int{}
+	redeemed := 0
+	for _, slot := range slots {
+		list, err := s.ListSlotBookings([]string{slot.ID})
+		if err != nil {
+			t.Fatalf("ListSlotBookings: %v", err)
+		}
+		for _, b := range list {
+			if b.Status != models.BookingCancelled {
+				taken[slot.ID] += b.Participants
+				redeemed += b.GiftAmount
+			}
+		}
+	}
+	for _, slot := range slots {
+		if got, _ := s.GetSlot(slot.ID); got.Remaining != got.Capacity-taken[slot.ID] {
+			t.Errorf("slot %s: remaining %d with %d seats booked of %d", slot.ID, got.Remaining, taken[slot.ID], got.Capacity)
+		}
+	}
+	if got, _ := s.GetGift(gift.Code); got.Balance != gift.Amount-redeemed {
+		t.Errorf("gift balance %d, want %d: %d redeemed by live bookings", got.Balance, gift.Amount-redeemed, redeemed)
+	}
+}
+
+func testRescheduleOffers(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	d := day(10)
+	if err := s.BulkCreateSlots([]models.TimeSlot{f.slot(d.Add(9*time.Hour), 4), f.slot(d.Add(12*time.Hour), 4)}); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	slots := f.availability(t, s, d)
+	b := models.Booking{SlotID: slots[0].ID, CustomerName: "Ivan", Phone: "+79990000000", Participants: 2}
+	if err := s.CreateBooking(&b); err != nil {
+		t.Fatalf("CreateBooking: %v", err)
+	}
+	if _, err := s.PatchBookingStatus(b.ID, models.BookingConfirmed, ""); err != nil {
+		t.Fatalf("PatchBookingStatus: %v", err)
+	}
+	upcoming, err := s.ListBookingsStarting(d, d.Add(24*time.Hour), models.BookingConfirmed)
+	if err != nil || len(upcoming) != 1 || upcoming[0].ID != b.ID {
+		t.Fatalf("ListBookingsStarting: %+v, %v", upcoming, err)
+	}
+	if got, _ := s.ListBookingsStarting(d.Add(10*time.Hour), d.Add(24*time.Hour), models.BookingConfirmed); len(got) != 0 {
+		t.Fatalf("ListBookingsStarting outside window: %+v", got)
+	}
+	if got, _ := s.ListBookingsStarting(d, d.Add(24*time.Hour), models.BookingPending); len(got) != 0 {
+		t.Fatalf("ListBookingsStarting other status: %+v", got)
+	}
+
+	if err := s.CreateRescheduleOffer(&models.RescheduleOffer{BookingID: MissingID, SlotIDs: []string{slots[1].ID}, ExpiresAt: slots[0].StartAt}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("offer for missing booking: want ErrNotFound, got %v", err)
+	}
+	o := models.RescheduleOffer{BookingID: b.ID, SlotIDs: []string{slots[1].ID}, ConditionsLevel: "Плохие", Score: 20, Explanation: "wind", ExpiresAt: slots[0].StartAt}
+	if err := s.CreateRescheduleOffer(&o); err != nil || o.Token == "" || o.Status != models.OfferPending {
+		t.Fatalf("CreateRescheduleOffer: %+v, %v", o, err)
+	}
+	if err := s.CreateRescheduleOffer(&models.RescheduleOffer{BookingID: b.ID, SlotIDs: []string{slots[1].ID}, ExpiresAt: slots[0].StartAt}); !errors.Is(err, repository.ErrOfferExists) {
+		t.Fatalf("second pending offer: want ErrOfferExists, got %v", err)
+	}
+	if got, err := s.GetRescheduleOffer(o.Token); err != nil || got.BookingID != b.ID || len(got.SlotIDs) != 1 || got.SlotIDs[0] != slots[1].ID {
+		t.Fatalf("GetRescheduleOffer: %+v, %v", got, err)
+	}
+	if _, err := s.GetRescheduleOffer("missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetRescheduleOffer missing: want ErrNotFound, got %v", err)
+	}
+	if got, err := s.SetRescheduleOfferStatus(o.Token, models.OfferPending, models.OfferAccepted, slots[1].ID); err != nil || got.Status != models.OfferAccepted || got.ChosenSlotID != slots[1].ID {
+		t.Fatalf("SetRescheduleOfferStatus: %+v, %v", got, err)
+	}
+	if _, err := s.SetRescheduleOfferStatus(o.Token, models.OfferPending, models.OfferDeclined, ""); !errors.Is(err, repository.ErrOfferClosed) {
+		t.Fatalf("resolving twice: want ErrOfferClosed, got %v", err)
+	}
+	if _, err := s.SetRescheduleOfferStatus("missing", models.OfferPending, models.OfferDeclined, ""); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("resolving missing offer: want ErrNotFound, got %v", err)
+	}
+	pending, err := s.ListRescheduleOffers(models.OfferPending)
+	if err != nil {
+		t.Fatalf("ListRescheduleOffers: %v", err)
+	}
+	for _, p := range pending {
+		if p.Token == o.Token {
+			t.Fatalf("accepted offer listed as pending: %+v", p)
+		}
+	}
+	if err := s.CreateRescheduleOffer(&models.RescheduleOffer{BookingID: b.ID, SlotIDs: []string{slots[1].ID}, ExpiresAt: slots[0].StartAt}); err != nil {
+		t.Fatalf("new offer after the previous one was resolved: %v", err)
+	}
+}
+
+func testAPIKeys(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	if err := s.CreateAPIKey(&models.APIKey{Name: "ghost", Role: models.RoleInstructor, InstructorID: MissingID, KeyHash: token()}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("key for missing instructor: want ErrNotFound, got %v", err)
+	}
+	hash := token()
+	k := models.APIKey{Name: "Instructor", Role: models.RoleInstructor, InstructorID: f.instructor.ID, KeyHash: hash}
+	if err := s.CreateAPIKey(&k); err != nil || k.ID == "" {
+		t.Fatalf("CreateAPIKey: %+v, %v", k, err)
+	}
+	if got, err := s.FindAPIKey(hash); err != nil || got.ID != k.ID || got.Role != models.RoleInstructor || got.InstructorID != f.instructor.ID {
+		t.Fatalf("FindAPIKey: %+v, %v", got, err)
+	}
+	if _, err := s.FindAPIKey(token()); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("FindAPIKey unknown: want ErrNotFound, got %v", err)
+	}
+	if err := s.RevokeAPIKey(k.ID); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if _, err := s.FindAPIKey(hash); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("revoked key still authenticates: %v", err)
+	}
+	if err := s.RevokeAPIKey(MissingID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("RevokeAPIKey missing: want ErrNotFound, got %v", err)
+	}
+	keys, err := s.ListAPIKeys()
+	if err != nil {
+		t.Fatalf("ListAPIKeys: %v", err)
+	}
+	for _, got := range keys {
+		if got.ID == k.ID && got.RevokedAt != nil {
+			return
+		}
+	}
+	t.Fatalf("revoked key missing from ListAPIKeys: %+v", keys)
+}
+
+func testSuggestedSlots(t *testing.T, s repository.Repository) {
+	f := newFixture(t, s)
+	d := day(4)
+	target := d.Add(12 * time.Hour)
+	slots := []models.TimeSlot{f.slot(d.Add(6*time.Hour), 4), f.slot(d.Add(11*time.Hour), 4), f.slot(d.Add(14*time.Hour), 4), f.slot(d.Add(17*time.Hour), 4)}
+	if err := s.BulkCreateSlots(slots); err != nil {
+		t.Fatalf("BulkCreateSlots: %v", err)
+	}
+	got, err := s.SuggestedSlots(target, f.route.ID, f.instructor.ID, 2)
+	if err != nil {
+		t.Fatalf("SuggestedSlots: %v", err)
+	}
+	if len(got) != 2 || !got[0].StartAt.Equal(d.Add(11*time.Hour)) || !got[1].StartAt.Equal(d.Add(14*time.Hour)) {
+		t.Fatalf("SuggestedSlots: want 11:00 and 14:00 closest to noon, got %+v", got)
+	}
+}
+
+func testWeatherCache(t *testing.T, s repository.Repository) {
+	lat, lng := 10.5, 20.25
+	from := day(6).Add(9 * time.Hour)
+	waves := 0.4
+	snap := models.WeatherSnapshot{LocationLat: lat, LocationLng: lng, TimeFrom: from, TimeTo: from.Add(time.Hour), Temperature: 24, WindSpeed: 3, WindGusts: 7.5, WindDirection: 315, WaveHeight: &waves, Provider: "met-norway", ConditionsLevel: "Отличные", Score: 90, Raw: map[string]any{}, FetchedAt: time.Now().UTC()}
+	if err := s.SaveWeatherSnapshot(&snap); err != nil {
+		t.Fatalf("SaveWeatherSnapshot: %v", err)
+	}
+	if snap.ID == "" {
+		t.Fatalf("SaveWeatherSnapshot did not assign id")
+	}
+	got, err := s.FindWeatherSnapshot(lat, lng, from, time.Hour)
+	if err != nil {
+		t.Fatalf("FindWeatherSnapshot: %v", err)
+	}
+	if got.Score != 90 || got.ConditionsLevel != "Отличные" || got.Temperature != 24 || got.WindGusts != 7.5 || got.WindDirection != 315 ||
+		got.WaveHeight == nil || *got.WaveHeight != 0.4 || got.WavePeriod != nil || got.SeaTemperature != nil || got.Provider != "met-norway" {
+		t.Fatalf("FindWeatherSnapshot round trip: %+v", got)
+	}
+	if _, err := s.FindWeatherSnapshot(lat, lng, from.Add(time.Hour), time.Hour); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("FindWeatherSnapshot other hour: want ErrNotFound, got %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := s.FindWeatherSnapshot(lat, lng, from, time.Millisecond); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("FindWeatherSnapshot expired: want ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+var (
+	ErrUnauthorized = errors.New("missing or invalid API key")
+	ErrInvalidRole  = errors.New("invalid role")
+)
+
+type AuthService struct {
+	keys        repository.APIKeyStore
+	instructors repository.InstructorStore
+	ownerKey    string
+}
+
+// NewAuthService takes the ADMIN_API_KEY bootstrap key: it always
+// authenticates as owner, so the first real keys can be issued with it.
+func NewAuthService(keys repository.APIKeyStore, instructors repository.InstructorStore, ownerKey string) *AuthService {
+	return &AuthService{keys: keys, instructors: instructors, ownerKey: ownerKey}
+}
+
+func (s *AuthService) Authenticate(key string) (models.APIKey, error) {
+	if key == "" {
+		return models.APIKey{}, ErrUnauthorized
+	}
+	if s.ownerKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(s.ownerKey)) == 1 {
+		return models.APIKey{Name: "ADMIN_API_KEY", Role: models.RoleOwner}, nil
+	}
+	k, err := s.keys.FindAPIKey(hashKey(key))
+	if errors.Is(err, repository.ErrNotFound) {
+		return k, ErrUnauthorized
+	}
+	return k, err
+}
+
+// IssueKey stores a new key and returns its plain value, which is not kept
+// anywhere and cannot be shown again.
+func (s *AuthService) IssueKey(k *models.APIKey) (string, error) {
+	switch k.Role {
+	case models.RoleOwner, models.RoleDispatcher:
+		k.InstructorID = ""
+	case models.RoleInstructor:
+		if k.InstructorID == "" {
+			return "", fmt.Errorf("%w: instructor keys need instructor_id", ErrInvalidRole)
+		}
+		if _, err := s.instructors.GetInstructor(k.InstructorID); err != nil {
+			return "", fmt.Errorf("instructor %s: %w", k.InstructorID, err)
+		}
+	default:
+		return "", fmt.Errorf("%w %q", ErrInvalidRole, k.Role)
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	key := "sk_" + hex.EncodeToString(b)
+	k.KeyHash = hashKey(key)
+	if err := s.keys.CreateAPIKey(k); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
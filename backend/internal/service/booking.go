@@ -0,0 +1,249 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+var (
+	ErrIdempotencyConflict   = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+// PriceMismatchError is returned when the client sent a price_total that
+// differs from the server quote.
+type PriceMismatchError struct {
+	Quote models.PriceBreakdown
+}
+
+func (e *PriceMismatchError) Error() string {
+	return fmt.Sprintf("price_total does not match server price %d", e.Quote.Total)
+}
+
+type BookingService struct {
+	bookings      repository.BookingStore
+	slots         repository.SlotStore
+	holds         repository.HoldStore
+	idempotency   repository.IdempotencyStore
+	pricing       *PricingService
+	promo         *PromoService
+	gifts         *GiftService
+	waitlist      *WaitlistService
+	weather       *WeatherService
+	holdTTL       time.Duration
+	idemRetention time.Duration
+}
+
+func NewBookingService(repo repository.Repository, pricing *PricingService, promo *PromoService, gifts *GiftService, waitlist *WaitlistService, weather *WeatherService, holdTTL, idemRetention time.Duration) *BookingService {
+	return &BookingService{bookings: repo, slots: repo, holds: repo, idempotency: repo, pricing: pricing, promo: promo, gifts: gifts, waitlist: waitlist, weather: weather, holdTTL: holdTTL, idemRetention: idemRetention}
+}
+
+// Create prices the booking on the server and stores it. A zero
+// price_total is filled in; any other value must match the quote. Add-ons
+// come from option_lines, or from the legacy options map when no lines are
+// sent. A promo code is checked and discounted before the comparison; a
+// gift certificate then pays GiftAmount of the total. A slot with an unsafe
+// forecast is refused with ErrUnsafeConditions. Fields the server owns
+// (status, gift amount, refund outcome, timestamps) are ignored.
+func (s *BookingService) Create(b *models.Booking) error {
+	b.ID, b.Status, b.Price = "", "", nil
+	b.GiftAmount, b.RefundAmount, b.RefundRule, b.GiftRefund = 0, 0, "", 0
+	b.CreatedAt, b.UpdatedAt = time.Time{}, time.Time{}
+	if len(b.OptionLines) == 0 {
+		lines, err := LegacyOptions(b.Options)
+		if err != nil {
+			return err
+		}
+		b.OptionLines = lines
+	}
+	quote, lines, err := s.pricing.Quote(b.SlotID, b.Participants, b.OptionLines)
+	if err != nil {
+		return err
+	}
+	if slot, err := s.slots.GetSlot(b.SlotID); err == nil {
+		if err := s.weather.Guard(slot); err != nil {
+			return err
+		}
+	}
+	if b.PromoCode != "" {
+		promo, err := s.promo.Apply(&quote, b.PromoCode, b.SlotID, time.Now())
+		if err != nil {
+			return err
+		}
+		b.PromoCode = promo.Code
+	}
+	if b.GiftCode != "" {
+		gift, amount, err := s.gifts.Cover(quote, b.GiftCode, b.SlotID, time.Now())
+		if err != nil {
+			return err
+		}
+		b.GiftCode, b.GiftAmount = gift.Code, amount
+	}
+	if b.PriceTotal != 0 && b.PriceTotal != quote.Total {
+		return &PriceMismatchError{Quote: quote}
+	}
+	b.OptionLines = lines
+	b.Options = legacyMap(lines)
+	b.PriceTotal = quote.Total
+	b.Price = &quote
+	return s.bookings.CreateBooking(b)
+}
+
+// CreateIdempotent is Create guarded by a client Idempotency-Key: a repeat
+// of the same request returns the booking made the first time
+// (replayed=true), a different request under the same key is rejected.
+func (s *BookingService) CreateIdempotent(key string, b *models.Booking) (replayed bool, err error) {
+	body, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	existing, reserved, err := s.idempotency.ReserveIdempotencyKey(key, hash, s.idemRetention)
+	if err != nil {
+		return false, err
+	}
+	if !reserved {
+		if existing.RequestHash != hash {
+			return false, ErrIdempotencyConflict
+		}
+		if existing.BookingID == "" {
+			return false, ErrIdempotencyInProgress
+		}
+		*b, err = s.bookings.GetBooking(existing.BookingID)
+		return err == nil, err
+	}
+	if err := s.Create(b); err != nil {
+		_ = s.idempotency.ReleaseIdempotencyKey(key)
+		return false, err
+	}
+	return false, s.idempotency.CompleteIdempotencyKey(key, b.ID)
+}
+
+// ChangeStatus applies a lifecycle transition; seats freed by a
+// cancellation are offered to the slot's waitlist.
+func (s *BookingService) ChangeStatus(id, status, reason string) (models.Booking, error) {
+	b, err := s.bookings.PatchBookingStatus(id, status, reason)
+	if err != nil {
+		return b, err
+	}
+	if b.Status == models.BookingCancelled {
+		s.waitlist.OfferFreedSeats(b.SlotID)
+	}
+	return b, nil
+}
+
+// Cancel cancels the booking with the refund the policy allows, offering
+// the freed seats to the waitlist. changed is false when the booking was
+// already cancelled.
+func (s *BookingService) Cancel(id, reason string, refund models.Refund) (models.Booking, bool, error) {
+	b, changed, err := s.bookings.CancelBooking(id, reason, refund)
+	if err != nil || !changed {
+		return b, false, err
+	}
+	s.waitlist.OfferFreedSeats(b.SlotID)
+	return b, true, nil
+}
+
+// Reschedule moves a booking to another slot, keeping its id. The booking is
+// re-priced only when the new slot has a different instructor or route; its
+// promo code is kept if the code allows the new slot, and its gift
+// certificate pays what it covers of the new price, at most what it paid
+// before (the rest goes back to the certificate). Moving into a slot with an
+// unsafe forecast is refused like a new booking.
+func (s *BookingService) Reschedule(id, slotID, reason string) (models.Booking, error) {
+	b, err := s.bookings.GetBooking(id)
+	if err != nil {
+		return b, err
+	}
+	slot, err := s.slots.GetSlot(slotID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return b, repository.ErrSlotUnavailable
+		}
+		return b, err
+	}
+	if slot.ID != b.SlotID {
+		if err := s.weather.Guard(slot); err != nil {
+			return b, err
+		}
+	}
+	var price *models.PriceBreakdown
+	giftAmount := b.GiftAmount
+	if slot.InstructorID != b.InstructorID || slot.RouteID != b.RouteID {
+		quote, _, err := s.pricing.Quote(slot.ID, b.Participants, b.OptionLines)
+		if err != nil {
+			return b, err
+		}
+		if b.PromoCode != "" {
+			if err := s.promo.Reprice(&quote, b.PromoCode, slot); err != nil {
+				return b, err
+			}
+		}
+		if b.GiftCode != "" {
+			if giftAmount, err = s.gifts.Recover(quote, b, slot); err != nil {
+				return b, err
+			}
+		}
+		price = &quote
+	}
+	moved, err := s.bookings.RescheduleBooking(id, slot.ID, price, giftAmount, reason)
+	if err != nil {
+		return moved, err
+	}
+	if moved.SlotID != b.SlotID {
+		s.waitlist.OfferFreedSeats(b.SlotID)
+	}
+	return moved, nil
+}
+
+// HoldSeats reserves seats on a slot for the configured TTL while the
+// customer fills in the booking form.
+func (s *BookingService) HoldSeats(slotID string, seats int) (models.SeatHold, error) {
+	return s.holds.CreateHold(slotID, seats, s.holdTTL)
+}
+
+// ReleaseExpiredHolds is the reaper job: it returns seats of holds that were
+// never turned into bookings.
+func (s *BookingService) ReleaseExpiredHolds(now time.Time) error {
+	released, err := s.holds.ReleaseExpiredHolds(now)
+	if err != nil {
+		return err
+	}
+	if len(released) > 0 {
+		log.Printf("released %d expired seat holds", len(released))
+	}
+	seen := map[string]bool{}
+	for _, h := range released {
+		if !seen[h.SlotID] {
+			seen[h.SlotID] = true
+			s.waitlist.OfferFreedSeats(h.SlotID)
+		}
+	}
+	return nil
+}
+
+func (s *BookingService) ReleaseHold(token string) error {
+	h, err := s.holds.GetHold(token)
+	if err != nil {
+		return err
+	}
+	if err := s.holds.ReleaseHold(token); err != nil {
+		return err
+	}
+	s.waitlist.OfferFreedSeats(h.SlotID)
+	return nil
+}
+
+func (s *BookingService) PurgeIdempotencyKeys(now time.Time) error {
+	_, err := s.idempotency.PurgeIdempotencyKeys(now)
+	return err
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+func TestRescheduleRecoversGiftAgainstNewQuote(t *testing.T) {
+	sv := newServices()
+	d := day(10)
+	from := addSlot(t, sv.repo, d.Add(9*time.Hour), 4)
+	cheap := addRoute(t, sv.repo, 500)
+	to := addRouteSlot(t, sv.repo, cheap.ID, d.Add(13*time.Hour), 4)
+
+	g := models.GiftCertificate{Kind: models.GiftValue, Amount: 10000}
+	if err := sv.gifts.Issue(&g); err != nil {
+		t.Fatal(err)
+	}
+	b := models.Booking{SlotID: from.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 1, GiftCode: g.Code}
+	if err := sv.booking.Create(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.GiftAmount != b.PriceTotal {
+		t.Fatalf("gift amount %d, want the whole total %d", b.GiftAmount, b.PriceTotal)
+	}
+
+	moved, err := sv.booking.Reschedule(b.ID, to.ID, "cheaper route")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.PriceTotal >= b.PriceTotal {
+		t.Fatalf("new total %d, want it below %d", moved.PriceTotal, b.PriceTotal)
+	}
+	if moved.GiftAmount != moved.PriceTotal {
+		t.Errorf("gift amount after move = %d, want the new total %d (nothing due, nothing negative)", moved.GiftAmount, moved.PriceTotal)
+	}
+	got, _ := sv.repo.GetGift(g.Code)
+	if want := 10000 - moved.GiftAmount; got.Balance != want {
+		t.Errorf("balance = %d, want %d: the unneeded part goes back", got.Balance, want)
+	}
+}
+
+func TestRescheduleRejectsRouteGiftOnAnotherRoute(t *testing.T) {
+	sv := newServices()
+	d := day(10)
+	from := addSlot(t, sv.repo, d.Add(9*time.Hour), 4)
+	other := addRoute(t, sv.repo, 2500)
+	to := addRouteSlot(t, sv.repo, other.ID, d.Add(13*time.Hour), 4)
+
+	g := models.GiftCertificate{Kind: models.GiftRoute, RouteID: seedRoute}
+	if err := sv.gifts.Issue(&g); err != nil {
+		t.Fatal(err)
+	}
+	b := models.Booking{SlotID: from.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 1, GiftCode: g.Code}
+	if err := sv.booking.Create(&b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sv.booking.Reschedule(b.ID, to.ID, ""); !errors.Is(err, ErrGiftRejected) {
+		t.Fatalf("reschedule = %v, want ErrGiftRejected", err)
+	}
+	if got, _ := sv.repo.GetBooking(b.ID); got.SlotID != from.ID || got.GiftAmount != b.GiftAmount {
+		t.Errorf("booking changed by a rejected move: %+v", got)
+	}
+}
+
+func TestCreatePricesOnServer(t *testing.T) {
+	sv := newServices()
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 4)
+	book := func(total int) (models.Booking, error) {
+		b := models.Booking{SlotID: slot.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 2, PriceTotal: total, OptionLines: []models.OptionLine{{Code: "photo"}}}
+		return b, sv.booking.Create(&b)
+	}
+
+	b, err := book(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (3000 + 2500 + 700) × 2
+	if b.PriceTotal != 12400 || b.Price == nil || b.Price.Total != 12400 {
+		t.Fatalf("zero price_total filled as %d (%+v), want 12400", b.PriceTotal, b.Price)
+	}
+	if len(b.OptionLines) != 1 || b.OptionLines[0].Amount != 1400 || b.Options["photo"] != true {
+		t.Errorf("option lines %+v, options %v; want photo priced 1400 and mirrored in options", b.OptionLines, b.Options)
+	}
+	if _, err := book(12400); err != nil {
+		t.Errorf("matching price_total: %v", err)
+	}
+	var mismatch *PriceMismatchError
+	if _, err := book(100); !errors.As(err, &mismatch) || mismatch.Quote.Total != 12400 {
+		t.Errorf("price_total 100: err = %v, want PriceMismatchError quoting 12400", err)
+	}
+	if got, _ := sv.repo.GetSlot(slot.ID); got.Remaining != 0 {
+		t.Errorf("remaining = %d, want 0: the rejected booking must not take seats", got.Remaining)
+	}
+}
+
+func TestCreateLegacyOptions(t *testing.T) {
+	sv := newServices()
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 4)
+	b := models.Booking{SlotID: slot.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 1, Options: map[string]any{"photo": true, "drybag": false}}
+	if err := sv.booking.Create(&b); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.OptionLines) != 1 || b.OptionLines[0].Code != "photo" || b.PriceTotal != 6200 {
+		t.Errorf("lines %+v, total %d; want photo only, 6200", b.OptionLines, b.PriceTotal)
+	}
+	bad := models.Booking{SlotID: slot.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 1, Options: map[string]any{"photo": "yes"}}
+	if err := sv.booking.Create(&bad); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("options {photo: \"yes\"}: err = %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestCreateTakesInstructorAndRouteFromSlot(t *testing.T) {
+	sv := newServices()
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 4)
+
+	b := models.Booking{SlotID: slot.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 1}
+	if err := sv.booking.Create(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.InstructorID != seedInstructor || b.RouteID != seedRoute {
+		t.Errorf("booking instructor/route = %s/%s, want the slot's %s/%s", b.InstructorID, b.RouteID, seedInstructor, seedRoute)
+	}
+
+	other := addRoute(t, sv.repo, 1000)
+	for _, bad := range []models.Booking{
+		{SlotID: slot.ID, InstructorID: "22222222222222222222222222222222", CustomerName: "Анна", Phone: "+79990000000", Participants: 1},
+		{SlotID: slot.ID, RouteID: other.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 1},
+	} {
+		if err := sv.booking.Create(&bad); !errors.Is(err, repository.ErrSlotMismatch) {
+			t.Errorf("instructor %q route %q: err = %v, want ErrSlotMismatch", bad.InstructorID, bad.RouteID, err)
+		}
+	}
+}
+
+func TestHoldSeats(t *testing.T) {
+	sv := newServices()
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 4)
+	remaining := func() int {
+		t.Helper()
+		s, err := sv.repo.GetSlot(slot.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Remaining
+	}
+
+	kept, err := sv.booking.HoldSeats(slot.ID, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := remaining(); got != 1 {
+		t.Fatalf("remaining after holding 3 = %d, want 1", got)
+	}
+	if _, err := sv.booking.HoldSeats(slot.ID, 2); !errors.Is(err, repository.ErrSlotUnavailable) {
+		t.Errorf("holding 2 of 1 free seat: err = %v, want ErrSlotUnavailable", err)
+	}
+
+	big := models.Booking{SlotID: slot.ID, HoldToken: kept.Token, CustomerName: "Анна", Phone: "+79990000000", Participants: 4}
+	if err := sv.booking.Create(&big); !errors.Is(err, repository.ErrHoldMismatch) {
+		t.Errorf("4 participants on a 3-seat hold: err = %v, want ErrHoldMismatch", err)
+	}
+	b := models.Booking{SlotID: slot.ID, HoldToken: kept.Token, CustomerName: "Анна", Phone: "+79990000000", Participants: 2}
+	if err := sv.booking.Create(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got := remaining(); got != 2 {
+		t.Errorf("remaining after booking 2 on a 3-seat hold = %d, want 2: the unused seat goes back", got)
+	}
+	again := models.Booking{SlotID: slot.ID, HoldToken: kept.Token, CustomerName: "Анна", Phone: "+79990000000", Participants: 1}
+	if err := sv.booking.Create(&again); !errors.Is(err, repository.ErrHoldNotFound) {
+		t.Errorf("reusing a consumed hold: err = %v, want ErrHoldNotFound", err)
+	}
+
+	if _, err := sv.booking.HoldSeats(slot.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := sv.booking.ReleaseExpiredHolds(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := remaining(); got != 0 {
+		t.Errorf("remaining = %d, want 0: a live hold must survive the reaper", got)
+	}
+	if err := sv.booking.ReleaseExpiredHolds(time.Now().Add(11 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got := remaining(); got != 2 {
+		t.Errorf("remaining after the hold expired = %d, want 2", got)
+	}
+}
+
+func TestCreateIdempotent(t *testing.T) {
+	sv := newServices()
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 4)
+	req := func(n int) *models.Booking {
+		return &models.Booking{SlotID: slot.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: n}
+	}
+
+	first := req(2)
+	if replayed, err := sv.booking.CreateIdempotent("k1", first); err != nil || replayed {
+		t.Fatalf("first request: replayed %v, err %v", replayed, err)
+	}
+	again := req(2)
+	if replayed, err := sv.booking.CreateIdempotent("k1", again); err != nil || !replayed || again.ID != first.ID {
+		t.Fatalf("retry: replayed %v, id %s, err %v; want a replay of %s", replayed, again.ID, err, first.ID)
+	}
+	if _, err := sv.booking.CreateIdempotent("k1", req(3)); !errors.Is(err, ErrIdempotencyConflict) {
+		t.Errorf("same key, other body: err = %v, want ErrIdempotencyConflict", err)
+	}
+	if s, _ := sv.repo.GetSlot(slot.ID); s.Remaining != 2 {
+		t.Errorf("remaining = %d, want 2: the retry must not book twice", s.Remaining)
+	}
+
+	// A failed booking releases its key: the retry is booked again rather than
+	// reported as in progress.
+	tooMany := req(5)
+	if _, err := sv.booking.CreateIdempotent("k2", tooMany); !errors.Is(err, repository.ErrSlotUnavailable) {
+		t.Fatalf("5 of 2 seats: err = %v, want ErrSlotUnavailable", err)
+	}
+	if _, err := sv.booking.CreateIdempotent("k2", req(5)); !errors.Is(err, repository.ErrSlotUnavailable) {
+		t.Errorf("retry after a failure: err = %v, want the booking error again, not an idempotency error", err)
+	}
+}
+
+func TestRescheduleMovesSeatsAndReprices(t *testing.T) {
+	d := day(10)
+	// stormy from 17:00
+	sv := newServicesWith(&stubWeather{hour: func(h time.Time) ForecastHour {
+		if h.Before(d.Add(17 * time.Hour)) {
+			return calm
+		}
+		return storm
+	}})
+	from := addSlot(t, sv.repo, d.Add(9*time.Hour), 4)
+	same := addSlot(t, sv.repo, d.Add(11*time.Hour), 4)
+	full := addSlot(t, sv.repo, d.Add(13*time.Hour), 1)
+	stormy := addSlot(t, sv.repo, d.Add(17*time.Hour), 4)
+	cheap := addRouteSlot(t, sv.repo, addRoute(t, sv.repo, 500).ID, d.Add(15*time.Hour), 4)
+	remaining := func(id string) int {
+		s, _ := sv.repo.GetSlot(id)
+		return s.Remaining
+	}
+
+	b := models.Booking{SlotID: from.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 2}
+	if err := sv.booking.Create(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		slot string
+		want error
+	}{
+		{full.ID, repository.ErrSlotUnavailable},
+		{stormy.ID, ErrUnsafeConditions},
+		{newID(), repository.ErrSlotUnavailable},
+	} {
+		if _, err := sv.booking.Reschedule(b.ID, tt.slot, ""); !errors.Is(err, tt.want) {
+			t.Errorf("reschedule to %s: err = %v, want %v", tt.slot, err, tt.want)
+		}
+	}
+
+	moved, err := sv.booking.Reschedule(b.ID, same.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.PriceTotal != 11000 || remaining(from.ID) != 4 || remaining(same.ID) != 2 {
+		t.Errorf("same route: price %d, seats %d/%d; want 11000 kept and 2 seats moved", moved.PriceTotal, remaining(from.ID), remaining(same.ID))
+	}
+	moved, err = sv.booking.Reschedule(b.ID, cheap.ID, "клиент попросил")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (3000 + 500) × 2
+	if moved.PriceTotal != 7000 || moved.RouteID != cheap.RouteID {
+		t.Errorf("another route: price %d, route %s; want 7000 on %s", moved.PriceTotal, moved.RouteID, cheap.RouteID)
+	}
+
+	if _, err := sv.booking.ChangeStatus(b.ID, models.BookingCancelled, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sv.booking.Reschedule(b.ID, same.ID, ""); !errors.Is(err, repository.ErrInvalidTransition) {
+		t.Errorf("reschedule a cancelled booking: err = %v, want ErrInvalidTransition", err)
+	}
+}
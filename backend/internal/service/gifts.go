@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+var (
+	ErrInvalidGift  = errors.New("invalid gift certificate")
+	ErrGiftRejected = errors.New("gift certificate cannot be used for this booking")
+)
+
+// giftValidity is the default lifetime of a certificate issued without
+// expires_at.
+const giftValidity = 365 * 24 * time.Hour
+
+type GiftService struct {
+	gifts  repository.GiftStore
+	routes repository.RouteStore
+	slots  repository.SlotStore
+}
+
+func NewGiftService(repo repository.Repository) *GiftService {
+	return &GiftService{gifts: repo, routes: repo, slots: repo}
+}
+
+// Issue creates a certificate: a value one needs an amount, a route one a
+// route and the number of people it covers (1 by default).
+func (s *GiftService) Issue(g *models.GiftCertificate) error {
+	switch g.Kind {
+	case models.GiftValue:
+		if g.Amount < 1 {
+			return fmt.Errorf("%w: amount must be positive", ErrInvalidGift)
+		}
+		g.Balance, g.RouteID, g.Participants = g.Amount, "", 0
+	case models.GiftRoute:
+		if g.RouteID == "" {
+			return fmt.Errorf("%w: route_id is required", ErrInvalidGift)
+		}
+		route, err := s.routes.GetRoute(g.RouteID)
+		if err != nil {
+			return fmt.Errorf("route %s: %w", g.RouteID, err)
+		}
+		if g.Participants == 0 {
+			g.Participants = 1
+		}
+		if g.Participants < 1 {
+			return fmt.Errorf("%w: participants must be positive", ErrInvalidGift)
+		}
+		g.RouteID, g.Amount, g.Balance = route.ID, 0, 0
+	default:
+		return fmt.Errorf("%w: kind must be value or route", ErrInvalidGift)
+	}
+	now := time.Now()
+	if g.ExpiresAt.IsZero() {
+		g.ExpiresAt = now.Add(giftValidity)
+	}
+	if !g.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: expires_at must be in the future", ErrInvalidGift)
+	}
+	return s.gifts.CreateGift(g)
+}
+
+func (s *GiftService) Get(code string) (models.GiftCertificate, error) {
+	return s.gifts.GetGift(NormalizeCode(code))
+}
+
+func (s *GiftService) Void(code string) (models.GiftCertificate, error) {
+	return s.gifts.VoidGift(NormalizeCode(code))
+}
+
+// Cover works out how much of the quoted booking the certificate pays: as
+// much of the total as the balance allows, or for a route certificate the
+// instructor and route price of the people it covers.
+func (s *GiftService) Cover(quote models.PriceBreakdown, code, slotID string, now time.Time) (models.GiftCertificate, int, error) {
+	g, err := s.gifts.GetGift(NormalizeCode(code))
+	if errors.Is(err, repository.ErrNotFound) {
+		return g, 0, fmt.Errorf("%w: certificate not found", ErrGiftRejected)
+	}
+	if err != nil {
+		return g, 0, err
+	}
+	if g.Status != models.GiftActive {
+		return g, 0, fmt.Errorf("%w: certificate is %s", repository.ErrGiftClosed, g.Status)
+	}
+	if !now.Before(g.ExpiresAt) {
+		return g, 0, fmt.Errorf("%w: certificate expired at %s", repository.ErrGiftClosed, g.ExpiresAt.Format(time.RFC3339))
+	}
+	routeID := ""
+	if g.Kind == models.GiftRoute {
+		slot, err := s.slots.GetSlot(slotID)
+		if err != nil {
+			return g, 0, err
+		}
+		routeID = slot.RouteID
+	}
+	amount, err := coverAmount(g, quote, routeID)
+	if err != nil {
+		return g, 0, err
+	}
+	if amount == 0 {
+		return g, 0, fmt.Errorf("%w: nothing to pay", ErrGiftRejected)
+	}
+	return g, amount, nil
+}
+
+// Recover works out what the certificate of booking b still pays once the
+// booking is re-quoted for slot: what Cover gives for the new quote, but
+// never more than the booking already took from the certificate. A route
+// certificate cannot follow the booking to another route.
+func (s *GiftService) Recover(quote models.PriceBreakdown, b models.Booking, slot models.TimeSlot) (int, error) {
+	g, err := s.gifts.GetGift(b.GiftCode)
+	if err != nil {
+		return 0, err
+	}
+	g.Balance = b.GiftAmount
+	amount, err := coverAmount(g, quote, slot.RouteID)
+	return min(amount, b.GiftAmount), err
+}
+
+// coverAmount is the part of quote the certificate pays on routeID: its
+// balance, or for a route certificate the instructor and route price of the
+// people it covers; never more than the total.
+func coverAmount(g models.GiftCertificate, quote models.PriceBreakdown, routeID string) (int, error) {
+	amount := g.Balance
+	if g.Kind == models.GiftRoute {
+		if routeID != g.RouteID {
+			return 0, fmt.Errorf("%w: certificate is for another route", ErrGiftRejected)
+		}
+		base := 0
+		for _, l := range quote.Lines {
+			if l.Code == "instructor" || l.Code == "route" {
+				base += l.Amount
+			}
+		}
+		amount = base / quote.Participants * min(quote.Participants, g.Participants)
+	}
+	return min(amount, quote.Total), nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+func TestIssueGiftValidates(t *testing.T) {
+	gifts := NewGiftService(repository.New())
+	for _, g := range []models.GiftCertificate{
+		{Kind: "voucher", Amount: 1000},
+		{Kind: models.GiftValue},
+		{Kind: models.GiftRoute},
+		{Kind: models.GiftRoute, RouteID: seedRoute, Participants: -1},
+		{Kind: models.GiftValue, Amount: 1000, ExpiresAt: time.Now().Add(-time.Hour)},
+	} {
+		if err := gifts.Issue(&g); !errors.Is(err, ErrInvalidGift) {
+			t.Errorf("issue %+v: err = %v, want ErrInvalidGift", g, err)
+		}
+	}
+	g := models.GiftCertificate{Kind: models.GiftValue, Amount: 3000}
+	if err := gifts.Issue(&g); err != nil {
+		t.Fatal(err)
+	}
+	if g.Code == "" || g.Balance != 3000 || g.Status != models.GiftActive || time.Until(g.ExpiresAt) < 364*24*time.Hour {
+		t.Errorf("issued %+v, want an active code with balance 3000 valid for a year", g)
+	}
+}
+
+func TestGiftPaysForBookings(t *testing.T) {
+	sv := newServices()
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 6)
+	issue := func(g models.GiftCertificate) string {
+		t.Helper()
+		if err := sv.gifts.Issue(&g); err != nil {
+			t.Fatal(err)
+		}
+		return g.Code
+	}
+	book := func(code string) (models.Booking, error) {
+		b := models.Booking{SlotID: slot.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 2, GiftCode: code}
+		return b, sv.booking.Create(&b)
+	}
+	balance := func(code string) models.GiftCertificate {
+		g, _ := sv.gifts.Get(code)
+		return g
+	}
+
+	// 2 × (3000 + 2500) = 11000
+	value := issue(models.GiftCertificate{Kind: models.GiftValue, Amount: 8000})
+	b, err := book(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := balance(value); b.GiftAmount != 8000 || g.Balance != 0 || g.Status != models.GiftRedeemed {
+		t.Errorf("value gift paid %d, left %d (%s); want 8000, 0, redeemed", b.GiftAmount, g.Balance, g.Status)
+	}
+	if _, err := book(value); !errors.Is(err, repository.ErrGiftClosed) {
+		t.Errorf("spent certificate: err = %v, want ErrGiftClosed", err)
+	}
+
+	large := issue(models.GiftCertificate{Kind: models.GiftValue, Amount: 20000})
+	if b, err := book(large); err != nil || b.GiftAmount != 11000 || balance(large).Balance != 9000 {
+		t.Errorf("large gift paid %d, left %d (err %v); want 11000, 9000", b.GiftAmount, balance(large).Balance, err)
+	}
+
+	single := issue(models.GiftCertificate{Kind: models.GiftRoute, RouteID: seedRoute})
+	if b, err := book(single); err != nil || b.GiftAmount != 5500 {
+		t.Errorf("one-person route gift paid %d (err %v), want 5500", b.GiftAmount, err)
+	}
+
+	voided := issue(models.GiftCertificate{Kind: models.GiftValue, Amount: 1000})
+	if _, err := sv.gifts.Void(voided); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := book(voided); !errors.Is(err, repository.ErrGiftClosed) {
+		t.Errorf("voided certificate: err = %v, want ErrGiftClosed", err)
+	}
+	if _, err := book("NO-SUCH-GIFT"); !errors.Is(err, ErrGiftRejected) {
+		t.Errorf("unknown code: err = %v, want ErrGiftRejected", err)
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+// Seeded by repository.New.
+const (
+	seedInstructor = "11111111111111111111111111111111"
+	seedRoute      = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+)
+
+var (
+	calm  = ForecastHour{Temperature: 24, WindSpeed: 2, WindGusts: 4, CloudCover: 10}
+	storm = ForecastHour{Temperature: 24, WindSpeed: 12, WindGusts: 18, Precipitation: 3, CloudCover: 100}
+)
+
+// stubWeather is a WeatherProvider that answers with hour(t) for every hour
+// of the requested days, or with err. A delay longer than the caller's
+// timeout makes it time out.
+type stubWeather struct {
+	hour  func(t time.Time) ForecastHour
+	err   error
+	delay time.Duration
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *stubWeather) Name() string { return "stub" }
+
+func (p *stubWeather) Forecast(ctx context.Context, _, _ float64, from, to time.Time) (Forecast, error) {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+	if p.delay > 0 {
+		select {
+		case <-time.After(p.delay):
+		case <-ctx.Done():
+			return Forecast{}, ctx.Err()
+		}
+	}
+	if p.err != nil {
+		return Forecast{}, p.err
+	}
+	f := Forecast{}
+	last := to.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	for t := from.UTC().Truncate(24 * time.Hour); t.Before(last); t = t.Add(time.Hour) {
+		f.Times = append(f.Times, t)
+		f.Points = append(f.Points, p.hour(t))
+	}
+	return f, nil
+}
+
+func (p *stubWeather) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func newTestWeather(repo repository.Repository, p WeatherProvider) *WeatherService {
+	return NewWeatherService(repo, repo, repo, []WeatherProvider{p}, "", time.Second, time.Second, 20*time.Minute, true, WeatherRules{DefaultDifficulty: DefaultScoringRules})
+}
+
+// services is the service stack of cmd/server over a memory store with a
+// calm forecast.
+type services struct {
+	repo     *repository.Memory
+	weather  *WeatherService
+	pricing  *PricingService
+	promo    *PromoService
+	gifts    *GiftService
+	waitlist *WaitlistService
+	booking  *BookingService
+	notifier *recordingNotifier
+}
+
+func newServices() services {
+	return newServicesWith(&stubWeather{hour: func(time.Time) ForecastHour { return calm }})
+}
+
+// newServicesWith is newServices with p as the forecast.
+func newServicesWith(p WeatherProvider) services {
+	repo := repository.New()
+	sv := services{repo: repo, weather: newTestWeather(repo, p), notifier: &recordingNotifier{}}
+	sv.pricing = NewPricingService(repo, repo, repo, repo)
+	sv.promo = NewPromoService(repo, sv.pricing)
+	sv.gifts = NewGiftService(repo)
+	sv.waitlist = NewWaitlistService(repo, repo, sv.notifier, 30*time.Minute)
+	sv.booking = NewBookingService(repo, sv.pricing, sv.promo, sv.gifts, sv.waitlist, sv.weather, 10*time.Minute, 24*time.Hour)
+	return sv
+}
+
+// addRoute stores a route with the given base price.
+func addRoute(t *testing.T, repo repository.Repository, basePrice int) models.Route {
+	t.Helper()
+	r := models.Route{Title: "Маршрут " + newID(), DurationMinutes: 90, Difficulty: "easy", BasePrice: basePrice, LocationLat: 45.092, LocationLng: 37.268}
+	if err := repo.UpsertRoute(&r); err != nil {
+		t.Fatalf("add route: %v", err)
+	}
+	return r
+}
+
+// day returns midnight UTC days ahead of today, clear of the seeded slots.
+func day(days int) time.Time {
+	return time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, days)
+}
+
+// addSlot stores a 90-minute slot of the seeded instructor and route.
+func addSlot(t *testing.T, repo repository.Repository, start time.Time, capacity int) models.TimeSlot {
+	t.Helper()
+	return addRouteSlot(t, repo, seedRoute, start, capacity)
+}
+
+// addRouteSlot stores a 90-minute slot of the seeded instructor on routeID.
+func addRouteSlot(t *testing.T, repo repository.Repository, routeID string, start time.Time, capacity int) models.TimeSlot {
+	t.Helper()
+	s := models.TimeSlot{ID: newID(), InstructorID: seedInstructor, RouteID: routeID, StartAt: start, EndAt: start.Add(90 * time.Minute), Capacity: capacity, Remaining: capacity, Status: "open"}
+	if err := repo.BulkCreateSlots([]models.TimeSlot{s}); err != nil {
+		t.Fatalf("add slot: %v", err)
+	}
+	s, err := repo.GetSlot(s.ID)
+	if err != nil {
+		t.Fatalf("get slot: %v", err)
+	}
+	return s
+}
+
+var idSeq atomic.Int64
+
+func newID() string {
+	return fmt.Sprintf("%032x", idSeq.Add(1))
+}
+
+// recordingNotifier keeps every notification it is given.
+type recordingNotifier struct {
+	mu   sync.Mutex
+	sent []Notification
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, msg Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, msg)
+	return nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+var (
+	ErrForeignResource   = errors.New("belongs to another instructor")
+	ErrInvalidAttendance = errors.New("attendance must be completed or no_show")
+	ErrSlotNotStarted    = errors.New("slot has not started yet")
+)
+
+// ScheduleSlot is a slot of the instructor's schedule with the bookings made
+// for it (cancelled ones left out).
+type ScheduleSlot struct {
+	models.TimeSlot
+	Booked   int              `json:"booked"`
+	Bookings []models.Booking `json:"bookings"`
+}
+
+// InstructorService backs the instructor portal; every call is scoped to the
+// instructor the API key belongs to.
+type InstructorService struct {
+	slots    repository.SlotStore
+	bookings repository.BookingStore
+	booking  *BookingService
+}
+
+func NewInstructorService(repo repository.Repository, booking *BookingService) *InstructorService {
+	return &InstructorService{slots: repo, bookings: repo, booking: booking}
+}
+
+func (s *InstructorService) Schedule(instructorID string, from, to time.Time) ([]ScheduleSlot, error) {
+	slots, err := s.slots.ListInstructorSlots(instructorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(slots))
+	index := map[string]int{}
+	out := make([]ScheduleSlot, len(slots))
+	for i, slot := range slots {
+		ids[i] = slot.ID
+		index[slot.ID] = i
+		out[i] = ScheduleSlot{TimeSlot: slot, Bookings: []models.Booking{}}
+	}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	bookings, err := s.bookings.ListSlotBookings(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bookings {
+		if b.Status == models.BookingCancelled {
+			continue
+		}
+		item := &out[index[b.SlotID]]
+		item.Booked += b.Participants
+		item.Bookings = append(item.Bookings, b)
+	}
+	return out, nil
+}
+
+func (s *InstructorService) SetSlotBlocked(instructorID, slotID string, blocked bool) (models.TimeSlot, error) {
+	slot, err := s.slots.GetSlot(slotID)
+	if err != nil {
+		return slot, err
+	}
+	if slot.InstructorID != instructorID {
+		return models.TimeSlot{}, fmt.Errorf("slot %w", ErrForeignResource)
+	}
+	return s.slots.SetSlotBlocked(slotID, blocked)
+}
+
+// MarkAttendance closes a booking as completed or no_show once its slot has
+// started.
+func (s *InstructorService) MarkAttendance(instructorID, bookingID, status string) (models.Booking, error) {
+	if status != models.BookingCompleted && status != models.BookingNoShow {
+		return models.Booking{}, ErrInvalidAttendance
+	}
+	b, err := s.bookings.GetBooking(bookingID)
+	if err != nil {
+		return b, err
+	}
+	if b.InstructorID != instructorID {
+		return models.Booking{}, fmt.Errorf("booking %w", ErrForeignResource)
+	}
+	slot, err := s.slots.GetSlot(b.SlotID)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	if time.Now().Before(slot.StartAt) {
+		return models.Booking{}, ErrSlotNotStarted
+	}
+	return s.booking.ChangeStatus(bookingID, status, "marked by instructor")
+}
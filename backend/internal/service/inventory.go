@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+var ErrInvalidStation = errors.New("invalid station")
+
+var equipmentKinds = map[string]bool{
+	models.EquipmentBoard:  true,
+	models.EquipmentPaddle: true,
+	models.EquipmentVest:   true,
+	models.EquipmentDrybag: true,
+}
+
+type InventoryService struct {
+	stations repository.InventoryStore
+}
+
+func NewInventoryService(stations repository.InventoryStore) *InventoryService {
+	return &InventoryService{stations: stations}
+}
+
+func (s *InventoryService) Save(st *models.Station) error {
+	if st.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidStation)
+	}
+	for kind, n := range st.Stock {
+		if !equipmentKinds[kind] {
+			return fmt.Errorf("%w: unknown equipment %q, expected board, paddle, vest or drybag", ErrInvalidStation, kind)
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: stock of %s is negative", ErrInvalidStation, kind)
+		}
+	}
+	return s.stations.UpsertStation(st)
+}
+
+// Free reports the station and the equipment left for [from, to).
+func (s *InventoryService) Free(stationID string, from, to time.Time) (models.Station, map[string]int, error) {
+	if !to.After(from) {
+		return models.Station{}, nil, fmt.Errorf("%w: to must be after from", ErrInvalidStation)
+	}
+	st, err := s.stations.GetStation(stationID)
+	if err != nil {
+		return models.Station{}, nil, err
+	}
+	free, err := s.stations.FreeEquipment(st.ID, from, to)
+	return st, free, err
+}
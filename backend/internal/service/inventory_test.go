@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+const seedStation = "cccccccccccccccccccccccccccccccc" // 10 boards, 6 dry bags
+
+func TestBookingsShareStationEquipment(t *testing.T) {
+	sv := newServices()
+	inventory := NewInventoryService(sv.repo)
+	start := day(10).Add(9 * time.Hour)
+	first := addSlot(t, sv.repo, start, 6)
+	// Another instructor starting half an hour later at the same station.
+	second := models.TimeSlot{ID: newID(), InstructorID: "22222222222222222222222222222222", RouteID: seedRoute, StartAt: start.Add(30 * time.Minute), EndAt: start.Add(2 * time.Hour), Capacity: 6, Remaining: 6, Status: "open"}
+	if err := sv.repo.BulkCreateSlots([]models.TimeSlot{second}); err != nil {
+		t.Fatal(err)
+	}
+	book := func(slotID string, n int) (models.Booking, error) {
+		b := models.Booking{SlotID: slotID, CustomerName: "Анна", Phone: "+79990000000", Participants: n, OptionLines: []models.OptionLine{{Code: "drybag"}}}
+		return b, sv.booking.Create(&b)
+	}
+
+	a, err := book(first.ID, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := book(second.ID, 3); !errors.Is(err, repository.ErrEquipmentUnavailable) {
+		t.Fatalf("3 dry bags with 2 left: err = %v, want ErrEquipmentUnavailable", err)
+	}
+	_, free, err := inventory.Free(seedStation, second.StartAt, second.EndAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if free[models.EquipmentDrybag] != 2 || free[models.EquipmentBoard] != 6 {
+		t.Errorf("free = %v, want 2 dry bags and 6 boards", free)
+	}
+	if _, err := sv.booking.ChangeStatus(a.ID, models.BookingCancelled, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := book(second.ID, 3); err != nil {
+		t.Errorf("after the first booking was cancelled: %v", err)
+	}
+}
+
+func TestSaveStationValidates(t *testing.T) {
+	inventory := NewInventoryService(repository.New())
+	for _, st := range []models.Station{
+		{Stock: map[string]int{models.EquipmentBoard: 1}},
+		{Title: "Пляж", Stock: map[string]int{"kayak": 1}},
+		{Title: "Пляж", Stock: map[string]int{models.EquipmentBoard: -1}},
+	} {
+		if err := inventory.Save(&st); !errors.Is(err, ErrInvalidStation) {
+			t.Errorf("save %+v: err = %v, want ErrInvalidStation", st, err)
+		}
+	}
+	if _, _, err := inventory.Free(seedStation, day(10), day(10)); !errors.Is(err, ErrInvalidStation) {
+		t.Errorf("empty window: err = %v, want ErrInvalidStation", err)
+	}
+}
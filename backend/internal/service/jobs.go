@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunPeriodic runs job once right away and then every interval until ctx is
+// cancelled. Errors are logged and do not stop the loop.
+func RunPeriodic(ctx context.Context, name string, every time.Duration, job func(now time.Time) error) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		if err := job(time.Now().UTC()); err != nil {
+			log.Printf("%s: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Notification is a message for the customer of a booking or of a
+// waitlist entry. Token is a bearer secret (the reschedule offer link or
+// the waitlist hold) and must never be logged.
+type Notification struct {
+	Kind       string `json:"kind"`
+	BookingID  string `json:"booking_id,omitempty"`
+	WaitlistID string `json:"waitlist_id,omitempty"`
+	Phone      string `json:"phone"`
+	Messenger  string `json:"messenger,omitempty"`
+	Text       string `json:"text"`
+	Token      string `json:"token,omitempty"`
+}
+
+// Notifier delivers notifications to customers.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NewNotifier posts notifications to webhookURL; an empty URL gives a
+// notifier that only logs that nothing was delivered.
+func NewNotifier(webhookURL, secret string) (Notifier, error) {
+	if webhookURL == "" {
+		return logNotifier{}, nil
+	}
+	if secret == "" {
+		return nil, errors.New("notification webhook secret is empty")
+	}
+	return &WebhookNotifier{url: webhookURL, secret: secret, http: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// WebhookNotifier posts each notification as JSON signed with HMAC-SHA256
+// in X-Signature; the receiver sends it to the customer's phone or
+// messenger.
+type WebhookNotifier struct {
+	url    string
+	secret string
+	http   *http.Client
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, []byte(w.secret))
+	mac.Write(body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notification webhook answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type logNotifier struct{}
+
+func (logNotifier) Notify(_ context.Context, n Notification) error {
+	if n.WaitlistID != "" {
+		log.Printf("%s for waitlist entry %s not delivered: NOTIFY_WEBHOOK_URL is empty", n.Kind, n.WaitlistID)
+		return nil
+	}
+	log.Printf("%s for booking %s not delivered: NOTIFY_WEBHOOK_URL is empty", n.Kind, n.BookingID)
+	return nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+var optionCode = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+type OptionService struct {
+	options repository.OptionStore
+	routes  repository.RouteStore
+}
+
+func NewOptionService(repo repository.Repository) *OptionService {
+	return &OptionService{options: repo, routes: repo}
+}
+
+func (s *OptionService) Save(o *models.Option) error {
+	if o.PriceType == "" {
+		o.PriceType = models.OptionPerPerson
+	}
+	if !optionCode.MatchString(o.Code) {
+		return fmt.Errorf("%w: code must be 1-32 lowercase letters, digits or _", ErrInvalidOption)
+	}
+	if o.Title == "" || o.Price < 0 {
+		return fmt.Errorf("%w: title is required and price cannot be negative", ErrInvalidOption)
+	}
+	if o.PriceType != models.OptionPerPerson && o.PriceType != models.OptionPerBooking {
+		return fmt.Errorf("%w: price_type must be per_person or per_booking", ErrInvalidOption)
+	}
+	if o.Equipment != "" && !equipmentKinds[o.Equipment] {
+		return fmt.Errorf("%w: unknown equipment %q", ErrInvalidOption, o.Equipment)
+	}
+	for i, id := range o.RouteIDs {
+		route, err := s.routes.GetRoute(id)
+		if err != nil {
+			return fmt.Errorf("route %s: %w", id, err)
+		}
+		o.RouteIDs[i] = route.ID
+	}
+	return s.options.UpsertOption(o)
+}
+
+// ForRoute lists the active options bookable on the route; an empty
+// routeID lists every active option.
+func (s *OptionService) ForRoute(routeID string) ([]models.Option, error) {
+	all, err := s.options.ListOptions()
+	if err != nil {
+		return nil, err
+	}
+	if routeID != "" {
+		route, err := s.routes.GetRoute(routeID)
+		if err != nil {
+			return nil, err
+		}
+		routeID = route.ID
+	}
+	out := []models.Option{}
+	for _, o := range all {
+		if o.IsActive && (routeID == "" || optionOnRoute(o, routeID)) {
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
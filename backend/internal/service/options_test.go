@@ -0,0 +1,74 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+func TestSaveOptionValidates(t *testing.T) {
+	options := NewOptionService(repository.New())
+	for _, o := range []models.Option{
+		{Code: "Photo", Title: "Фото"},
+		{Code: "photo", Price: 100},
+		{Code: "photo", Title: "Фото", Price: -1},
+		{Code: "photo", Title: "Фото", PriceType: "per_hour"},
+		{Code: "photo", Title: "Фото", Equipment: "kayak"},
+	} {
+		if err := options.Save(&o); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("save %+v: err = %v, want ErrInvalidOption", o, err)
+		}
+	}
+	unknown := models.Option{Code: "snack", Title: "Перекус", RouteIDs: []string{newID()}}
+	if err := options.Save(&unknown); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("unknown route: err = %v, want ErrNotFound", err)
+	}
+	o := models.Option{Code: "snack", Title: "Перекус", Price: 300}
+	if err := options.Save(&o); err != nil || o.PriceType != models.OptionPerPerson {
+		t.Errorf("default price type: err %v, price_type %q; want per_person", err, o.PriceType)
+	}
+}
+
+func TestOptionsRestrictedToRoutes(t *testing.T) {
+	sv := newServices()
+	options := NewOptionService(sv.repo)
+	sunset := addRoute(t, sv.repo, 4000)
+	for _, o := range []models.Option{
+		{Code: "lantern", Title: "Фонарь", Price: 200, RouteIDs: []string{sunset.ID}, IsActive: true},
+		{Code: "drone", Title: "Съёмка с дрона", Price: 1500, IsActive: false},
+	} {
+		if err := options.Save(&o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	codes := func(routeID string) []string {
+		t.Helper()
+		list, err := options.ForRoute(routeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, o := range list {
+			out = append(out, o.Code)
+		}
+		slices.Sort(out)
+		return out
+	}
+	if got, want := codes(seedRoute), []string{"drybag", "photo", "vest"}; !slices.Equal(got, want) {
+		t.Errorf("options on the seeded route = %v, want %v", got, want)
+	}
+	if got, want := codes(sunset.ID), []string{"drybag", "lantern", "photo", "vest"}; !slices.Equal(got, want) {
+		t.Errorf("options on the sunset route = %v, want %v", got, want)
+	}
+
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 4)
+	for _, code := range []string{"lantern", "drone"} {
+		if _, _, err := sv.pricing.Quote(slot.ID, 1, []models.OptionLine{{Code: code}}); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("quote with %s on the seeded route: err = %v, want ErrInvalidOption", code, err)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+var (
+	ErrInvalidPayment   = errors.New("invalid payment")
+	ErrNoPayments       = errors.New("payments are not configured")
+	ErrNothingToPay     = errors.New("booking has nothing left to pay")
+	ErrNotPayable       = errors.New("booking cannot be paid in its current status")
+	ErrWebhookSignature = errors.New("invalid webhook signature")
+)
+
+// PaymentIntent is a payment opened at the provider: its id there and the
+// page where the customer pays.
+type PaymentIntent struct {
+	Ref             string
+	ConfirmationURL string
+}
+
+// PaymentEvent is a verified webhook notification; Status is
+// models.PaymentSucceeded or models.PaymentFailed.
+type PaymentEvent struct {
+	Ref    string
+	Status string
+}
+
+// PaymentProvider is a payment gateway. Amounts are whole roubles.
+type PaymentProvider interface {
+	Name() string
+	CreateIntent(p models.Payment, description string) (PaymentIntent, error)
+	// ParseWebhook checks the request really comes from the provider.
+	ParseWebhook(body []byte, header http.Header) (PaymentEvent, error)
+	Refund(ref string, amount int) error
+}
+
+// NewPaymentProvider returns the provider configured by PAYMENT_PROVIDER, or
+// nil when it is empty and the server runs without payments. Webhooks are
+// always verified, so a provider needs the secret.
+func NewPaymentProvider(name, secret string) (PaymentProvider, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("payment provider %q needs a webhook secret", name)
+	}
+	switch name {
+	case "fake":
+		return NewFakeProvider(secret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", name)
+	}
+}
+
+// PaymentService takes payments for bookings. With a nil provider nothing
+// can be paid and bookings wait for staff confirmation.
+type PaymentService struct {
+	payments repository.PaymentStore
+	bookings repository.BookingStore
+	booking  *BookingService
+	provider PaymentProvider
+	mode     string
+	deposit  int
+}
+
+func NewPaymentService(repo repository.Repository, booking *BookingService, provider PaymentProvider, mode string, depositPercent int) *PaymentService {
+	return &PaymentService{payments: repo, bookings: repo, booking: booking, provider: provider, mode: mode, deposit: depositPercent}
+}
+
+// Paid is what the booking's payments brought in, net of refunds.
+func (s *PaymentService) Paid(bookingID string) (int, error) {
+	list, err := s.payments.ListBookingPayments(bookingID)
+	if err != nil {
+		return 0, err
+	}
+	paid := 0
+	for _, p := range list {
+		if p.Status == models.PaymentSucceeded || p.Status == models.PaymentRefunded {
+			paid += p.Amount - p.Refunded
+		}
+	}
+	return paid, nil
+}
+
+// Start opens a payment for what is left of the booking price after the
+// gift certificate and earlier payments. A deposit takes the configured
+// percentage and is only possible before anything was paid; an empty mode
+// uses PAYMENT_MODE. A payment still waiting for the customer is returned
+// instead of opening another one, as is the last succeeded payment once
+// nothing is due, so a repeated request never charges twice.
+func (s *PaymentService) Start(bookingID, mode string) (models.Payment, error) {
+	if s.provider == nil {
+		return models.Payment{}, ErrNoPayments
+	}
+	b, err := s.bookings.GetBooking(bookingID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if b.Status != models.BookingPending && b.Status != models.BookingConfirmed {
+		return models.Payment{}, fmt.Errorf("%w: %s", ErrNotPayable, b.Status)
+	}
+	if mode != "" && mode != models.PaymentFull && mode != models.PaymentDeposit {
+		return models.Payment{}, fmt.Errorf("%w: mode must be full or deposit", ErrInvalidPayment)
+	}
+	list, err := s.payments.ListBookingPayments(b.ID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	paid := 0
+	var open, last *models.Payment
+	for i, p := range list {
+		switch p.Status {
+		case models.PaymentPending:
+			open = &list[i]
+		case models.PaymentSucceeded, models.PaymentRefunded:
+			paid += p.Amount - p.Refunded
+			if p.Status == models.PaymentSucceeded {
+				last = &list[i]
+			}
+		}
+	}
+	if open != nil {
+		if mode != "" && mode != open.Mode {
+			return models.Payment{}, fmt.Errorf("%w: a %s payment is already open", ErrInvalidPayment, open.Mode)
+		}
+		return *open, nil
+	}
+	if mode == "" {
+		mode = s.mode
+	}
+	due := b.PriceTotal - b.GiftAmount - paid
+	if due <= 0 {
+		if last != nil {
+			return *last, nil
+		}
+		return models.Payment{}, ErrNothingToPay
+	}
+	amount := due
+	if mode == models.PaymentDeposit {
+		if paid > 0 {
+			return models.Payment{}, fmt.Errorf("%w: deposit is already paid, pay the rest in full", ErrInvalidPayment)
+		}
+		amount = (due*s.deposit + 99) / 100
+	}
+	p := models.Payment{BookingID: b.ID, Provider: s.provider.Name(), Mode: mode, Amount: amount, Status: models.PaymentPending}
+	intent, err := s.provider.CreateIntent(p, "SUP-прогулка, бронь "+b.ID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	p.ProviderRef, p.ConfirmationURL = intent.Ref, intent.ConfirmationURL
+	return p, s.payments.CreatePayment(&p)
+}
+
+// HandleWebhook applies a provider notification. Repeated deliveries are
+// harmless: the payment moves once, and settling the booking is idempotent.
+func (s *PaymentService) HandleWebhook(body []byte, header http.Header) (models.Payment, error) {
+	if s.provider == nil {
+		return models.Payment{}, ErrNoPayments
+	}
+	ev, err := s.provider.ParseWebhook(body, header)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	p, err := s.payments.FindPayment(s.provider.Name(), ev.Ref)
+	if err != nil {
+		return p, err
+	}
+	switch ev.Status {
+	case models.PaymentSucceeded:
+		p, err = s.payments.SetPaymentStatus(p.ID, models.PaymentPending, models.PaymentSucceeded)
+		if err != nil && !errors.Is(err, repository.ErrPaymentClosed) {
+			return p, err
+		}
+		if p.Status != models.PaymentSucceeded {
+			return p, nil
+		}
+		return s.settle(p)
+	case models.PaymentFailed:
+		p, err = s.payments.SetPaymentStatus(p.ID, models.PaymentPending, models.PaymentFailed)
+		if errors.Is(err, repository.ErrPaymentClosed) {
+			err = nil
+		}
+		return p, err
+	default:
+		return p, fmt.Errorf("%w: unknown event status %q", ErrInvalidPayment, ev.Status)
+	}
+}
+
+// settle confirms a pending booking once money came in. Money arriving for
+// a booking that was cancelled in the meantime goes straight back.
+func (s *PaymentService) settle(p models.Payment) (models.Payment, error) {
+	b, err := s.bookings.GetBooking(p.BookingID)
+	if err != nil {
+		return p, err
+	}
+	switch b.Status {
+	case models.BookingPending:
+		_, err = s.booking.ChangeStatus(b.ID, models.BookingConfirmed, "payment "+p.ID+" succeeded")
+	case models.BookingCancelled:
+		log.Printf("payment %s succeeded for cancelled booking %s, refunding", p.ID, b.ID)
+		return s.Refund(p.ID, 0)
+	}
+	return p, err
+}
+
+// Refund returns amount roubles of a succeeded payment; zero refunds all
+// that is left of it. The amount is reserved in the store before the
+// provider is asked, so concurrent refunds cannot pay out more than was
+// paid, and the reservation is undone when the provider fails.
+func (s *PaymentService) Refund(paymentID string, amount int) (models.Payment, error) {
+	if s.provider == nil {
+		return models.Payment{}, ErrNoPayments
+	}
+	p, err := s.payments.GetPayment(paymentID)
+	if err != nil {
+		return p, err
+	}
+	if p.Status != models.PaymentSucceeded {
+		return p, repository.ErrPaymentClosed
+	}
+	if amount == 0 {
+		amount = p.Amount - p.Refunded
+	}
+	if amount < 1 || p.Refunded+amount > p.Amount {
+		return p, repository.ErrRefundTooLarge
+	}
+	reserved, err := s.payments.AddPaymentRefund(p.ID, amount)
+	if err != nil {
+		return reserved, err
+	}
+	if err := s.provider.Refund(p.ProviderRef, amount); err != nil {
+		if _, uerr := s.payments.UndoPaymentRefund(p.ID, amount); uerr != nil {
+			log.Printf("payment %s: undo refund reservation of %d: %v", p.ID, amount, uerr)
+		}
+		return p, err
+	}
+	return reserved, nil
+}
+
+// RefundBooking returns amount roubles of the booking's payments, newest
+// payment first.
+func (s *PaymentService) RefundBooking(bookingID string, amount int) error {
+	list, err := s.payments.ListBookingPayments(bookingID)
+	if err != nil {
+		return err
+	}
+	for i := len(list) - 1; i >= 0 && amount > 0; i-- {
+		p := list[i]
+		part := min(amount, p.Amount-p.Refunded)
+		if p.Status != models.PaymentSucceeded || part < 1 {
+			continue
+		}
+		if _, err := s.Refund(p.ID, part); err != nil {
+			return err
+		}
+		amount -= part
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"sup-anapa/backend/internal/models"
+)
+
+// FakeProvider is an in-process provider for local runs and tests. It
+// never charges anyone: a webhook is a JSON {"ref", "status"} body signed
+// with HMAC-SHA256 in X-Fake-Signature. Event builds such a webhook.
+type FakeProvider struct {
+	secret   string
+	mu       sync.Mutex
+	refunded map[string]int
+}
+
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{secret: secret, refunded: map[string]int{}}
+}
+
+func (f *FakeProvider) Name() string { return "fake" }
+
+func (f *FakeProvider) CreateIntent(p models.Payment, _ string) (PaymentIntent, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return PaymentIntent{}, err
+	}
+	ref := "fake_" + hex.EncodeToString(b)
+	return PaymentIntent{Ref: ref, ConfirmationURL: fmt.Sprintf("https://pay.fake.local/%s?amount=%d", ref, p.Amount)}, nil
+}
+
+func (f *FakeProvider) ParseWebhook(body []byte, header http.Header) (PaymentEvent, error) {
+	if f.secret == "" || !hmac.Equal([]byte(header.Get("X-Fake-Signature")), []byte(f.sign(body))) {
+		return PaymentEvent{}, ErrWebhookSignature
+	}
+	var ev struct {
+		Ref    string `json:"ref"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &ev); err != nil || ev.Ref == "" {
+		return PaymentEvent{}, fmt.Errorf("%w: malformed webhook", ErrInvalidPayment)
+	}
+	return PaymentEvent{Ref: ev.Ref, Status: ev.Status}, nil
+}
+
+func (f *FakeProvider) Refund(ref string, amount int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refunded[ref] += amount
+	return nil
+}
+
+// Refunded reports the total refunded on ref.
+func (f *FakeProvider) Refunded(ref string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.refunded[ref]
+}
+
+// Event returns a webhook body and headers announcing status for ref.
+func (f *FakeProvider) Event(ref, status string) ([]byte, http.Header) {
+	body, _ := json.Marshal(map[string]string{"ref": ref, "status": status})
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Fake-Signature", f.sign(body))
+	return body, header
+}
+
+func (f *FakeProvider) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(f.secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
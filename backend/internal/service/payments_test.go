@@ -0,0 +1,162 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func TestNewPaymentProvider(t *testing.T) {
+	if _, err := NewPaymentProvider("fake", ""); err == nil {
+		t.Error("fake provider without a webhook secret: want an error")
+	}
+	if _, err := NewPaymentProvider("stripe", "s"); err == nil {
+		t.Error("unknown provider: want an error")
+	}
+	if p, err := NewPaymentProvider("", ""); p != nil || err != nil {
+		t.Errorf("no provider = %v, %v; want nil, nil", p, err)
+	}
+}
+
+func TestPaymentsNotConfigured(t *testing.T) {
+	sv := newServices()
+	payments := NewPaymentService(sv.repo, sv.booking, nil, models.PaymentFull, 30)
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 6)
+	b := models.Booking{SlotID: slot.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 1}
+	if err := sv.booking.Create(&b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.Start(b.ID, ""); !errors.Is(err, ErrNoPayments) {
+		t.Errorf("start: err = %v, want ErrNoPayments", err)
+	}
+	if _, err := payments.HandleWebhook([]byte("{}"), nil); !errors.Is(err, ErrNoPayments) {
+		t.Errorf("webhook: err = %v, want ErrNoPayments", err)
+	}
+	if paid, err := payments.Paid(b.ID); paid != 0 || err != nil {
+		t.Errorf("paid = %d, %v; want 0", paid, err)
+	}
+	if got, _ := sv.repo.GetBooking(b.ID); got.Status != models.BookingPending {
+		t.Errorf("booking %s, want pending until staff confirm it", got.Status)
+	}
+}
+
+func TestPaymentWebhooks(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	sv := newServices()
+	provider := NewFakeProvider("secret")
+	payments := NewPaymentService(sv.repo, sv.booking, provider, models.PaymentFull, 30)
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 6)
+	b := models.Booking{SlotID: slot.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 2}
+	if err := sv.booking.Create(&b); err != nil {
+		t.Fatal(err)
+	}
+	status := func() string {
+		got, _ := sv.repo.GetBooking(b.ID)
+		return got.Status
+	}
+
+	// 2 × (3000 + 2500) = 11000, 30% deposit
+	deposit, err := payments.Start(b.ID, models.PaymentDeposit)
+	if err != nil || deposit.Amount != 3300 {
+		t.Fatalf("deposit %d, err %v; want 3300", deposit.Amount, err)
+	}
+	if again, err := payments.Start(b.ID, models.PaymentDeposit); err != nil || again.ID != deposit.ID {
+		t.Fatalf("repeated deposit = %s, %v; want the open payment %s", again.ID, err, deposit.ID)
+	}
+	if _, err := payments.Start(b.ID, models.PaymentFull); !errors.Is(err, ErrInvalidPayment) {
+		t.Fatalf("full payment while a deposit is open: err = %v, want ErrInvalidPayment", err)
+	}
+	body, header := provider.Event(deposit.ProviderRef, models.PaymentSucceeded)
+	forged := NewFakeProvider("other")
+	fBody, fHeader := forged.Event(deposit.ProviderRef, models.PaymentSucceeded)
+	if _, err := payments.HandleWebhook(fBody, fHeader); !errors.Is(err, ErrWebhookSignature) {
+		t.Fatalf("forged webhook: err = %v, want ErrWebhookSignature", err)
+	}
+	if status() != models.BookingPending {
+		t.Fatalf("booking %s after a forged webhook, want pending", status())
+	}
+	for i := 0; i < 2; i++ {
+		if p, err := payments.HandleWebhook(body, header); err != nil || p.Status != models.PaymentSucceeded {
+			t.Fatalf("delivery #%d: status %s, err %v", i+1, p.Status, err)
+		}
+	}
+	if paid, _ := payments.Paid(b.ID); paid != 3300 || status() != models.BookingConfirmed {
+		t.Errorf("paid %d, booking %s; want 3300 once and confirmed", paid, status())
+	}
+
+	if _, err := payments.Start(b.ID, models.PaymentDeposit); !errors.Is(err, ErrInvalidPayment) {
+		t.Errorf("second deposit: err = %v, want ErrInvalidPayment", err)
+	}
+	rest, err := payments.Start(b.ID, "")
+	if err != nil || rest.Amount != 7700 {
+		t.Fatalf("rest %d, err %v; want 7700", rest.Amount, err)
+	}
+	// The booking is cancelled before the money arrives, so it goes back.
+	if _, err := sv.booking.ChangeStatus(b.ID, models.BookingCancelled, ""); err != nil {
+		t.Fatal(err)
+	}
+	body, header = provider.Event(rest.ProviderRef, models.PaymentSucceeded)
+	if _, err := payments.HandleWebhook(body, header); err != nil {
+		t.Fatal(err)
+	}
+	if got := provider.Refunded(rest.ProviderRef); got != 7700 {
+		t.Errorf("refunded %d of a payment for a cancelled booking, want 7700", got)
+	}
+	if _, err := payments.Start(b.ID, ""); !errors.Is(err, ErrNotPayable) {
+		t.Errorf("pay a cancelled booking: err = %v, want ErrNotPayable", err)
+	}
+}
+
+// failingRefunds is a FakeProvider whose refunds fail.
+type failingRefunds struct{ *FakeProvider }
+
+func (failingRefunds) Refund(string, int) error { return errors.New("provider unavailable") }
+
+func TestPaymentRefunds(t *testing.T) {
+	sv := newServices()
+	provider := NewFakeProvider("secret")
+	payments := NewPaymentService(sv.repo, sv.booking, provider, models.PaymentFull, 30)
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 6)
+	b := models.Booking{SlotID: slot.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 1}
+	if err := sv.booking.Create(&b); err != nil {
+		t.Fatal(err)
+	}
+	p, err := payments.Start(b.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.HandleWebhook(provider.Event(p.ProviderRef, models.PaymentSucceeded)); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := payments.Start(b.ID, ""); err != nil || again.ID != p.ID {
+		t.Fatalf("start a paid booking = %s, %v; want the succeeded payment %s", again.ID, err, p.ID)
+	}
+
+	broken := NewPaymentService(sv.repo, sv.booking, failingRefunds{provider}, models.PaymentFull, 30)
+	if _, err := broken.Refund(p.ID, 1000); err == nil {
+		t.Fatal("refund through a failing provider: want an error")
+	}
+	if got, _ := sv.repo.GetPayment(p.ID); got.Refunded != 0 || got.Status != models.PaymentSucceeded {
+		t.Fatalf("after a failed refund: refunded %d, %s; want the reservation undone", got.Refunded, got.Status)
+	}
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			payments.Refund(p.ID, 0)
+		}()
+	}
+	wg.Wait()
+	if got := provider.Refunded(p.ProviderRef); got != p.Amount {
+		t.Errorf("concurrent full refunds paid out %d, want %d once", got, p.Amount)
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+var ErrInvalidOption = errors.New("invalid option")
+
+type PricingService struct {
+	instructors repository.InstructorStore
+	routes      repository.RouteStore
+	slots       repository.SlotStore
+	options     repository.OptionStore
+}
+
+func NewPricingService(instructors repository.InstructorStore, routes repository.RouteStore, slots repository.SlotStore, options repository.OptionStore) *PricingService {
+	return &PricingService{instructors: instructors, routes: routes, slots: slots, options: options}
+}
+
+// Quote prices a booking of the given slot: (instructor base + route base +
+// per-person options) per participant, plus per-booking options. The
+// requested lines only need a code (and a quantity for per-booking
+// options); Quote returns them priced from the catalog.
+func (p *PricingService) Quote(slotID string, participants int, requested []models.OptionLine) (models.PriceBreakdown, []models.OptionLine, error) {
+	slot, err := p.slots.GetSlot(slotID)
+	if err != nil {
+		return models.PriceBreakdown{}, nil, err
+	}
+	inst, err := p.instructors.GetInstructor(slot.InstructorID)
+	if err != nil {
+		return models.PriceBreakdown{}, nil, fmt.Errorf("instructor %s: %w", slot.InstructorID, err)
+	}
+	route, err := p.routes.GetRoute(slot.RouteID)
+	if err != nil {
+		return models.PriceBreakdown{}, nil, fmt.Errorf("route %s: %w", slot.RouteID, err)
+	}
+	lines, err := p.resolveOptions(route.ID, participants, requested)
+	if err != nil {
+		return models.PriceBreakdown{}, nil, err
+	}
+	breakdown := []models.PriceLine{
+		{Code: "instructor", Title: inst.Name, Amount: inst.BasePrice * participants},
+		{Code: "route", Title: route.Title, Amount: route.BasePrice * participants},
+	}
+	perPerson := inst.BasePrice + route.BasePrice
+	flat := 0
+	for _, l := range lines {
+		if l.Amount == 0 {
+			continue
+		}
+		if l.PriceType == models.OptionPerPerson {
+			perPerson += l.UnitPrice
+		} else {
+			flat += l.Amount
+		}
+		breakdown = append(breakdown, models.PriceLine{Code: l.Code, Title: l.Title, Amount: l.Amount})
+	}
+	return models.PriceBreakdown{Participants: participants, PerPerson: perPerson, Lines: breakdown, Total: perPerson*participants + flat}, lines, nil
+}
+
+// resolveOptions checks the requested add-ons against the catalog: known,
+// active, offered on the route and listed once. Per-person options cover
+// every participant.
+func (p *PricingService) resolveOptions(routeID string, participants int, requested []models.OptionLine) ([]models.OptionLine, error) {
+	seen := map[string]bool{}
+	out := []models.OptionLine{}
+	for _, req := range requested {
+		if seen[req.Code] {
+			return nil, fmt.Errorf("%w %q: listed twice", ErrInvalidOption, req.Code)
+		}
+		seen[req.Code] = true
+		o, err := p.options.GetOption(req.Code)
+		if errors.Is(err, repository.ErrNotFound) || err == nil && !o.IsActive {
+			return nil, fmt.Errorf("%w %q", ErrInvalidOption, req.Code)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !optionOnRoute(o, routeID) {
+			return nil, fmt.Errorf("%w %q: not available on this route", ErrInvalidOption, req.Code)
+		}
+		qty := participants
+		if o.PriceType == models.OptionPerBooking {
+			qty = req.Quantity
+			if qty == 0 {
+				qty = 1
+			}
+			if qty < 1 {
+				return nil, fmt.Errorf("%w %q: quantity must be positive", ErrInvalidOption, req.Code)
+			}
+		} else if req.Quantity != 0 && req.Quantity != participants {
+			return nil, fmt.Errorf("%w %q: priced per person, quantity must equal participants", ErrInvalidOption, req.Code)
+		}
+		out = append(out, models.OptionLine{Code: o.Code, Title: o.Title, Quantity: qty, UnitPrice: o.Price, Amount: o.Price * qty, PriceType: o.PriceType, Equipment: o.Equipment})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out, nil
+}
+
+func optionOnRoute(o models.Option, routeID string) bool {
+	return allowedFor(o.RouteIDs, routeID)
+}
+
+// allowedFor reports whether id is in a restriction list; an empty list
+// allows everything.
+func allowedFor(ids []string, id string) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// LegacyOptions converts the old {"code": true} options map into option
+// lines; codes set to false are dropped.
+func LegacyOptions(options map[string]any) ([]models.OptionLine, error) {
+	out := []models.OptionLine{}
+	for code, v := range options {
+		selected, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%w %q: expected true or false", ErrInvalidOption, code)
+		}
+		if selected {
+			out = append(out, models.OptionLine{Code: code})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out, nil
+}
+
+// legacyMap is the {"code": true} view of option lines kept in
+// Booking.Options for older clients.
+func legacyMap(lines []models.OptionLine) map[string]any {
+	out := map[string]any{}
+	for _, l := range lines {
+		out[l.Code] = true
+	}
+	return out
+}
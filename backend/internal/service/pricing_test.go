@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func TestQuote(t *testing.T) {
+	sv := newServices()
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 6)
+	transfer := models.Option{Code: "transfer", Title: "Трансфер", Price: 1000, PriceType: models.OptionPerBooking, IsActive: true}
+	if err := sv.repo.UpsertOption(&transfer); err != nil {
+		t.Fatal(err)
+	}
+	// seeded: instructor 3000, route 2500, photo 700 per person
+	tests := []struct {
+		name         string
+		participants int
+		lines        []models.OptionLine
+		perPerson    int
+		total        int
+	}{
+		{"base only", 2, nil, 5500, 11000},
+		{"per-person option", 2, []models.OptionLine{{Code: "photo"}}, 6200, 12400},
+		{"per-booking option", 2, []models.OptionLine{{Code: "transfer"}}, 5500, 12000},
+		{"per-booking quantity equal to participants", 2, []models.OptionLine{{Code: "transfer", Quantity: 2}}, 5500, 13000},
+		{"both kinds", 3, []models.OptionLine{{Code: "photo"}, {Code: "transfer", Quantity: 3}}, 6200, 21600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, lines, err := sv.pricing.Quote(slot.ID, tt.participants, tt.lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.PerPerson != tt.perPerson || q.Total != tt.total {
+				t.Errorf("per_person %d, total %d; want %d, %d", q.PerPerson, q.Total, tt.perPerson, tt.total)
+			}
+			sum := 0
+			for _, l := range q.Lines {
+				sum += l.Amount
+			}
+			if sum != q.Total {
+				t.Errorf("lines add up to %d, total is %d", sum, q.Total)
+			}
+			if len(lines) != len(tt.lines) {
+				t.Errorf("priced lines %+v, want %d", lines, len(tt.lines))
+			}
+		})
+	}
+}
+
+func TestQuoteRejectsBadOptions(t *testing.T) {
+	sv := newServices()
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 6)
+	tests := map[string][]models.OptionLine{
+		"unknown":                   {{Code: "jetpack"}},
+		"listed twice":              {{Code: "photo"}, {Code: "photo"}},
+		"per-person wrong quantity": {{Code: "photo", Quantity: 1}},
+	}
+	for name, lines := range tests {
+		if _, _, err := sv.pricing.Quote(slot.ID, 2, lines); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: err = %v, want ErrInvalidOption", name, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+var (
+	ErrInvalidPromo  = errors.New("invalid promo code")
+	ErrPromoRejected = errors.New("promo code cannot be applied")
+)
+
+var promoCode = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+type PromoService struct {
+	promos      repository.PromoStore
+	routes      repository.RouteStore
+	instructors repository.InstructorStore
+	slots       repository.SlotStore
+	pricing     *PricingService
+}
+
+func NewPromoService(repo repository.Repository, pricing *PricingService) *PromoService {
+	return &PromoService{promos: repo, routes: repo, instructors: repo, slots: repo, pricing: pricing}
+}
+
+// NormalizeCode is the stored form of a promo or gift code typed by a
+// customer.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (s *PromoService) Save(p *models.PromoCode) error {
+	p.Code = NormalizeCode(p.Code)
+	if !promoCode.MatchString(p.Code) {
+		return fmt.Errorf("%w: code must be 3-32 letters, digits, _ or -", ErrInvalidPromo)
+	}
+	switch {
+	case p.Kind != models.PromoPercent && p.Kind != models.PromoFixed:
+		return fmt.Errorf("%w: kind must be percent or fixed", ErrInvalidPromo)
+	case p.Value < 1 || p.Kind == models.PromoPercent && p.Value > 100:
+		return fmt.Errorf("%w: value must be 1-100 for percent codes and positive for fixed ones", ErrInvalidPromo)
+	case p.ValidFrom != nil && p.ValidTo != nil && !p.ValidTo.After(*p.ValidFrom):
+		return fmt.Errorf("%w: valid_to must be after valid_from", ErrInvalidPromo)
+	case p.MaxUses < 0 || p.MinParticipants < 0:
+		return fmt.Errorf("%w: max_uses and min_participants cannot be negative", ErrInvalidPromo)
+	}
+	for i, id := range p.RouteIDs {
+		route, err := s.routes.GetRoute(id)
+		if err != nil {
+			return fmt.Errorf("route %s: %w", id, err)
+		}
+		p.RouteIDs[i] = route.ID
+	}
+	for i, id := range p.InstructorIDs {
+		inst, err := s.instructors.GetInstructor(id)
+		if err != nil {
+			return fmt.Errorf("instructor %s: %w", id, err)
+		}
+		p.InstructorIDs[i] = inst.ID
+	}
+	return s.promos.UpsertPromoCode(p)
+}
+
+// Check quotes a booking with the code applied, so the checkout form can
+// show the discount before the booking is submitted.
+func (s *PromoService) Check(code, slotID string, participants int, lines []models.OptionLine) (models.PriceBreakdown, models.PromoCode, error) {
+	quote, _, err := s.pricing.Quote(slotID, participants, lines)
+	if err != nil {
+		return quote, models.PromoCode{}, err
+	}
+	p, err := s.Apply(&quote, code, slotID, time.Now())
+	return quote, p, err
+}
+
+// Apply discounts the quote for a booking of the slot made at now. The code
+// must be active, inside its validity window, not used up and match the
+// booking's route, instructor and participant count.
+func (s *PromoService) Apply(quote *models.PriceBreakdown, code, slotID string, now time.Time) (models.PromoCode, error) {
+	p, err := s.promos.GetPromoCode(NormalizeCode(code))
+	if errors.Is(err, repository.ErrNotFound) {
+		return p, fmt.Errorf("%w: code not found", ErrPromoRejected)
+	}
+	if err != nil {
+		return p, err
+	}
+	switch {
+	case !p.IsActive:
+		return p, fmt.Errorf("%w: code is not active", ErrPromoRejected)
+	case p.ValidFrom != nil && now.Before(*p.ValidFrom):
+		return p, fmt.Errorf("%w: code is valid from %s", ErrPromoRejected, p.ValidFrom.Format(time.RFC3339))
+	case p.ValidTo != nil && !now.Before(*p.ValidTo):
+		return p, fmt.Errorf("%w: code expired at %s", ErrPromoRejected, p.ValidTo.Format(time.RFC3339))
+	case p.MaxUses > 0 && p.Used >= p.MaxUses:
+		return p, repository.ErrPromoExhausted
+	}
+	slot, err := s.slots.GetSlot(slotID)
+	if err != nil {
+		return p, err
+	}
+	if err := promoFits(p, slot, quote.Participants); err != nil {
+		return p, err
+	}
+	applyDiscount(quote, p)
+	return p, nil
+}
+
+// Reprice discounts a quote for a rescheduled booking. The code was already
+// accepted and counted, so only the route, instructor and participant rules
+// are checked; if the new slot breaks them the discount is dropped.
+func (s *PromoService) Reprice(quote *models.PriceBreakdown, code string, slot models.TimeSlot) error {
+	p, err := s.promos.GetPromoCode(code)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if promoFits(p, slot, quote.Participants) == nil {
+		applyDiscount(quote, p)
+	}
+	return nil
+}
+
+func promoFits(p models.PromoCode, slot models.TimeSlot, participants int) error {
+	switch {
+	case participants < p.MinParticipants:
+		return fmt.Errorf("%w: needs at least %d participants", ErrPromoRejected, p.MinParticipants)
+	case !allowedFor(p.RouteIDs, slot.RouteID):
+		return fmt.Errorf("%w: not valid on this route", ErrPromoRejected)
+	case !allowedFor(p.InstructorIDs, slot.InstructorID):
+		return fmt.Errorf("%w: not valid with this instructor", ErrPromoRejected)
+	}
+	return nil
+}
+
+// applyDiscount takes the code off the total, never below zero, and lists
+// it as a negative line.
+func applyDiscount(quote *models.PriceBreakdown, p models.PromoCode) {
+	off := p.Value
+	if p.Kind == models.PromoPercent {
+		off = quote.Total * p.Value / 100
+	}
+	off = min(off, quote.Total)
+	quote.Discount = off
+	quote.Total -= off
+	quote.Lines = append(quote.Lines, models.PriceLine{Code: "promo", Title: "Промокод " + p.Code, Amount: -off})
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+func TestSavePromoValidates(t *testing.T) {
+	sv := newServices()
+	now := time.Now()
+	for _, p := range []models.PromoCode{
+		{Code: "ab", Kind: models.PromoPercent, Value: 10},
+		{Code: "SUMMER", Kind: "bogo", Value: 10},
+		{Code: "SUMMER", Kind: models.PromoPercent, Value: 101},
+		{Code: "SUMMER", Kind: models.PromoFixed, Value: 0},
+		{Code: "SUMMER", Kind: models.PromoFixed, Value: 500, ValidFrom: &now, ValidTo: &now},
+		{Code: "SUMMER", Kind: models.PromoFixed, Value: 500, MaxUses: -1},
+	} {
+		if err := sv.promo.Save(&p); !errors.Is(err, ErrInvalidPromo) {
+			t.Errorf("save %+v: err = %v, want ErrInvalidPromo", p, err)
+		}
+	}
+	p := models.PromoCode{Code: " summer-26 ", Kind: models.PromoPercent, Value: 10, IsActive: true}
+	if err := sv.promo.Save(&p); err != nil || p.Code != "SUMMER-26" {
+		t.Errorf("save: err %v, code %q; want stored as SUMMER-26", err, p.Code)
+	}
+}
+
+func TestApplyPromo(t *testing.T) {
+	sv := newServices()
+	d := day(10)
+	slot := addSlot(t, sv.repo, d.Add(9*time.Hour), 6)
+	other := addRoute(t, sv.repo, 2500)
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	for _, p := range []models.PromoCode{
+		{Code: "TEN", Kind: models.PromoPercent, Value: 10, IsActive: true},
+		{Code: "HUGE", Kind: models.PromoFixed, Value: 50000, IsActive: true},
+		{Code: "OFF", Kind: models.PromoPercent, Value: 10},
+		{Code: "SOON", Kind: models.PromoPercent, Value: 10, ValidFrom: &future, IsActive: true},
+		{Code: "GONE", Kind: models.PromoPercent, Value: 10, ValidTo: &past, IsActive: true},
+		{Code: "TRIO", Kind: models.PromoPercent, Value: 10, MinParticipants: 3, IsActive: true},
+		{Code: "ROUTE", Kind: models.PromoPercent, Value: 10, RouteIDs: []string{other.ID}, IsActive: true},
+		{Code: "MARIA", Kind: models.PromoPercent, Value: 10, InstructorIDs: []string{"22222222222222222222222222222222"}, IsActive: true},
+		{Code: "ONCE", Kind: models.PromoFixed, Value: 1000, MaxUses: 1, IsActive: true},
+	} {
+		if err := sv.promo.Save(&p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 2 × (3000 + 2500) = 11000
+	tests := []struct {
+		code  string
+		total int
+		err   error
+	}{
+		{"ten", 9900, nil},
+		{"HUGE", 0, nil},
+		{"NOPE", 11000, ErrPromoRejected},
+		{"OFF", 11000, ErrPromoRejected},
+		{"SOON", 11000, ErrPromoRejected},
+		{"GONE", 11000, ErrPromoRejected},
+		{"TRIO", 11000, ErrPromoRejected},
+		{"ROUTE", 11000, ErrPromoRejected},
+		{"MARIA", 11000, ErrPromoRejected},
+	}
+	for _, tt := range tests {
+		q, _, err := sv.promo.Check(tt.code, slot.ID, 2, nil)
+		if !errors.Is(err, tt.err) || err == nil && q.Total != tt.total {
+			t.Errorf("%s: total %d, err %v; want %d, %v", tt.code, q.Total, err, tt.total, tt.err)
+		}
+	}
+
+	b := models.Booking{SlotID: slot.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 2, PromoCode: "once"}
+	if err := sv.booking.Create(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.PriceTotal != 10000 || b.PromoCode != "ONCE" {
+		t.Errorf("booking total %d, code %q; want 10000 with ONCE", b.PriceTotal, b.PromoCode)
+	}
+	again := models.Booking{SlotID: slot.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 2, PromoCode: "ONCE"}
+	if err := sv.booking.Create(&again); !errors.Is(err, repository.ErrPromoExhausted) {
+		t.Errorf("second use of a single-use code: err = %v, want ErrPromoExhausted", err)
+	}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+const (
+	CancelByCustomer = "customer"
+	CancelByStaff    = "staff"
+)
+
+var weatherLevels = map[string]bool{"Отличные": true, "Хорошие": true, "Нормальные": true, "Плохие": true}
+
+// RefundRule is one line of the cancellation policy. A rule matches when
+// every condition it sets holds: the cancellation is at least
+// MinHoursBefore hours before the slot starts, the slot forecast has the
+// Weather level, and the cancellation comes from Initiator.
+type RefundRule struct {
+	Name           string   `json:"name"`
+	MinHoursBefore *float64 `json:"min_hours_before,omitempty"`
+	Weather        string   `json:"weather,omitempty"`
+	Initiator      string   `json:"initiator,omitempty"`
+	Percent        int      `json:"percent"`
+}
+
+// RefundQuote is what cancelling the booking now would give back of the
+// money paid through the provider.
+type RefundQuote struct {
+	BookingID   string  `json:"booking_id"`
+	Paid        int     `json:"paid"`
+	Percent     int     `json:"percent"`
+	Refundable  int     `json:"refundable"`
+	Rule        string  `json:"rule"`
+	HoursBefore float64 `json:"hours_before"`
+	Weather     string  `json:"weather,omitempty"`
+	// GiftAmount is what a gift certificate paid; GiftRefundable of it goes
+	// back to the certificate at the same percent.
+	GiftAmount     int `json:"gift_amount,omitempty"`
+	GiftRefundable int `json:"gift_refundable,omitempty"`
+}
+
+func hours(h float64) *float64 { return &h }
+
+// DefaultRefundPolicy applies when REFUND_POLICY is empty.
+var DefaultRefundPolicy = []RefundRule{
+	{Name: "bad_weather", Weather: "Плохие", Percent: 100},
+	{Name: "early", MinHoursBefore: hours(24), Percent: 100},
+	{Name: "late", MinHoursBefore: hours(0), Percent: 50},
+}
+
+// ParseRefundPolicy reads REFUND_POLICY: a JSON array of rules, first match
+// wins; a cancellation no rule matches refunds nothing.
+func ParseRefundPolicy(raw string) ([]RefundRule, error) {
+	if raw == "" {
+		return DefaultRefundPolicy, nil
+	}
+	var rules []RefundRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("REFUND_POLICY: %w", err)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("REFUND_POLICY: at least one rule is required")
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule_%d", i+1)
+		}
+		switch {
+		case r.Percent < 0 || r.Percent > 100:
+			return nil, fmt.Errorf("REFUND_POLICY %s: percent must be between 0 and 100", r.Name)
+		case r.Weather != "" && !weatherLevels[r.Weather]:
+			return nil, fmt.Errorf("REFUND_POLICY %s: unknown weather level %q", r.Name, r.Weather)
+		case r.Initiator != "" && r.Initiator != CancelByCustomer && r.Initiator != CancelByStaff:
+			return nil, fmt.Errorf("REFUND_POLICY %s: initiator must be customer or staff", r.Name)
+		case r.MinHoursBefore != nil && (math.IsNaN(*r.MinHoursBefore) || math.IsInf(*r.MinHoursBefore, 0)):
+			return nil, fmt.Errorf("REFUND_POLICY %s: min_hours_before must be a number", r.Name)
+		}
+	}
+	return rules, nil
+}
+
+type RefundService struct {
+	bookings repository.BookingStore
+	slots    repository.SlotStore
+	routes   repository.RouteStore
+	booking  *BookingService
+	payments *PaymentService
+	weather  *WeatherService
+	policy   []RefundRule
+}
+
+func NewRefundService(repo repository.Repository, booking *BookingService, payments *PaymentService, weather *WeatherService, policy []RefundRule) *RefundService {
+	return &RefundService{bookings: repo, slots: repo, routes: repo, booking: booking, payments: payments, weather: weather, policy: policy}
+}
+
+// Quote evaluates the policy for cancelling the booking at now.
+func (s *RefundService) Quote(bookingID, initiator string, now time.Time) (RefundQuote, error) {
+	b, err := s.bookings.GetBooking(bookingID)
+	if err != nil {
+		return RefundQuote{}, err
+	}
+	if !repository.CanTransition(b.Status, models.BookingCancelled) {
+		return RefundQuote{}, fmt.Errorf("%w: cannot cancel a %s booking", repository.ErrInvalidTransition, b.Status)
+	}
+	slot, err := s.slots.GetSlot(b.SlotID)
+	if err != nil {
+		return RefundQuote{}, err
+	}
+	paid, err := s.payments.Paid(b.ID)
+	if err != nil {
+		return RefundQuote{}, err
+	}
+	q := RefundQuote{BookingID: b.ID, Paid: paid, Rule: "none", HoursBefore: math.Round(slot.StartAt.Sub(now).Hours()*10) / 10}
+	q.Weather = s.forecast(slot)
+	for _, r := range s.policy {
+		if r.MinHoursBefore != nil && slot.StartAt.Sub(now).Hours() < *r.MinHoursBefore ||
+			r.Weather != "" && r.Weather != q.Weather ||
+			r.Initiator != "" && r.Initiator != initiator {
+			continue
+		}
+		q.Rule, q.Percent = r.Name, r.Percent
+		break
+	}
+	q.Refundable = paid * q.Percent / 100
+	q.GiftAmount, q.GiftRefundable = b.GiftAmount, b.GiftAmount*q.Percent/100
+	return q, nil
+}
+
+// forecast is the slot's weather level, or "" when no rule looks at the
+// weather or the forecast is unavailable (weather rules then don't match).
+func (s *RefundService) forecast(slot models.TimeSlot) string {
+	needed := false
+	for _, r := range s.policy {
+		needed = needed || r.Weather != ""
+	}
+	if !needed {
+		return ""
+	}
+	route, err := s.routes.GetRoute(slot.RouteID)
+	if err != nil {
+		log.Printf("refund quote: route %s: %v", slot.RouteID, err)
+		return ""
+	}
+	w, err := s.weather.ForSlot(route, slot)
+	if err != nil {
+		log.Printf("refund quote: weather for slot %s: %v", slot.ID, err)
+		return ""
+	}
+	return w.ConditionsLevel
+}
+
+// Cancel cancels the booking, records the refund the policy allows on it
+// and sends that amount back through the provider. A failed provider
+// refund is logged and left to staff; the booking stays cancelled.
+// Cancelling a cancelled booking again reports the refund already made;
+// of concurrent cancellations only the one that changed the status pays.
+func (s *RefundService) Cancel(bookingID, initiator, reason string) (models.Booking, RefundQuote, error) {
+	if b, err := s.bookings.GetBooking(bookingID); err == nil && b.Status == models.BookingCancelled {
+		return b, recordedRefund(b), nil
+	}
+	q, err := s.Quote(bookingID, initiator, time.Now())
+	if err != nil {
+		return models.Booking{}, q, err
+	}
+	b, changed, err := s.booking.Cancel(bookingID, reason, models.Refund{Amount: q.Refundable, GiftPercent: q.Percent, Rule: q.Rule})
+	if err != nil {
+		return b, q, err
+	}
+	if !changed {
+		return b, recordedRefund(b), nil
+	}
+	if err := s.payments.RefundBooking(bookingID, q.Refundable); err != nil {
+		log.Printf("refund of %d for booking %s: %v", q.Refundable, bookingID, err)
+	}
+	return b, q, nil
+}
+
+func recordedRefund(b models.Booking) RefundQuote {
+	return RefundQuote{BookingID: b.ID, Refundable: b.RefundAmount, Rule: b.RefundRule, GiftAmount: b.GiftAmount, GiftRefundable: b.GiftRefund}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+)
+
+func TestParseRefundPolicy(t *testing.T) {
+	rules, err := ParseRefundPolicy("")
+	if err != nil || len(rules) != len(DefaultRefundPolicy) {
+		t.Fatalf("empty policy = %v, %v; want the default", rules, err)
+	}
+	rules, err = ParseRefundPolicy(`[{"min_hours_before": 48, "percent": 100}, {"initiator": "staff", "percent": 100}]`)
+	if err != nil || len(rules) != 2 || rules[0].Name != "rule_1" || *rules[0].MinHoursBefore != 48 || rules[1].Initiator != CancelByStaff {
+		t.Errorf("policy = %+v, %v", rules, err)
+	}
+	for _, raw := range []string{
+		`{}`,
+		`[]`,
+		`[{"percent": 101}]`,
+		`[{"weather": "Штормовые", "percent": 100}]`,
+		`[{"initiator": "robot", "percent": 100}]`,
+	} {
+		if _, err := ParseRefundPolicy(raw); err == nil {
+			t.Errorf("ParseRefundPolicy(%s): want an error", raw)
+		}
+	}
+}
+
+func TestRefundPolicy(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	sv := newServices()
+	provider := NewFakeProvider("secret")
+	payments := NewPaymentService(sv.repo, sv.booking, provider, models.PaymentFull, 30)
+	policy, err := ParseRefundPolicy(`[
+		{"name": "calm_sea", "weather": "Отличные", "initiator": "staff", "percent": 100},
+		{"name": "early", "min_hours_before": 24, "percent": 90},
+		{"name": "late", "min_hours_before": 2, "percent": 50}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refunds := NewRefundService(sv.repo, sv.booking, payments, sv.weather, policy)
+
+	// The second seeded instructor (3200) has no seeded slots to clash with.
+	book := func(start time.Time, gift int) models.Booking {
+		t.Helper()
+		slot := models.TimeSlot{ID: newID(), InstructorID: "22222222222222222222222222222222", RouteID: seedRoute, StartAt: start, EndAt: start.Add(90 * time.Minute), Capacity: 4, Remaining: 4, Status: "open"}
+		if err := sv.repo.BulkCreateSlots([]models.TimeSlot{slot}); err != nil {
+			t.Fatal(err)
+		}
+		b := models.Booking{SlotID: slot.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 2}
+		if gift > 0 {
+			g := models.GiftCertificate{Kind: models.GiftValue, Amount: gift}
+			if err := sv.gifts.Issue(&g); err != nil {
+				t.Fatal(err)
+			}
+			b.GiftCode = g.Code
+		}
+		if err := sv.booking.Create(&b); err != nil {
+			t.Fatal(err)
+		}
+		p, err := payments.Start(b.ID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := payments.HandleWebhook(provider.Event(p.ProviderRef, models.PaymentSucceeded)); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	now := time.Now().Truncate(time.Minute)
+
+	// 2 × (3200 + 2500) = 11400
+	tests := []struct {
+		name      string
+		in        time.Duration
+		initiator string
+		rule      string
+		refund    int
+	}{
+		{"ten days ahead", 240 * time.Hour, CancelByCustomer, "early", 10260},
+		{"five hours ahead", 5 * time.Hour, CancelByCustomer, "late", 5700},
+		{"an hour ahead", time.Hour, CancelByCustomer, "none", 0},
+		{"staff on a calm day", time.Hour, CancelByStaff, "calm_sea", 11400},
+	}
+	for i, tt := range tests {
+		b := book(now.Add(tt.in+time.Duration(i)*24*time.Hour), 0)
+		q, err := refunds.Quote(b.ID, tt.initiator, now.Add(time.Duration(i)*24*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Rule != tt.rule || q.Paid != 11400 || q.Refundable != tt.refund {
+			t.Errorf("%s: rule %s, refundable %d of %d; want %s, %d", tt.name, q.Rule, q.Refundable, q.Paid, tt.rule, tt.refund)
+		}
+	}
+
+	// 4000 paid by the certificate, 7400 through the provider.
+	b := book(now.Add(5*time.Hour+30*time.Minute), 4000)
+	for i := 0; i < 2; i++ {
+		cancelled, q, err := refunds.Cancel(b.ID, CancelByCustomer, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cancelled.Status != models.BookingCancelled || q.Refundable != 3700 || q.GiftRefundable != 2000 {
+			t.Errorf("cancel #%d: %s, refund %d + %d to the certificate; want cancelled, 3700 + 2000", i+1, cancelled.Status, q.Refundable, q.GiftRefundable)
+		}
+	}
+	payList, _ := sv.repo.ListBookingPayments(b.ID)
+	if len(payList) != 1 || provider.Refunded(payList[0].ProviderRef) != 3700 {
+		t.Errorf("provider refunds = %+v, want 3700 once", payList)
+	}
+	if g, _ := sv.gifts.Get(b.GiftCode); g.Balance != 2000 || g.Status != models.GiftActive {
+		t.Errorf("certificate balance %d (%s), want 2000 back and active", g.Balance, g.Status)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+var (
+	ErrOfferExpired   = errors.New("reschedule offer has expired")
+	ErrSlotNotOffered = errors.New("slot is not one of the offered alternatives")
+)
+
+type RescheduleOfferService struct {
+	offers    repository.RescheduleOfferStore
+	bookings  repository.BookingStore
+	slots     repository.SlotStore
+	routes    repository.RouteStore
+	weather   *WeatherService
+	booking   *BookingService
+	notifier  Notifier
+	lookahead time.Duration
+}
+
+func NewRescheduleOfferService(repo repository.Repository, weather *WeatherService, booking *BookingService, notifier Notifier, lookahead time.Duration) *RescheduleOfferService {
+	return &RescheduleOfferService{offers: repo, bookings: repo, slots: repo, routes: repo, weather: weather, booking: booking, notifier: notifier, lookahead: lookahead}
+}
+
+// ScanBookings is the background job: every confirmed booking starting
+// within the lookahead whose slot has bad weather gets an offer listing the
+// alternatives WeatherService suggests that have a better forecast. The
+// offer link goes to the customer through the notifier.
+func (s *RescheduleOfferService) ScanBookings(now time.Time) error {
+	bookings, err := s.bookings.ListBookingsStarting(now, now.Add(s.lookahead), models.BookingConfirmed)
+	if err != nil {
+		return err
+	}
+	for _, b := range bookings {
+		o, err := s.offerFor(b, now)
+		if err != nil {
+			log.Printf("reschedule offer for booking %s: %v", b.ID, err)
+			continue
+		}
+		if o == nil {
+			continue
+		}
+		log.Printf("reschedule offer for booking %s (%s, score %d): %d alternatives", b.ID, o.ConditionsLevel, o.Score, len(o.SlotIDs))
+		n := Notification{Kind: "reschedule_offer", BookingID: b.ID, Phone: b.Phone, Messenger: b.Messenger, Token: o.Token,
+			Text: fmt.Sprintf("Прогноз на вашу прогулку: %s Можно перенести бронь на другое время.", o.Explanation)}
+		if err := s.notifier.Notify(context.Background(), n); err != nil {
+			log.Printf("reschedule offer for booking %s: notify: %v", b.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *RescheduleOfferService) offerFor(b models.Booking, now time.Time) (*models.RescheduleOffer, error) {
+	slot, err := s.slots.GetSlot(b.SlotID)
+	if err != nil {
+		return nil, err
+	}
+	route, err := s.routes.GetRoute(slot.RouteID)
+	if err != nil {
+		return nil, err
+	}
+	w, err := s.weather.ForSlot(route, slot)
+	if err != nil {
+		return nil, err
+	}
+	if w.ConditionsLevel != "Плохие" {
+		return nil, nil
+	}
+	ids := []string{}
+	for _, alt := range w.SuggestedSlots {
+		if alt.ID != slot.ID && alt.Remaining >= b.Participants && alt.StartAt.After(now) && s.betterWeather(route, alt) {
+			ids = append(ids, alt.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	o := models.RescheduleOffer{BookingID: b.ID, SlotIDs: ids, ConditionsLevel: w.ConditionsLevel, Score: w.Score, Explanation: w.Explanation, ExpiresAt: slot.StartAt}
+	if err := s.offers.CreateRescheduleOffer(&o); err != nil {
+		if errors.Is(err, repository.ErrOfferExists) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &o, nil
+}
+
+// betterWeather reports whether alt has a safe forecast that is not bad, so
+// an offer never moves a customer into weather as poor as the one they
+// leave. An alternative that cannot be scored is left out.
+func (s *RescheduleOfferService) betterWeather(route models.Route, alt models.TimeSlot) bool {
+	if alt.RouteID != route.ID {
+		r, err := s.routes.GetRoute(alt.RouteID)
+		if err != nil {
+			return false
+		}
+		route = r
+	}
+	w, err := s.weather.ForSlot(route, alt)
+	return err == nil && !w.Unsafe && w.ConditionsLevel != "Плохие"
+}
+
+// Get returns the offer together with the current state of its slots.
+func (s *RescheduleOfferService) Get(token string) (models.RescheduleOffer, []models.TimeSlot, error) {
+	o, err := s.offers.GetRescheduleOffer(token)
+	if err != nil {
+		return o, nil, err
+	}
+	slots := []models.TimeSlot{}
+	for _, id := range o.SlotIDs {
+		if slot, err := s.slots.GetSlot(id); err == nil {
+			slots = append(slots, slot)
+		}
+	}
+	return o, slots, nil
+}
+
+// Accept moves the booking to the chosen slot. The offer is claimed first so
+// two clicks on the link cannot both reschedule; a failed move hands it back.
+func (s *RescheduleOfferService) Accept(token, slotID string) (models.RescheduleOffer, models.Booking, error) {
+	o, err := s.offers.GetRescheduleOffer(token)
+	if err != nil {
+		return o, models.Booking{}, err
+	}
+	if o.Status != models.OfferPending {
+		return o, models.Booking{}, repository.ErrOfferClosed
+	}
+	if time.Now().After(o.ExpiresAt) {
+		return o, models.Booking{}, ErrOfferExpired
+	}
+	offered := false
+	for _, id := range o.SlotIDs {
+		offered = offered || id == slotID
+	}
+	if !offered {
+		return o, models.Booking{}, ErrSlotNotOffered
+	}
+	o, err = s.offers.SetRescheduleOfferStatus(token, models.OfferPending, models.OfferAccepted, slotID)
+	if err != nil {
+		return o, models.Booking{}, err
+	}
+	b, err := s.booking.Reschedule(o.BookingID, slotID, "weather: "+o.Explanation)
+	if err != nil {
+		_, _ = s.offers.SetRescheduleOfferStatus(token, models.OfferAccepted, models.OfferPending, "")
+		return o, b, err
+	}
+	return o, b, nil
+}
+
+func (s *RescheduleOfferService) Decline(token string) (models.RescheduleOffer, error) {
+	o, err := s.offers.GetRescheduleOffer(token)
+	if err != nil {
+		return o, err
+	}
+	if time.Now().After(o.ExpiresAt) {
+		return o, ErrOfferExpired
+	}
+	return s.offers.SetRescheduleOfferStatus(token, models.OfferPending, models.OfferDeclined, "")
+}
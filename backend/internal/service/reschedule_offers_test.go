@@ -0,0 +1,128 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+func TestScanBookingsOffersOnlyBetterWeather(t *testing.T) {
+	repo := repository.New()
+	d := day(10)
+	// stormy until 15:00, calm afterwards
+	weather := newTestWeather(repo, &stubWeather{hour: func(h time.Time) ForecastHour {
+		if h.Before(d.Add(15 * time.Hour)) {
+			return storm
+		}
+		return calm
+	}})
+	booked := addSlot(t, repo, d.Add(10*time.Hour), 4)
+	stormy := addSlot(t, repo, d.Add(12*time.Hour), 4)
+	clear := addSlot(t, repo, d.Add(16*time.Hour), 4)
+	b := models.Booking{SlotID: booked.ID, CustomerName: "Иван", Phone: "+79990000000", Participants: 2}
+	if err := repo.CreateBooking(&b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.PatchBookingStatus(b.ID, models.BookingConfirmed, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := &recordingNotifier{}
+	offers := NewRescheduleOfferService(repo, weather, nil, notifier, 30*24*time.Hour)
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	if err := offers.ScanBookings(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := repo.ListRescheduleOffers(models.OfferPending)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("offers = %v, %v; want one", list, err)
+	}
+	o := list[0]
+	if len(o.SlotIDs) != 1 || o.SlotIDs[0] != clear.ID {
+		t.Errorf("alternatives = %v, want only %s (not the stormy %s)", o.SlotIDs, clear.ID, stormy.ID)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].Token != o.Token || notifier.sent[0].Phone != b.Phone {
+		t.Errorf("notifications = %+v, want the offer token sent to %s", notifier.sent, b.Phone)
+	}
+	if strings.Contains(logs.String(), o.Token) {
+		t.Errorf("offer token leaked into the log: %s", logs.String())
+	}
+}
+
+func TestScanBookingsSkipsWithoutBetterSlot(t *testing.T) {
+	repo := repository.New()
+	d := day(10)
+	weather := newTestWeather(repo, &stubWeather{hour: func(time.Time) ForecastHour { return storm }})
+	booked := addSlot(t, repo, d.Add(10*time.Hour), 4)
+	addSlot(t, repo, d.Add(12*time.Hour), 4)
+	b := models.Booking{SlotID: booked.ID, CustomerName: "Иван", Phone: "+79990000000", Participants: 1}
+	if err := repo.CreateBooking(&b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.PatchBookingStatus(b.ID, models.BookingConfirmed, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := &recordingNotifier{}
+	if err := NewRescheduleOfferService(repo, weather, nil, notifier, 30*24*time.Hour).ScanBookings(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := repo.ListRescheduleOffers(""); len(list) != 0 || len(notifier.sent) != 0 {
+		t.Errorf("offers = %v, notifications = %v; want none when every alternative is stormy", list, notifier.sent)
+	}
+}
+
+func TestAcceptRescheduleOffer(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	d := day(10)
+	sv := newServicesWith(&stubWeather{hour: func(h time.Time) ForecastHour {
+		if h.Before(d.Add(15 * time.Hour)) {
+			return storm
+		}
+		return calm
+	}})
+	booked := addSlot(t, sv.repo, d.Add(10*time.Hour), 4)
+	clear := addSlot(t, sv.repo, d.Add(16*time.Hour), 4)
+	b := models.Booking{SlotID: booked.ID, CustomerName: "Иван", Phone: "+79990000000", Participants: 2}
+	if err := sv.repo.CreateBooking(&b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sv.repo.PatchBookingStatus(b.ID, models.BookingConfirmed, ""); err != nil {
+		t.Fatal(err)
+	}
+	offers := NewRescheduleOfferService(sv.repo, sv.weather, sv.booking, &recordingNotifier{}, 30*24*time.Hour)
+	if err := offers.ScanBookings(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := sv.repo.ListRescheduleOffers(models.OfferPending)
+	if len(list) != 1 {
+		t.Fatalf("offers = %v, want one", list)
+	}
+	token := list[0].Token
+
+	if _, _, err := offers.Accept(token, booked.ID); !errors.Is(err, ErrSlotNotOffered) {
+		t.Errorf("accept a slot that was not offered: err = %v, want ErrSlotNotOffered", err)
+	}
+	o, moved, err := offers.Accept(token, clear.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != models.OfferAccepted || moved.SlotID != clear.ID {
+		t.Errorf("offer %s, booking on %s; want accepted and moved to %s", o.Status, moved.SlotID, clear.ID)
+	}
+	if _, _, err := offers.Accept(token, clear.ID); !errors.Is(err, repository.ErrOfferClosed) {
+		t.Errorf("second accept: err = %v, want ErrOfferClosed", err)
+	}
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+var ErrInvalidTemplate = errors.New("invalid schedule template")
+
+const defaultTimezone = "Europe/Moscow"
+
+// PlannedSlot is a slot a template yields; Exists marks the ones clashing
+// with a slot the instructor already has, which generation skips.
+type PlannedSlot struct {
+	models.TimeSlot
+	TemplateID string `json:"template_id"`
+	Exists     bool   `json:"exists"`
+}
+
+type ScheduleService struct {
+	templates   repository.TemplateStore
+	slots       repository.SlotStore
+	routes      repository.RouteStore
+	instructors repository.InstructorStore
+	horizonDays int
+}
+
+func NewScheduleService(repo repository.Repository, horizonDays int) *ScheduleService {
+	return &ScheduleService{templates: repo, slots: repo, routes: repo, instructors: repo, horizonDays: horizonDays}
+}
+
+func (s *ScheduleService) Save(t *models.ScheduleTemplate) error {
+	if t.Timezone == "" {
+		t.Timezone = defaultTimezone
+	}
+	if err := validateTemplate(*t); err != nil {
+		return err
+	}
+	if _, err := s.instructors.GetInstructor(t.InstructorID); err != nil {
+		return fmt.Errorf("instructor %s: %w", t.InstructorID, err)
+	}
+	if _, err := s.routes.GetRoute(t.RouteID); err != nil {
+		return fmt.Errorf("route %s: %w", t.RouteID, err)
+	}
+	return s.templates.UpsertTemplate(t)
+}
+
+func validateTemplate(t models.ScheduleTemplate) error {
+	if len(t.Weekdays) == 0 || len(t.Times) == 0 {
+		return fmt.Errorf("%w: weekdays and times are required", ErrInvalidTemplate)
+	}
+	for _, d := range t.Weekdays {
+		if d < 1 || d > 7 {
+			return fmt.Errorf("%w: weekday %d, expected 1 (Monday) to 7 (Sunday)", ErrInvalidTemplate, d)
+		}
+	}
+	for _, v := range t.Times {
+		if _, err := time.Parse("15:04", v); err != nil {
+			return fmt.Errorf("%w: time %q, expected HH:MM", ErrInvalidTemplate, v)
+		}
+	}
+	if t.Capacity < 1 {
+		return fmt.Errorf("%w: capacity must be positive", ErrInvalidTemplate)
+	}
+	for _, v := range []string{t.ValidFrom, t.ValidTo} {
+		if _, err := time.Parse("2006-01-02", v); v != "" && err != nil {
+			return fmt.Errorf("%w: date %q, expected YYYY-MM-DD", ErrInvalidTemplate, v)
+		}
+	}
+	if t.ValidFrom != "" && t.ValidTo != "" && t.ValidTo < t.ValidFrom {
+		return fmt.Errorf("%w: valid_to is before valid_from", ErrInvalidTemplate)
+	}
+	if _, err := time.LoadLocation(t.Timezone); err != nil {
+		return fmt.Errorf("%w: timezone %q", ErrInvalidTemplate, t.Timezone)
+	}
+	return nil
+}
+
+// Plan expands the active templates (or just templateID) over the days
+// starting at from, marking slots that already exist.
+func (s *ScheduleService) Plan(from time.Time, days int, templateID string) ([]PlannedSlot, error) {
+	var templates []models.ScheduleTemplate
+	if templateID != "" {
+		t, err := s.templates.GetTemplate(templateID)
+		if err != nil {
+			return nil, err
+		}
+		templates = []models.ScheduleTemplate{t}
+	} else {
+		all, err := s.templates.ListTemplates()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range all {
+			if t.IsActive {
+				templates = append(templates, t)
+			}
+		}
+	}
+	out := []PlannedSlot{}
+	existing := map[string][]models.TimeSlot{}
+	for _, t := range templates {
+		route, err := s.routes.GetRoute(t.RouteID)
+		if err != nil {
+			return nil, fmt.Errorf("template %s route: %w", t.ID, err)
+		}
+		if _, loaded := existing[t.InstructorID]; !loaded {
+			slots, err := s.slots.ListInstructorSlots(t.InstructorID, from.Add(-24*time.Hour), from.AddDate(0, 0, days+1))
+			if err != nil {
+				return nil, err
+			}
+			existing[t.InstructorID] = slots
+		}
+		for _, slot := range expandTemplate(t, route, from, days) {
+			_, taken := findOverlap(slot, existing[t.InstructorID])
+			out = append(out, PlannedSlot{TimeSlot: slot, TemplateID: t.ID, Exists: taken})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].StartAt.Before(out[j].StartAt) })
+	return out, nil
+}
+
+func expandTemplate(t models.ScheduleTemplate, route models.Route, from time.Time, days int) []models.TimeSlot {
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		return nil
+	}
+	weekdays := map[int]bool{}
+	for _, d := range t.Weekdays {
+		weekdays[d] = true
+	}
+	local := from.In(loc)
+	first := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	out := []models.TimeSlot{}
+	for i := 0; i < days; i++ {
+		day := first.AddDate(0, 0, i)
+		date := day.Format("2006-01-02")
+		weekday := int(day.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		if !weekdays[weekday] || (t.ValidFrom != "" && date < t.ValidFrom) || (t.ValidTo != "" && date > t.ValidTo) {
+			continue
+		}
+		for _, v := range t.Times {
+			hm, err := time.Parse("15:04", v)
+			if err != nil {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), hm.Hour(), hm.Minute(), 0, 0, loc)
+			if !start.After(from) {
+				continue
+			}
+			out = append(out, models.TimeSlot{InstructorID: t.InstructorID, RouteID: t.RouteID, StartAt: start.UTC(), EndAt: start.Add(time.Duration(route.DurationMinutes) * time.Minute).UTC(), Capacity: t.Capacity})
+		}
+	}
+	return out
+}
+
+// Generate creates the planned slots that do not exist yet. Running it again
+// over the same range creates nothing.
+func (s *ScheduleService) Generate(from time.Time, days int) ([]models.TimeSlot, error) {
+	plan, err := s.Plan(from, days, "")
+	if err != nil {
+		return nil, err
+	}
+	missing := []models.TimeSlot{}
+	for _, p := range plan {
+		if !p.Exists {
+			missing = append(missing, p.TimeSlot)
+		}
+	}
+	if len(missing) == 0 {
+		return []models.TimeSlot{}, nil
+	}
+	return s.slots.CreateSlotsIfAbsent(missing)
+}
+
+// GenerateAhead is the background job keeping the schedule filled for the
+// configured horizon.
+func (s *ScheduleService) GenerateAhead(now time.Time) error {
+	created, err := s.Generate(now, s.horizonDays)
+	if err != nil {
+		return err
+	}
+	if len(created) > 0 {
+		log.Printf("schedule generator created %d slots", len(created))
+	}
+	return nil
+}
+
+func (s *ScheduleService) HorizonDays() int { return s.horizonDays }
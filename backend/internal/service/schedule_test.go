@@ -0,0 +1,81 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+func TestSaveTemplateValidates(t *testing.T) {
+	schedule := NewScheduleService(repository.New(), 14)
+	valid := func() models.ScheduleTemplate {
+		return models.ScheduleTemplate{InstructorID: seedInstructor, RouteID: seedRoute, Weekdays: []int{6, 7}, Times: []string{"10:00"}, Capacity: 4, IsActive: true}
+	}
+	tests := []struct {
+		name   string
+		change func(*models.ScheduleTemplate)
+	}{
+		{"no weekdays", func(t *models.ScheduleTemplate) { t.Weekdays = nil }},
+		{"weekday 0", func(t *models.ScheduleTemplate) { t.Weekdays = []int{0} }},
+		{"bad time", func(t *models.ScheduleTemplate) { t.Times = []string{"25:00"} }},
+		{"no capacity", func(t *models.ScheduleTemplate) { t.Capacity = 0 }},
+		{"bad date", func(t *models.ScheduleTemplate) { t.ValidFrom = "01.06.2026" }},
+		{"reversed dates", func(t *models.ScheduleTemplate) { t.ValidFrom, t.ValidTo = "2026-09-01", "2026-06-01" }},
+		{"bad timezone", func(t *models.ScheduleTemplate) { t.Timezone = "Europe/Anapa" }},
+	}
+	for _, tt := range tests {
+		tpl := valid()
+		tt.change(&tpl)
+		if err := schedule.Save(&tpl); !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("%s: err = %v, want ErrInvalidTemplate", tt.name, err)
+		}
+	}
+	tpl := valid()
+	tpl.RouteID = newID()
+	if err := schedule.Save(&tpl); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("unknown route: err = %v, want ErrNotFound", err)
+	}
+	tpl = valid()
+	if err := schedule.Save(&tpl); err != nil || tpl.Timezone != "Europe/Moscow" {
+		t.Errorf("valid template: err %v, timezone %q; want saved in Europe/Moscow", err, tpl.Timezone)
+	}
+}
+
+func TestGenerateFillsTheScheduleOnce(t *testing.T) {
+	const instructor = "22222222222222222222222222222222" // seeded without slots
+	repo := repository.New()
+	schedule := NewScheduleService(repo, 14)
+	from := day(30)
+	tpl := models.ScheduleTemplate{InstructorID: instructor, RouteID: seedRoute, Weekdays: []int{1, 2, 3, 4, 5, 6, 7}, Times: []string{"10:00"}, Capacity: 4, ValidTo: from.AddDate(0, 0, 5).Format("2006-01-02"), IsActive: true}
+	if err := schedule.Save(&tpl); err != nil {
+		t.Fatal(err)
+	}
+	// 10:00 in Moscow is 07:00 UTC.
+	manual := models.TimeSlot{ID: newID(), InstructorID: instructor, RouteID: seedRoute, StartAt: from.AddDate(0, 0, 2).Add(7 * time.Hour), EndAt: from.AddDate(0, 0, 2).Add(8*time.Hour + 30*time.Minute), Capacity: 2, Remaining: 2, Status: "open"}
+	if err := repo.BulkCreateSlots([]models.TimeSlot{manual}); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := schedule.Generate(from, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Six days up to valid_to, less the one the manual slot already takes.
+	if len(created) != 5 {
+		t.Fatalf("created %d slots, want 5", len(created))
+	}
+	for _, s := range created {
+		if s.StartAt.Hour() != 7 || s.StartAt.Minute() != 0 || s.EndAt.Sub(s.StartAt) != 90*time.Minute || s.Capacity != 4 {
+			t.Errorf("slot %s–%s capacity %d, want 07:00 UTC for 90 minutes, capacity 4", s.StartAt, s.EndAt, s.Capacity)
+		}
+		if s.StartAt.Equal(manual.StartAt) {
+			t.Errorf("generated a slot over the manual one at %s", s.StartAt)
+		}
+	}
+	if again, err := schedule.Generate(from, 7); err != nil || len(again) != 0 {
+		t.Errorf("second run created %d slots (err %v), want none", len(again), err)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+// SlotCheck is the validation outcome of one item of a bulk request.
+type SlotCheck struct {
+	Index  int             `json:"index"`
+	Slot   models.TimeSlot `json:"slot"`
+	Errors []string        `json:"errors,omitempty"`
+}
+
+type SlotService struct {
+	slots       repository.SlotStore
+	routes      repository.RouteStore
+	instructors repository.InstructorStore
+}
+
+func NewSlotService(repo repository.Repository) *SlotService {
+	return &SlotService{slots: repo, routes: repo, instructors: repo}
+}
+
+// Check validates the slots against their route, each other and the
+// instructors' existing schedule. A missing end_at is derived from the route
+// duration. An item with the id of a stored slot is an update: it keeps the
+// instructor, cannot drop capacity below the seats already taken and, while
+// any are taken, cannot move to another route or time. ok reports whether
+// every item passed.
+func (s *SlotService) Check(slots []models.TimeSlot) (report []SlotCheck, ok bool, err error) {
+	routes := map[string]*models.Route{}
+	instructors := map[string]bool{}
+	report = make([]SlotCheck, len(slots))
+	ok = true
+	for i, slot := range slots {
+		c := SlotCheck{Index: i}
+		if slot.Capacity < 1 {
+			c.Errors = append(c.Errors, "capacity must be positive")
+		}
+		if slot.StartAt.IsZero() {
+			c.Errors = append(c.Errors, "start_at is required")
+		}
+		if slot.ID != "" {
+			old, err := s.slots.GetSlot(slot.ID)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return nil, false, err
+			}
+			if err == nil {
+				if slot.InstructorID != old.InstructorID {
+					c.Errors = append(c.Errors, "instructor_id cannot change on update")
+				}
+				taken := old.Capacity - old.Remaining
+				if slot.Capacity < taken {
+					c.Errors = append(c.Errors, fmt.Sprintf("capacity %d is below %d seats already taken", slot.Capacity, taken))
+				}
+				if taken > 0 && slot.RouteID != old.RouteID {
+					c.Errors = append(c.Errors, fmt.Sprintf("route_id cannot change while %d seats are taken", taken))
+				}
+				if taken > 0 && (!slot.StartAt.Equal(old.StartAt) || !slot.EndAt.IsZero() && !slot.EndAt.Equal(old.EndAt)) {
+					c.Errors = append(c.Errors, fmt.Sprintf("start_at and end_at cannot change while %d seats are taken", taken))
+				}
+			}
+		}
+		if slot.InstructorID == "" {
+			c.Errors = append(c.Errors, "instructor_id is required")
+		} else if known, seen := instructors[slot.InstructorID]; seen && !known {
+			c.Errors = append(c.Errors, "instructor not found")
+		} else if !seen {
+			_, err := s.instructors.GetInstructor(slot.InstructorID)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return nil, false, err
+			}
+			instructors[slot.InstructorID] = err == nil
+			if err != nil {
+				c.Errors = append(c.Errors, "instructor not found")
+			}
+		}
+		var route *models.Route
+		if slot.RouteID == "" {
+			c.Errors = append(c.Errors, "route_id is required")
+		} else if r, seen := routes[slot.RouteID]; seen {
+			route = r
+		} else {
+			r, err := s.routes.GetRoute(slot.RouteID)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return nil, false, err
+			}
+			if err == nil {
+				route = &r
+			}
+			routes[slot.RouteID] = route
+		}
+		if slot.RouteID != "" && route == nil {
+			c.Errors = append(c.Errors, "route not found")
+		}
+		if route != nil && !slot.StartAt.IsZero() {
+			want := time.Duration(route.DurationMinutes) * time.Minute
+			if slot.EndAt.IsZero() {
+				slot.EndAt = slot.StartAt.Add(want)
+			} else if got := slot.EndAt.Sub(slot.StartAt); got != want {
+				c.Errors = append(c.Errors, fmt.Sprintf("duration %s does not match route duration %s", got, want))
+			}
+		}
+		if len(c.Errors) == 0 {
+			// No route lasts a day, so anything overlapping starts within it.
+			existing, err := s.slots.ListInstructorSlots(slot.InstructorID, slot.StartAt.Add(-24*time.Hour), slot.EndAt)
+			if err != nil {
+				return nil, false, err
+			}
+			if other, clash := findOverlap(slot, existing); clash {
+				c.Errors = append(c.Errors, fmt.Sprintf("overlaps slot %s at %s", other.ID, other.StartAt.Format(time.RFC3339)))
+			} else if j, clash := batchOverlap(slot, report[:i]); clash {
+				c.Errors = append(c.Errors, fmt.Sprintf("overlaps item %d", j))
+			}
+		}
+		c.Slot = slot
+		report[i] = c
+		ok = ok && len(c.Errors) == 0
+	}
+	return report, ok, nil
+}
+
+// Create validates the slots and writes them only if every item passed.
+// With dryRun nothing is written.
+func (s *SlotService) Create(slots []models.TimeSlot, dryRun bool) ([]SlotCheck, bool, error) {
+	report, ok, err := s.Check(slots)
+	if err != nil || !ok || dryRun {
+		return report, ok, err
+	}
+	checked := make([]models.TimeSlot, len(report))
+	for i, c := range report {
+		checked[i] = c.Slot
+	}
+	if err := s.slots.BulkCreateSlots(checked); err != nil {
+		return report, false, err
+	}
+	return report, true, nil
+}
+
+func findOverlap(slot models.TimeSlot, others []models.TimeSlot) (models.TimeSlot, bool) {
+	for _, other := range others {
+		if other.ID != slot.ID && slot.StartAt.Before(other.EndAt) && other.StartAt.Before(slot.EndAt) {
+			return other, true
+		}
+	}
+	return models.TimeSlot{}, false
+}
+
+func batchOverlap(slot models.TimeSlot, checked []SlotCheck) (int, bool) {
+	for _, c := range checked {
+		if len(c.Errors) == 0 && c.Slot.InstructorID == slot.InstructorID && slot.StartAt.Before(c.Slot.EndAt) && c.Slot.StartAt.Before(slot.EndAt) {
+			return c.Index, true
+		}
+	}
+	return 0, false
+}
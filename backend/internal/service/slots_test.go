@@ -0,0 +1,81 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+func TestSlotCheck(t *testing.T) {
+	repo := repository.New()
+	slots := NewSlotService(repo)
+	d := day(10)
+	existing := addSlot(t, repo, d.Add(9*time.Hour), 4)
+	other := addRoute(t, repo, 2500)
+	b := models.Booking{SlotID: existing.ID, CustomerName: "Анна", Phone: "+79990000000", Participants: 3}
+	if err := repo.CreateBooking(&b); err != nil {
+		t.Fatal(err)
+	}
+	slot := func(hour float64) models.TimeSlot {
+		return models.TimeSlot{InstructorID: seedInstructor, RouteID: seedRoute, StartAt: d.Add(time.Duration(hour * float64(time.Hour))), Capacity: 4}
+	}
+
+	batch := []models.TimeSlot{
+		slot(10),   // clashes with the stored 09:00–10:30
+		slot(12),   // fine, end_at derived
+		slot(13),   // clashes with item 1
+		slot(14.5), // starts as item 1 ends
+		{InstructorID: seedInstructor, RouteID: seedRoute, StartAt: d.Add(17 * time.Hour), EndAt: d.Add(18 * time.Hour), Capacity: 4},
+		{InstructorID: "99999999999999999999999999999999", RouteID: seedRoute, StartAt: d.Add(19 * time.Hour), Capacity: 0},
+		{ID: existing.ID, InstructorID: seedInstructor, RouteID: seedRoute, StartAt: existing.StartAt, Capacity: 2},
+		{ID: existing.ID, InstructorID: "22222222222222222222222222222222", RouteID: seedRoute, StartAt: existing.StartAt, Capacity: 4},
+		{ID: existing.ID, InstructorID: seedInstructor, RouteID: other.ID, StartAt: existing.StartAt, Capacity: 4},
+		{ID: existing.ID, InstructorID: seedInstructor, RouteID: seedRoute, StartAt: d.Add(20 * time.Hour), Capacity: 4},
+	}
+	want := [][]string{
+		{"overlaps slot " + existing.ID},
+		nil,
+		{"overlaps item 1"},
+		nil,
+		{"does not match route duration"},
+		{"capacity must be positive", "instructor not found"},
+		{"capacity 2 is below 3 seats already taken"},
+		{"instructor_id cannot change on update"},
+		{"route_id cannot change while 3 seats are taken"},
+		{"start_at and end_at cannot change while 3 seats are taken"},
+	}
+	report, ok, err := slots.Create(batch, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("ok = true for a batch with errors")
+	}
+	for i, c := range report {
+		if len(c.Errors) != len(want[i]) {
+			t.Errorf("item %d errors = %q, want %q", i, c.Errors, want[i])
+			continue
+		}
+		for j, msg := range want[i] {
+			if !strings.Contains(c.Errors[j], msg) {
+				t.Errorf("item %d error %q, want it to mention %q", i, c.Errors[j], msg)
+			}
+		}
+	}
+	if !report[1].Slot.EndAt.Equal(d.Add(13*time.Hour + 30*time.Minute)) {
+		t.Errorf("derived end_at = %s, want 13:30", report[1].Slot.EndAt)
+	}
+	if got, _ := repo.ListInstructorSlots(seedInstructor, d, d.AddDate(0, 0, 1)); len(got) != 1 {
+		t.Errorf("stored %d slots that day, want only the existing one: a failed batch writes nothing", len(got))
+	}
+
+	if _, ok, err := slots.Create([]models.TimeSlot{slot(12), slot(14.5)}, false); err != nil || !ok {
+		t.Fatalf("clean batch: ok %v, err %v", ok, err)
+	}
+	if got, _ := repo.ListInstructorSlots(seedInstructor, d, d.AddDate(0, 0, 1)); len(got) != 3 {
+		t.Errorf("stored %d slots that day, want 3", len(got))
+	}
+}
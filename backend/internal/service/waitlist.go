@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+var ErrSeatsAvailable = errors.New("slot has free seats, book it directly")
+
+type WaitlistService struct {
+	store    repository.WaitlistStore
+	slots    repository.SlotStore
+	notifier Notifier
+	offerTTL time.Duration
+}
+
+func NewWaitlistService(store repository.WaitlistStore, slots repository.SlotStore, notifier Notifier, offerTTL time.Duration) *WaitlistService {
+	return &WaitlistService{store: store, slots: slots, notifier: notifier, offerTTL: offerTTL}
+}
+
+// Join queues a customer for a slot that cannot take their party right now.
+func (s *WaitlistService) Join(e *models.WaitlistEntry) error {
+	slot, err := s.slots.GetSlot(e.SlotID)
+	if err != nil {
+		return err
+	}
+	if slot.Status != "open" && slot.Status != "closed" || e.Participants > slot.Capacity {
+		return repository.ErrSlotUnavailable
+	}
+	if slot.Status == "open" && slot.Remaining >= e.Participants {
+		return ErrSeatsAvailable
+	}
+	return s.store.JoinWaitlist(e)
+}
+
+// Offer hands an entry seats out of turn (admin action).
+func (s *WaitlistService) Offer(id string) (models.WaitlistEntry, error) {
+	e, err := s.store.OfferWaitlistEntry(id, s.offerTTL)
+	if err != nil {
+		return e, err
+	}
+	s.notify(e)
+	return e, nil
+}
+
+// Cancel removes an entry from the queue; if it held an offer, the seats go
+// to the next customer in line.
+func (s *WaitlistService) Cancel(id string) (models.WaitlistEntry, error) {
+	before, err := s.store.GetWaitlistEntry(id)
+	if err != nil {
+		return before, err
+	}
+	e, err := s.store.CancelWaitlistEntry(id)
+	if err != nil {
+		return e, err
+	}
+	if before.Status == models.WaitlistOffered {
+		s.OfferFreedSeats(e.SlotID)
+	}
+	return e, nil
+}
+
+// OfferFreedSeats is called whenever seats return to a slot; it offers them
+// to the waiting customers in queue order.
+func (s *WaitlistService) OfferFreedSeats(slotID string) {
+	offered, err := s.store.OfferWaitlist(slotID, s.offerTTL)
+	if err != nil {
+		log.Printf("waitlist offer for slot %s: %v", slotID, err)
+		return
+	}
+	for _, e := range offered {
+		log.Printf("waitlist: offered %d seats on slot %s to entry %s until %s", e.Participants, e.SlotID, e.ID, e.OfferExpiresAt.Format(time.RFC3339))
+		s.notify(e)
+	}
+}
+
+// notify sends the customer the hold token of an offer; they book with it
+// before it expires.
+func (s *WaitlistService) notify(e models.WaitlistEntry) {
+	n := Notification{Kind: "waitlist_offer", WaitlistID: e.ID, Phone: e.Phone, Messenger: e.Messenger, Token: e.HoldToken,
+		Text: fmt.Sprintf("Освободились места на прогулку (%d). Они держатся за вами %d минут.", e.Participants, int(s.offerTTL.Minutes()))}
+	if err := s.notifier.Notify(context.Background(), n); err != nil {
+		log.Printf("waitlist offer for entry %s: notify: %v", e.ID, err)
+	}
+}
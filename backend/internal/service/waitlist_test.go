@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+func TestWaitlistOffersFreedSeatsInTurn(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	sv := newServices()
+	slot := addSlot(t, sv.repo, day(10).Add(9*time.Hour), 2)
+	join := func(name string, n int) (models.WaitlistEntry, error) {
+		e := models.WaitlistEntry{SlotID: slot.ID, CustomerName: name, Phone: "+79990000000", Participants: n}
+		return e, sv.waitlist.Join(&e)
+	}
+	status := func(e models.WaitlistEntry) string {
+		t.Helper()
+		got, err := sv.repo.GetWaitlistEntry(e.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.Status
+	}
+
+	if _, err := join("Анна", 1); !errors.Is(err, ErrSeatsAvailable) {
+		t.Fatalf("join an open slot: err = %v, want ErrSeatsAvailable", err)
+	}
+	var booked []models.Booking
+	for range 2 {
+		b := models.Booking{SlotID: slot.ID, CustomerName: "Иван", Phone: "+79990000001", Participants: 1}
+		if err := sv.booking.Create(&b); err != nil {
+			t.Fatal(err)
+		}
+		booked = append(booked, b)
+	}
+	if _, err := join("Анна", 3); !errors.Is(err, repository.ErrSlotUnavailable) {
+		t.Errorf("join for more seats than the slot has: err = %v, want ErrSlotUnavailable", err)
+	}
+	first, err := join("Анна", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := join("Пётр", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sv.booking.ChangeStatus(booked[0].ID, models.BookingCancelled, ""); err != nil {
+		t.Fatal(err)
+	}
+	if status(first) != models.WaitlistOffered || status(second) != models.WaitlistWaiting {
+		t.Fatalf("after one seat freed: first %s, second %s; want offered, waiting", status(first), status(second))
+	}
+	offered, _ := sv.repo.GetWaitlistEntry(first.ID)
+	if sent := sv.notifier.sent; len(sent) != 1 || sent[0].Kind != "waitlist_offer" || sent[0].WaitlistID != first.ID || sent[0].Token != offered.HoldToken || sent[0].Phone != first.Phone {
+		t.Fatalf("notifications = %+v, want the offer with hold token %s to entry %s", sent, offered.HoldToken, first.ID)
+	}
+	if _, err := sv.waitlist.Cancel(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status(second) != models.WaitlistOffered {
+		t.Fatalf("second = %s after the first declined, want offered", status(second))
+	}
+
+	offer, _ := sv.repo.GetWaitlistEntry(second.ID)
+	if sent := sv.notifier.sent; len(sent) != 2 || sent[1].WaitlistID != second.ID || sent[1].Token != offer.HoldToken {
+		t.Fatalf("notifications = %+v, want the second offer sent to entry %s", sent, second.ID)
+	}
+	b := models.Booking{SlotID: slot.ID, HoldToken: offer.HoldToken, CustomerName: "Пётр", Phone: "+79990000000", Participants: 1}
+	if err := sv.booking.Create(&b); err != nil {
+		t.Fatalf("book the offered seat: %v", err)
+	}
+	if got, _ := sv.repo.GetWaitlistEntry(second.ID); got.Status != models.WaitlistAccepted || got.BookingID != b.ID {
+		t.Errorf("entry after booking = %s (booking %q), want accepted with %s", got.Status, got.BookingID, b.ID)
+	}
+}
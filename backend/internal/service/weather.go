@@ -1,175 +1,436 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
+	"log"
 	"math"
 	"net/http"
-	"net/url"
 	"time"
 
 	"sup-anapa/backend/internal/models"
 	"sup-anapa/backend/internal/repository"
 )
 
+var ErrUnsafeConditions = errors.New("weather conditions are unsafe for this slot")
+
 type WeatherService struct {
-	repo     *repository.Repository
-	apiURL   string
-	http     *http.Client
-	cacheTTL time.Duration
+	cache       repository.WeatherCache
+	slots       repository.SlotStore
+	routes      repository.RouteStore
+	providers   []WeatherProvider
+	marineURL   string
+	http        *http.Client
+	timeout     time.Duration
+	guardBudget time.Duration
+	cacheTTL    time.Duration
+	blockUnsafe bool
+	rules       WeatherRules
+}
+
+// WeatherProvider is a forecast source. Forecast returns the hourly series
+// for the days from the day of from to the day of to.
+type WeatherProvider interface {
+	Name() string
+	Forecast(ctx context.Context, lat, lng float64, from, to time.Time) (Forecast, error)
+}
+
+// NewWeatherProvider returns a provider named in WEATHER_PROVIDERS; an empty
+// url keeps its public endpoint.
+func NewWeatherProvider(name, url string) (WeatherProvider, error) {
+	switch name {
+	case OpenMeteoName:
+		return NewOpenMeteoProvider(url), nil
+	case MetNorwayName:
+		return NewMetNorwayProvider(url), nil
+	default:
+		return nil, fmt.Errorf("unknown weather provider %q", name)
+	}
 }
 
 type WeatherResponse struct {
 	Temperature     float64           `json:"temperature"`
 	WindSpeed       float64           `json:"wind_speed"`
+	WindGusts       float64           `json:"wind_gusts"`
+	WindDirection   int               `json:"wind_direction"`
 	Precipitation   float64           `json:"precipitation"`
 	CloudCover      int               `json:"cloud_cover"`
+	WaveHeight      *float64          `json:"wave_height,omitempty"`
+	WavePeriod      *float64          `json:"wave_period,omitempty"`
+	SeaTemperature  *float64          `json:"sea_temperature,omitempty"`
 	ConditionsLevel string            `json:"conditions_level"`
 	Explanation     string            `json:"explanation"`
 	Score           int               `json:"score"`
+	Offshore        bool              `json:"offshore"`
+	Unsafe          bool              `json:"unsafe"`
+	SafetyNote      string            `json:"safety_note,omitempty"`
+	Provider        string            `json:"provider,omitempty"`
 	SuggestedSlots  []models.TimeSlot `json:"suggested_slots,omitempty"`
 	Raw             map[string]any    `json:"raw,omitempty"`
+	AverageScore    int               `json:"average_score"`
+	WorstHour       time.Time         `json:"worst_hour"`
+	Hours           []HourlyWeather   `json:"hours,omitempty"`
+}
+
+// HourlyWeather is one hour of a slot scored on its own.
+type HourlyWeather struct {
+	Time            time.Time `json:"time"`
+	Temperature     float64   `json:"temperature"`
+	WindSpeed       float64   `json:"wind_speed"`
+	WindGusts       float64   `json:"wind_gusts"`
+	WindDirection   int       `json:"wind_direction"`
+	Precipitation   float64   `json:"precipitation"`
+	CloudCover      int       `json:"cloud_cover"`
+	WaveHeight      *float64  `json:"wave_height,omitempty"`
+	WavePeriod      *float64  `json:"wave_period,omitempty"`
+	SeaTemperature  *float64  `json:"sea_temperature,omitempty"`
+	ConditionsLevel string    `json:"conditions_level"`
+	Score           int       `json:"score"`
+	Offshore        bool      `json:"offshore"`
+	Unsafe          bool      `json:"unsafe"`
 }
 
-func NewWeatherService(repo *repository.Repository, apiURL string, cacheTTL time.Duration) *WeatherService {
-	return &WeatherService{repo: repo, apiURL: apiURL, cacheTTL: cacheTTL, http: &http.Client{Timeout: 10 * time.Second}}
+// NewWeatherService scores forecasts for routes. Providers are tried in
+// order, each for at most timeout. An empty marineURL turns off wave and
+// sea temperature data. With blockUnsafe, Guard refuses slots whose
+// forecast is unsafe; it spends at most guardBudget fetching a forecast
+// that is not cached. Hours are scored by the rules of the route's
+// difficulty.
+func NewWeatherService(cache repository.WeatherCache, slots repository.SlotStore, routes repository.RouteStore, providers []WeatherProvider, marineURL string, timeout, guardBudget, cacheTTL time.Duration, blockUnsafe bool, rules WeatherRules) *WeatherService {
+	return &WeatherService{cache: cache, slots: slots, routes: routes, providers: providers, marineURL: marineURL, timeout: timeout, guardBudget: guardBudget, cacheTTL: cacheTTL, blockUnsafe: blockUnsafe, rules: rules, http: &http.Client{Timeout: 10 * time.Second}}
 }
 
+// Get scores the hour of target. The offshore rule needs the shore bearing
+// of routeID and is skipped without it; without a route the default rules
+// apply.
 func (s *WeatherService) Get(lat, lng float64, target time.Time, routeID, instructorID string) (WeatherResponse, error) {
-	targetHour := target.UTC().Truncate(time.Hour)
-	if cached, err := s.repo.FindWeatherSnapshot(lat, lng, targetHour, s.cacheTTL); err == nil {
-		resp := mapSnapshot(cached)
-		if resp.ConditionsLevel == "Плохие" {
-			resp.SuggestedSlots, _ = s.repo.SuggestedSlots(target, routeID, instructorID, 5)
+	var route models.Route
+	if routeID != "" {
+		if r, err := s.routes.GetRoute(routeID); err == nil {
+			route = r
 		}
-		return resp, nil
 	}
-	apiData, err := s.fetch(lat, lng, targetHour)
-	if err != nil {
-		return WeatherResponse{}, err
+	targetHour := target.UTC().Truncate(time.Hour)
+	resp, ok := s.cached(lat, lng, targetHour, route)
+	if !ok {
+		f, err := s.fetch(context.Background(), lat, lng, targetHour, targetHour)
+		if err != nil {
+			return WeatherResponse{}, err
+		}
+		resp = s.score(lat, lng, targetHour, f, route)
 	}
-	score, level, explanation := scoreWeather(apiData.Temperature, apiData.WindSpeed, apiData.Precipitation, apiData.CloudCover)
-	snapshot := models.WeatherSnapshot{LocationLat: lat, LocationLng: lng, TimeFrom: targetHour, TimeTo: targetHour.Add(time.Hour), Temperature: apiData.Temperature, WindSpeed: apiData.WindSpeed, Precipitation: apiData.Precipitation, CloudCover: apiData.CloudCover, ConditionsLevel: level, Score: score, Raw: apiData.Raw, FetchedAt: time.Now().UTC()}
-	_ = s.repo.SaveWeatherSnapshot(&snapshot)
-	resp := WeatherResponse{Temperature: apiData.Temperature, WindSpeed: apiData.WindSpeed, Precipitation: apiData.Precipitation, CloudCover: apiData.CloudCover, ConditionsLevel: level, Explanation: explanation, Score: score, Raw: apiData.Raw}
-	if level == "Плохие" {
-		resp.SuggestedSlots, _ = s.repo.SuggestedSlots(target, routeID, instructorID, 5)
+	resp.AverageScore, resp.WorstHour = resp.Score, targetHour
+	if resp.ConditionsLevel == "Плохие" {
+		resp.SuggestedSlots, _ = s.slots.SuggestedSlots(target, routeID, instructorID, 5)
 	}
 	return resp, nil
 }
 
-type fetchedData struct {
-	Temperature, WindSpeed, Precipitation float64
-	CloudCover                            int
-	Raw                                   map[string]any
+// ForSlot scores every hour the slot covers at the route's start. The
+// top-level fields describe the worst hour, AverageScore the mean over
+// Hours; Unsafe is set if any hour is unsafe.
+func (s *WeatherService) ForSlot(route models.Route, slot models.TimeSlot) (WeatherResponse, error) {
+	return s.forSlot(context.Background(), route, slot)
 }
 
-func (s *WeatherService) fetch(lat, lng float64, targetHour time.Time) (fetchedData, error) {
-	u, _ := url.Parse(s.apiURL)
-	q := u.Query()
-	q.Set("latitude", fmt.Sprintf("%.5f", lat))
-	q.Set("longitude", fmt.Sprintf("%.5f", lng))
-	q.Set("hourly", "temperature_2m,wind_speed_10m,precipitation,cloud_cover")
-	q.Set("timezone", "UTC")
-	q.Set("start_date", targetHour.Format("2006-01-02"))
-	q.Set("end_date", targetHour.Format("2006-01-02"))
-	u.RawQuery = q.Encode()
-	resp, err := s.http.Get(u.String())
-	if err != nil {
-		return fetchedData{}, err
+// forSlot is ForSlot with the forecast fetch bounded by ctx; cached hours
+// need no fetch.
+func (s *WeatherService) forSlot(ctx context.Context, route models.Route, slot models.TimeSlot) (WeatherResponse, error) {
+	lat, lng := route.LocationLat, route.LocationLng
+	hours := []time.Time{}
+	for h := slot.StartAt.UTC().Truncate(time.Hour); h.Before(slot.EndAt) || len(hours) == 0; h = h.Add(time.Hour) {
+		hours = append(hours, h)
+	}
+	var f *Forecast
+	var worst WeatherResponse
+	total := 0
+	unsafe := ""
+	points := make([]HourlyWeather, 0, len(hours))
+	for i, h := range hours {
+		w, ok := s.cached(lat, lng, h, route)
+		if !ok {
+			if f == nil {
+				fetched, err := s.fetch(ctx, lat, lng, h, hours[len(hours)-1])
+				if err != nil {
+					return WeatherResponse{}, err
+				}
+				f = &fetched
+			}
+			w = s.score(lat, lng, h, *f, route)
+		}
+		if i == 0 || w.Score < worst.Score {
+			worst = w
+			worst.WorstHour = h
+		}
+		if w.Unsafe && unsafe == "" {
+			unsafe = w.SafetyNote
+		}
+		total += w.Score
+		points = append(points, HourlyWeather{Time: h, Temperature: w.Temperature, WindSpeed: w.WindSpeed, WindGusts: w.WindGusts, WindDirection: w.WindDirection, Precipitation: w.Precipitation, CloudCover: w.CloudCover, WaveHeight: w.WaveHeight, WavePeriod: w.WavePeriod, SeaTemperature: w.SeaTemperature, ConditionsLevel: w.ConditionsLevel, Score: w.Score, Offshore: w.Offshore, Unsafe: w.Unsafe})
+	}
+	worst.AverageScore = int(math.Round(float64(total) / float64(len(points))))
+	worst.Hours = points
+	worst.Raw = nil
+	if unsafe != "" {
+		worst.Unsafe, worst.SafetyNote = true, unsafe
+	}
+	if worst.ConditionsLevel == "Плохие" {
+		worst.SuggestedSlots, _ = s.slots.SuggestedSlots(slot.StartAt, slot.RouteID, slot.InstructorID, 5)
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode >= 400 {
-		return fetchedData{}, fmt.Errorf("weather api error: %s", string(body))
+	return worst, nil
+}
+
+// Guard returns ErrUnsafeConditions for a slot with an unsafe forecast when
+// blocking is enabled. It runs on the booking path, so it scores cached
+// hours and fetches the rest within guardBudget; a forecast that cannot be
+// loaded in time does not block.
+func (s *WeatherService) Guard(slot models.TimeSlot) error {
+	if !s.blockUnsafe {
+		return nil
 	}
-	var payload struct {
-		Hourly struct {
-			Time        []string  `json:"time"`
-			Temperature []float64 `json:"temperature_2m"`
-			Wind        []float64 `json:"wind_speed_10m"`
-			Precip      []float64 `json:"precipitation"`
-			Cloud       []int     `json:"cloud_cover"`
-		} `json:"hourly"`
+	route, err := s.routes.GetRoute(slot.RouteID)
+	if err != nil {
+		return err
 	}
-	if err := json.Unmarshal(body, &payload); err != nil {
-		return fetchedData{}, err
+	ctx, cancel := context.WithTimeout(context.Background(), s.guardBudget)
+	defer cancel()
+	w, err := s.forSlot(ctx, route, slot)
+	if err != nil {
+		log.Printf("weather guard for slot %s, not blocking: %v", slot.ID, err)
+		return nil
 	}
-	if len(payload.Hourly.Time) == 0 {
-		return fetchedData{}, fmt.Errorf("empty weather payload")
+	if w.Unsafe {
+		return fmt.Errorf("%w: %s", ErrUnsafeConditions, w.SafetyNote)
 	}
+	return nil
+}
+
+// cached rescores a cached hour, so the same snapshot serves routes with
+// different shore bearings and difficulties.
+func (s *WeatherService) cached(lat, lng float64, hour time.Time, route models.Route) (WeatherResponse, bool) {
+	snap, err := s.cache.FindWeatherSnapshot(lat, lng, hour, s.cacheTTL)
+	if err != nil {
+		return WeatherResponse{}, false
+	}
+	d := ForecastHour{Temperature: snap.Temperature, WindSpeed: snap.WindSpeed, WindGusts: snap.WindGusts, WindDirection: snap.WindDirection, Precipitation: snap.Precipitation, CloudCover: snap.CloudCover, WaveHeight: snap.WaveHeight, WavePeriod: snap.WavePeriod, SeaTemperature: snap.SeaTemperature}
+	return weatherResponse(d, s.assess(d, route), snap.Provider, nil), true
+}
+
+// score rates the hour of f and caches it with that hour's raw data only.
+func (s *WeatherService) score(lat, lng float64, hour time.Time, f Forecast, route models.Route) WeatherResponse {
+	i := f.nearest(hour)
+	d, raw := f.Points[i], f.rawAt(i)
+	a := s.assess(d, route)
+	snapshot := models.WeatherSnapshot{LocationLat: lat, LocationLng: lng, TimeFrom: hour, TimeTo: hour.Add(time.Hour), Temperature: d.Temperature, WindSpeed: d.WindSpeed, WindGusts: d.WindGusts, WindDirection: d.WindDirection, Precipitation: d.Precipitation, CloudCover: d.CloudCover, WaveHeight: d.WaveHeight, WavePeriod: d.WavePeriod, SeaTemperature: d.SeaTemperature, ConditionsLevel: a.Level, Score: a.Score, Provider: f.Provider, Raw: raw, FetchedAt: time.Now().UTC()}
+	_ = s.cache.SaveWeatherSnapshot(&snapshot)
+	return weatherResponse(d, a, f.Provider, raw)
+}
+
+func (s *WeatherService) assess(d ForecastHour, route models.Route) assessment {
+	rules, _ := s.rules.For(route.Difficulty)
+	return scoreWeather(d, route.ShoreBearing, rules)
+}
+
+func weatherResponse(d ForecastHour, a assessment, provider string, raw map[string]any) WeatherResponse {
+	return WeatherResponse{Temperature: d.Temperature, WindSpeed: d.WindSpeed, WindGusts: d.WindGusts, WindDirection: d.WindDirection, Precipitation: d.Precipitation, CloudCover: d.CloudCover, WaveHeight: d.WaveHeight, WavePeriod: d.WavePeriod, SeaTemperature: d.SeaTemperature, ConditionsLevel: a.Level, Explanation: a.Explanation, Score: a.Score, Offshore: a.Offshore, Unsafe: a.Unsafe, SafetyNote: a.SafetyNote, Provider: provider, Raw: raw}
+}
+
+// ForecastHour is one hour of forecast: wind in m/s, direction in degrees
+// the wind blows from. Marine fields are nil when the marine source has no
+// data for the hour.
+type ForecastHour struct {
+	Temperature, WindSpeed, WindGusts, Precipitation float64
+	WindDirection, CloudCover                        int
+	WaveHeight, WavePeriod, SeaTemperature           *float64
+}
+
+// Forecast is the hourly series returned by a provider; Raw keeps the
+// original payload of each point, in the same order as Points.
+type Forecast struct {
+	Provider string
+	Times    []time.Time
+	Points   []ForecastHour
+	Raw      []map[string]any
+}
+
+// nearest returns the index of the sample nearest to hour.
+func (f Forecast) nearest(hour time.Time) int {
 	idx := 0
 	min := math.MaxFloat64
-	for i, t := range payload.Hourly.Time {
-		parsed, _ := time.Parse("2006-01-02T15:04", t)
-		d := math.Abs(parsed.Sub(targetHour).Hours())
+	for i, t := range f.Times {
+		d := math.Abs(t.Sub(hour).Hours())
 		if d < min {
 			min = d
 			idx = i
 		}
 	}
-	raw := map[string]any{}
-	_ = json.Unmarshal(body, &raw)
-	return fetchedData{Temperature: payload.Hourly.Temperature[idx], WindSpeed: payload.Hourly.Wind[idx], Precipitation: payload.Hourly.Precip[idx], CloudCover: payload.Hourly.Cloud[idx], Raw: raw}, nil
+	return idx
+}
+
+// rawAt returns the raw data of point i, or nil when the provider kept none.
+func (f Forecast) rawAt(i int) map[string]any {
+	if i < len(f.Raw) {
+		return f.Raw[i]
+	}
+	return nil
+}
+
+// fetch asks the providers in order and returns the first forecast; a
+// provider that fails or does not answer within the timeout hands over to
+// the next one until ctx is done.
+func (s *WeatherService) fetch(parent context.Context, lat, lng float64, from, to time.Time) (Forecast, error) {
+	errs := []error{}
+	for _, p := range s.providers {
+		if err := parent.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		ctx, cancel := context.WithTimeout(parent, s.timeout)
+		f, err := p.Forecast(ctx, lat, lng, from, to)
+		cancel()
+		if err != nil {
+			log.Printf("weather provider %s: %v", p.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+			continue
+		}
+		if len(f.Times) == 0 {
+			errs = append(errs, fmt.Errorf("%s: empty forecast", p.Name()))
+			continue
+		}
+		f.Provider = p.Name()
+		if s.marineURL != "" {
+			ctx, cancel := context.WithTimeout(parent, s.timeout)
+			if err := s.fetchMarine(ctx, &f, lat, lng, from, to); err != nil {
+				log.Printf("marine forecast for %.5f,%.5f: %v", lat, lng, err)
+			}
+			cancel()
+		}
+		return f, nil
+	}
+	if len(errs) == 0 {
+		return Forecast{}, errors.New("no weather providers configured")
+	}
+	return Forecast{}, errors.Join(errs...)
+}
+
+// assessment is the score of one hourly point. Unsafe hours are capped
+// into "Плохие" whatever the rest of the score.
+type assessment struct {
+	Score       int
+	Level       string
+	Explanation string
+	Offshore    bool
+	Unsafe      bool
+	SafetyNote  string
 }
 
-func mapSnapshot(s models.WeatherSnapshot) WeatherResponse {
-	return WeatherResponse{Temperature: s.Temperature, WindSpeed: s.WindSpeed, Precipitation: s.Precipitation, CloudCover: s.CloudCover, ConditionsLevel: s.ConditionsLevel, Score: s.Score, Explanation: explanationFor(s.WindSpeed, s.Precipitation, s.Temperature)}
+// isOffshore reports whether wind from direction blows from the shore out
+// to sea on a start facing shore: within sector degrees of the seaward
+// bearing.
+func isOffshore(direction int, shore *int, sector float64) bool {
+	if shore == nil {
+		return false
+	}
+	toward := (direction + 180) % 360
+	diff := (toward - *shore + 360) % 360
+	return float64(min(diff, 360-diff)) <= sector
 }
 
-func scoreWeather(temp, wind, precipitation float64, cloud int) (int, string, string) {
-	score := 100
-	if wind >= 8 {
-		score -= 45
-	} else if wind >= 6 {
-		score -= 25
-	} else if wind >= 4 {
-		score -= 10
+// scoreWeather rates one hour by rules. Waves and sea temperature count
+// only on sea routes, i.e. routes with a shore bearing.
+func scoreWeather(d ForecastHour, shore *int, rules ScoringRules) assessment {
+	temp, wind, precipitation := d.Temperature, d.WindSpeed, d.Precipitation
+	sea := shore != nil
+	a := assessment{Offshore: wind >= rules.OffshoreMinWind && isOffshore(d.WindDirection, shore, rules.OffshoreSector)}
+	score := 100 - penaltyAbove(rules.Wind, wind)
+	if d.WindGusts >= rules.GustsStrong {
+		score -= rules.GustsPenalty
+	}
+	if a.Offshore {
+		score -= rules.OffshorePenalty
 	}
 	if precipitation > 0 {
-		score -= 20
+		score -= rules.RainPenalty
 	} else {
-		score += 5
+		score += rules.DryBonus
 	}
-	if temp < 12 || temp > 32 {
-		score -= 20
-	} else if temp < 16 || temp > 28 {
-		score -= 10
+	if temp < rules.TolerableTemp[0] || temp > rules.TolerableTemp[1] {
+		score -= rules.HarshPenalty
+	} else if temp < rules.ComfortTemp[0] || temp > rules.ComfortTemp[1] {
+		score -= rules.MildPenalty
 	} else {
-		score += 5
+		score += rules.ComfortBonus
+	}
+	if d.CloudCover > rules.CloudLimit {
+		score -= rules.CloudPenalty
+	}
+	if sea && d.WaveHeight != nil {
+		score -= penaltyAbove(rules.Waves, *d.WaveHeight)
+		// short-period waves of that size are steep chop
+		if d.WavePeriod != nil && *d.WavePeriod < rules.ChopPeriod && *d.WaveHeight >= rules.ChopHeight {
+			score -= rules.ChopPenalty
+		}
 	}
-	if cloud > 90 {
-		score -= 5
+	if sea && d.SeaTemperature != nil {
+		score -= penaltyBelow(rules.SeaCold, *d.SeaTemperature)
 	}
-	if score > 100 {
-		score = 100
+	switch {
+	case sea && d.WaveHeight != nil && *d.WaveHeight >= rules.WavesUnsafe:
+		a.Unsafe, a.SafetyNote = true, fmt.Sprintf("Волны до %.1f м — выход на воду опасен.", *d.WaveHeight)
+	case a.Offshore && wind >= rules.OffshoreUnsafe:
+		a.Unsafe, a.SafetyNote = true, fmt.Sprintf("Отжимной ветер %.1f м/с уносит от берега — выход на воду опасен.", wind)
+	case d.WindGusts >= rules.GustsUnsafe:
+		a.Unsafe, a.SafetyNote = true, fmt.Sprintf("Порывы ветра до %.1f м/с — выход на воду опасен.", d.WindGusts)
 	}
-	if score < 0 {
-		score = 0
+	if a.Unsafe {
+		score = min(score, rules.UnsafeCap)
 	}
-	level := "Плохие"
-	if score >= 80 {
-		level = "Отличные"
-	} else if score >= 60 {
-		level = "Хорошие"
-	} else if score >= 40 {
-		level = "Нормальные"
+	a.Score = max(0, min(score, 100))
+	a.Level = "Плохие"
+	if a.Score >= rules.Levels[0] {
+		a.Level = "Отличные"
+	} else if a.Score >= rules.Levels[1] {
+		a.Level = "Хорошие"
+	} else if a.Score >= rules.Levels[2] {
+		a.Level = "Нормальные"
 	}
-	return score, level, explanationFor(wind, precipitation, temp)
+	switch {
+	case a.Unsafe:
+		a.Explanation = a.SafetyNote
+	case a.Offshore:
+		a.Explanation = fmt.Sprintf("Ветер %.1f м/с дует от берега → держитесь ближе к берегу.", wind)
+	case d.WindGusts >= rules.GustsStrong && wind < rules.WindStrong:
+		a.Explanation = fmt.Sprintf("Порывы до %.1f м/с → будет сложнее держать равновесие.", d.WindGusts)
+	default:
+		a.Explanation = explanationFor(d, sea, rules)
+	}
+	return a
 }
 
-func explanationFor(wind, precipitation, temp float64) string {
-	if wind >= 8 {
+// explanationFor gives the main reason behind the score. On the sea the
+// wetsuit advice follows the water temperature, not the air.
+func explanationFor(d ForecastHour, sea bool, rules ScoringRules) string {
+	wind, precipitation, temp := d.WindSpeed, d.Precipitation, d.Temperature
+	if wind >= rules.WindStrong {
 		return fmt.Sprintf("Ветер %.1f м/с → будет сложнее грести.", wind)
 	}
+	if sea && d.WaveHeight != nil && *d.WaveHeight >= rules.WavesHigh {
+		return fmt.Sprintf("Волны %.1f м → нужен опыт, держитесь ближе к берегу.", *d.WaveHeight)
+	}
 	if precipitation > 0 {
 		return "Есть осадки → возможен дискомфорт на маршруте."
 	}
-	if temp < 12 {
+	if sea && d.SeaTemperature != nil {
+		if water := *d.SeaTemperature; water < rules.WaterWetsuit {
+			return fmt.Sprintf("Вода %.0f°C → нужен гидрокостюм.", water)
+		} else if water < rules.WaterCool {
+			return fmt.Sprintf("Вода %.0f°C → при падении будет холодно, возьмите гидрокостюм.", water)
+		}
+	} else if temp < rules.TolerableTemp[0] {
 		return "Прохладно, рекомендуется гидрокостюм."
 	}
-	if temp > 32 {
+	if temp > rules.TolerableTemp[1] {
 		return "Жарко, обязательно вода и головной убор."
 	}
 	return "Условия комфортные для прогулки."
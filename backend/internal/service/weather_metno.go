@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	MetNorwayName = "met-norway"
+	metNorwayURL  = "https://api.met.no/weatherapi/locationforecast/2.0/complete"
+)
+
+// MetNorwayProvider reads the MET Norway locationforecast API. It has no
+// sea data and gives 6-hour steps past the first days; such a step is
+// copied into each of its hours with the precipitation spread evenly.
+type MetNorwayProvider struct {
+	url  string
+	http *http.Client
+}
+
+func NewMetNorwayProvider(apiURL string) *MetNorwayProvider {
+	if apiURL == "" {
+		apiURL = metNorwayURL
+	}
+	return &MetNorwayProvider{url: apiURL, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *MetNorwayProvider) Name() string { return MetNorwayName }
+
+func (p *MetNorwayProvider) Forecast(ctx context.Context, lat, lng float64, from, to time.Time) (Forecast, error) {
+	u, _ := url.Parse(p.url)
+	q := u.Query()
+	// the API asks for at most 4 decimals
+	q.Set("lat", fmt.Sprintf("%.4f", lat))
+	q.Set("lon", fmt.Sprintf("%.4f", lng))
+	u.RawQuery = q.Encode()
+	// MET Norway rejects requests without an identifying User-Agent.
+	body, err := getJSON(ctx, p.http, u.String(), http.Header{"User-Agent": {"sup-anapa-backend/1.0"}})
+	if err != nil {
+		return Forecast{}, err
+	}
+	type precip struct {
+		Details struct {
+			Amount float64 `json:"precipitation_amount"`
+		} `json:"details"`
+	}
+	var raw struct {
+		Properties struct {
+			Timeseries []map[string]any `json:"timeseries"`
+		} `json:"properties"`
+	}
+	_ = json.Unmarshal(body, &raw)
+	var payload struct {
+		Properties struct {
+			Timeseries []struct {
+				Time string `json:"time"`
+				Data struct {
+					Instant struct {
+						Details struct {
+							Temperature float64  `json:"air_temperature"`
+							Wind        float64  `json:"wind_speed"`
+							Gust        *float64 `json:"wind_speed_of_gust"`
+							Direction   float64  `json:"wind_from_direction"`
+							Cloud       float64  `json:"cloud_area_fraction"`
+						} `json:"details"`
+					} `json:"instant"`
+					Next1h *precip `json:"next_1_hours"`
+					Next6h *precip `json:"next_6_hours"`
+				} `json:"data"`
+			} `json:"timeseries"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Forecast{}, err
+	}
+	first := from.UTC().Truncate(24 * time.Hour)
+	last := to.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	f := Forecast{}
+	steps := payload.Properties.Timeseries
+	for i, step := range steps {
+		t, err := time.Parse(time.RFC3339, step.Time)
+		if err != nil || t.Before(first) || !t.Before(last) {
+			continue
+		}
+		hours := 1
+		d := step.Data.Instant.Details
+		h := ForecastHour{Temperature: d.Temperature, WindSpeed: d.Wind, WindGusts: d.Wind, WindDirection: int(math.Round(d.Direction)) % 360, CloudCover: int(math.Round(d.Cloud))}
+		if d.Gust != nil {
+			h.WindGusts = *d.Gust
+		}
+		if step.Data.Next1h != nil {
+			h.Precipitation = step.Data.Next1h.Details.Amount
+		} else if step.Data.Next6h != nil {
+			h.Precipitation = step.Data.Next6h.Details.Amount / 6
+			hours = 6
+		}
+		var stepRaw map[string]any
+		if i < len(raw.Properties.Timeseries) {
+			stepRaw = raw.Properties.Timeseries[i]
+		}
+		end := t.Add(time.Duration(hours) * time.Hour)
+		if i+1 < len(steps) {
+			if next, err := time.Parse(time.RFC3339, steps[i+1].Time); err == nil && next.Before(end) {
+				end = next
+			}
+		}
+		for at := t; at.Before(end) && at.Before(last); at = at.Add(time.Hour) {
+			f.Times = append(f.Times, at.UTC())
+			f.Points = append(f.Points, h)
+			f.Raw = append(f.Raw, stepRaw)
+		}
+	}
+	if len(f.Times) == 0 {
+		return Forecast{}, fmt.Errorf("no forecast for %s..%s", first.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+	return f, nil
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	OpenMeteoName = "open-meteo"
+	openMeteoURL  = "https://api.open-meteo.com/v1/forecast"
+)
+
+// OpenMeteoProvider reads the hourly Open-Meteo forecast API.
+type OpenMeteoProvider struct {
+	url  string
+	http *http.Client
+}
+
+func NewOpenMeteoProvider(apiURL string) *OpenMeteoProvider {
+	if apiURL == "" {
+		apiURL = openMeteoURL
+	}
+	return &OpenMeteoProvider{url: apiURL, http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *OpenMeteoProvider) Name() string { return OpenMeteoName }
+
+func (p *OpenMeteoProvider) Forecast(ctx context.Context, lat, lng float64, from, to time.Time) (Forecast, error) {
+	body, err := getJSON(ctx, p.http, openMeteoQuery(p.url, lat, lng, from, to, "temperature_2m,wind_speed_10m,wind_gusts_10m,wind_direction_10m,precipitation,cloud_cover", true), nil)
+	if err != nil {
+		return Forecast{}, err
+	}
+	var payload struct {
+		Hourly struct {
+			Time        []string  `json:"time"`
+			Temperature []float64 `json:"temperature_2m"`
+			Wind        []float64 `json:"wind_speed_10m"`
+			Gusts       []float64 `json:"wind_gusts_10m"`
+			Direction   []float64 `json:"wind_direction_10m"`
+			Precip      []float64 `json:"precipitation"`
+			Cloud       []int     `json:"cloud_cover"`
+		} `json:"hourly"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Forecast{}, err
+	}
+	hourly := payload.Hourly
+	n := len(hourly.Time)
+	if n == 0 {
+		return Forecast{}, fmt.Errorf("empty weather payload")
+	}
+	if len(hourly.Temperature) < n || len(hourly.Wind) < n || len(hourly.Gusts) < n || len(hourly.Direction) < n || len(hourly.Precip) < n || len(hourly.Cloud) < n {
+		return Forecast{}, fmt.Errorf("incomplete weather payload")
+	}
+	f := Forecast{Times: make([]time.Time, n), Points: make([]ForecastHour, n)}
+	for i, t := range hourly.Time {
+		f.Times[i], _ = time.Parse("2006-01-02T15:04", t)
+		f.Points[i] = ForecastHour{Temperature: hourly.Temperature[i], WindSpeed: hourly.Wind[i], WindGusts: hourly.Gusts[i], WindDirection: int(math.Round(hourly.Direction[i])) % 360, Precipitation: hourly.Precip[i], CloudCover: hourly.Cloud[i]}
+	}
+	f.Raw = hourlyRaw(body, n)
+	return f, nil
+}
+
+// fetchMarine adds waves and sea temperature (Open-Meteo marine API) to the
+// matching hours of f. Points on land come back as nulls and stay nil.
+func (s *WeatherService) fetchMarine(ctx context.Context, f *Forecast, lat, lng float64, from, to time.Time) error {
+	body, err := getJSON(ctx, s.http, openMeteoQuery(s.marineURL, lat, lng, from, to, "wave_height,wave_period,sea_surface_temperature", false), nil)
+	if err != nil {
+		return err
+	}
+	var payload struct {
+		Hourly struct {
+			Time    []string   `json:"time"`
+			Height  []*float64 `json:"wave_height"`
+			Period  []*float64 `json:"wave_period"`
+			SeaTemp []*float64 `json:"sea_surface_temperature"`
+		} `json:"hourly"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return err
+	}
+	hourly := payload.Hourly
+	n := len(hourly.Time)
+	if len(hourly.Height) < n || len(hourly.Period) < n || len(hourly.SeaTemp) < n {
+		return fmt.Errorf("incomplete marine payload")
+	}
+	index := map[int64]int{}
+	for i, t := range f.Times {
+		index[t.Unix()] = i
+	}
+	raw := hourlyRaw(body, n)
+	if len(f.Raw) < len(f.Points) {
+		f.Raw = append(f.Raw, make([]map[string]any, len(f.Points)-len(f.Raw))...)
+	}
+	for i, t := range hourly.Time {
+		parsed, _ := time.Parse("2006-01-02T15:04", t)
+		if j, ok := index[parsed.Unix()]; ok {
+			f.Points[j].WaveHeight, f.Points[j].WavePeriod, f.Points[j].SeaTemperature = hourly.Height[i], hourly.Period[i], hourly.SeaTemp[i]
+			if f.Raw[j] == nil {
+				f.Raw[j] = map[string]any{}
+			}
+			f.Raw[j]["marine"] = raw[i]
+		}
+	}
+	return nil
+}
+
+// hourlyRaw splits the "hourly" arrays of an Open-Meteo payload into n
+// per-hour objects keyed by variable.
+func hourlyRaw(body []byte, n int) []map[string]any {
+	var payload struct {
+		Hourly map[string][]any `json:"hourly"`
+	}
+	out := make([]map[string]any, n)
+	_ = json.Unmarshal(body, &payload)
+	for i := range out {
+		out[i] = map[string]any{}
+		for k, v := range payload.Hourly {
+			if i < len(v) {
+				out[i][k] = v[i]
+			}
+		}
+	}
+	return out
+}
+
+func openMeteoQuery(apiURL string, lat, lng float64, from, to time.Time, hourly string, msWind bool) string {
+	u, _ := url.Parse(apiURL)
+	q := u.Query()
+	q.Set("latitude", fmt.Sprintf("%.5f", lat))
+	q.Set("longitude", fmt.Sprintf("%.5f", lng))
+	q.Set("hourly", hourly)
+	if msWind {
+		q.Set("wind_speed_unit", "ms")
+	}
+	q.Set("timezone", "UTC")
+	q.Set("start_date", from.Format("2006-01-02"))
+	q.Set("end_date", to.Format("2006-01-02"))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// getJSON fetches u and returns the body of a successful response.
+func getJSON(ctx context.Context, client *http.Client, u string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("weather api error %d: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+// openMeteoServer serves /forecast with calm weather and /marine with
+// marine(hour) for every hour of the requested days; a nil marine answers
+// 500.
+func openMeteoServer(t *testing.T, marine func(hour int) []any) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, _ := time.Parse("2006-01-02", r.URL.Query().Get("start_date"))
+		to, _ := time.Parse("2006-01-02", r.URL.Query().Get("end_date"))
+		hourly := map[string][]any{}
+		add := func(k string, v any) { hourly[k] = append(hourly[k], v) }
+		for t := from; !t.After(to.Add(23 * time.Hour)); t = t.Add(time.Hour) {
+			add("time", t.Format("2006-01-02T15:04"))
+			if strings.HasSuffix(r.URL.Path, "/marine") {
+				if marine == nil {
+					http.Error(w, "marine unavailable", 500)
+					return
+				}
+				v := marine(t.Hour())
+				add("wave_height", v[0])
+				add("wave_period", v[1])
+				add("sea_surface_temperature", v[2])
+				continue
+			}
+			add("temperature_2m", 24.0)
+			add("wind_speed_10m", 2.0)
+			add("wind_gusts_10m", 4.0)
+			add("wind_direction_10m", 180.0)
+			add("precipitation", 0.0)
+			add("cloud_cover", 10)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"hourly": hourly})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMarineForecast(t *testing.T) {
+	srv := openMeteoServer(t, func(hour int) []any {
+		switch hour {
+		case 10:
+			return []any{1.6, 6.0, 19.0}
+		case 11:
+			return []any{nil, nil, nil} // on land
+		default:
+			return []any{0.2, 6.0, 15.0}
+		}
+	})
+	repo := repository.New()
+	weather := NewWeatherService(repo, repo, repo, []WeatherProvider{NewOpenMeteoProvider(srv.URL + "/forecast")}, srv.URL+"/marine", time.Second, time.Second, 20*time.Minute, true, WeatherRules{DefaultDifficulty: DefaultScoringRules})
+	south := 180
+	sea := models.Route{Title: "Море", DurationMinutes: 60, Difficulty: "easy", LocationLat: 44.9, LocationLng: 37.3, ShoreBearing: &south}
+	river := models.Route{Title: "Река", DurationMinutes: 60, Difficulty: "easy", LocationLat: 45.1, LocationLng: 37.3}
+	for _, r := range []*models.Route{&sea, &river} {
+		if err := repo.UpsertRoute(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := day(10)
+	at := func(route models.Route, hour int) WeatherResponse {
+		t.Helper()
+		w, err := weather.Get(route.LocationLat, route.LocationLng, d.Add(time.Duration(hour)*time.Hour), route.ID, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+
+	if w := at(sea, 9); w.WaveHeight == nil || *w.WaveHeight != 0.2 || !strings.Contains(w.Explanation, "гидрокостюм") {
+		t.Errorf("09:00 on the sea: waves %v, %q; want 0.2 m and wetsuit advice for 15 °C water", w.WaveHeight, w.Explanation)
+	}
+	if w := at(sea, 10); !w.Unsafe || !strings.Contains(w.SafetyNote, "Волны до 1.6") {
+		t.Errorf("10:00 on the sea: unsafe %v, %q; want unsafe for 1.6 m waves", w.Unsafe, w.SafetyNote)
+	}
+	if w := at(sea, 11); w.WaveHeight != nil || w.SeaTemperature != nil || w.Unsafe {
+		t.Errorf("11:00 on the sea: waves %v, water %v; want no marine data", w.WaveHeight, w.SeaTemperature)
+	}
+	if w := at(river, 10); w.Unsafe || strings.Contains(w.Explanation, "Вода") {
+		t.Errorf("10:00 on the river: unsafe %v, %q; want marine data ignored", w.Unsafe, w.Explanation)
+	}
+}
+
+func TestMarineOutageKeepsForecast(t *testing.T) {
+	srv := openMeteoServer(t, nil)
+	repo := repository.New()
+	weather := NewWeatherService(repo, repo, repo, []WeatherProvider{NewOpenMeteoProvider(srv.URL + "/forecast")}, srv.URL+"/marine", time.Second, time.Second, 20*time.Minute, true, WeatherRules{DefaultDifficulty: DefaultScoringRules})
+	w, err := weather.Get(45.092, 37.268, day(10).Add(9*time.Hour), "", "")
+	if err != nil {
+		t.Fatalf("marine outage: %v, want the land forecast", err)
+	}
+	if w.WindSpeed != 2 || w.WaveHeight != nil {
+		t.Errorf("wind %.0f, waves %v; want 2 m/s and no waves", w.WindSpeed, w.WaveHeight)
+	}
+}
+
+// metServer serves a MET Norway locationforecast for the day d: an hourly
+// step at 09:00 and a 6-hour step at 12:00, plus one the day before.
+func metServer(t *testing.T, d time.Time) *httptest.Server {
+	t.Helper()
+	step := func(at time.Time, details map[string]any, next string, amount float64) map[string]any {
+		return map[string]any{"time": at.Format(time.RFC3339), "data": map[string]any{
+			"instant": map[string]any{"details": details},
+			next:      map[string]any{"details": map[string]any{"precipitation_amount": amount}},
+		}}
+	}
+	body, _ := json.Marshal(map[string]any{"properties": map[string]any{"timeseries": []any{
+		step(d.Add(-time.Hour), map[string]any{"air_temperature": 10.0, "wind_speed": 1.0}, "next_1_hours", 0.0),
+		step(d.Add(9*time.Hour), map[string]any{"air_temperature": 21.5, "wind_speed": 4.0, "wind_speed_of_gust": 9.0, "wind_from_direction": 359.6, "cloud_area_fraction": 37.4}, "next_1_hours", 0.6),
+		step(d.Add(12*time.Hour), map[string]any{"air_temperature": 23.0, "wind_speed": 5.0, "wind_from_direction": 90.0}, "next_6_hours", 3.0),
+	}}})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == "" || r.URL.Query().Get("lat") != "45.0920" {
+			http.Error(w, "bad request", 403)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMetNorwayForecast(t *testing.T) {
+	d := day(10)
+	srv := metServer(t, d)
+	f, err := NewMetNorwayProvider(srv.URL).Forecast(context.Background(), 45.092, 37.268, d.Add(9*time.Hour), d.Add(10*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The 6-hour step fills 12:00 to 17:00.
+	if len(f.Times) != 7 || !f.Times[0].Equal(d.Add(9*time.Hour)) || !f.Times[1].Equal(d.Add(12*time.Hour)) || !f.Times[6].Equal(d.Add(17*time.Hour)) || len(f.Raw) != 7 {
+		t.Fatalf("times = %v (%d raw), want 09:00 and 12:00–17:00 of the day only", f.Times, len(f.Raw))
+	}
+	hourly, sixHour := f.Points[0], f.Points[1]
+	if f.Points[6] != sixHour {
+		t.Errorf("17:00 = %+v, want the 12:00 step copied", f.Points[6])
+	}
+	if hourly.WindGusts != 9 || hourly.WindDirection != 0 || hourly.CloudCover != 37 || hourly.Precipitation != 0.6 {
+		t.Errorf("09:00 = %+v, want gusts 9, direction 0, cloud 37, precipitation 0.6", hourly)
+	}
+	if sixHour.WindGusts != 5 || sixHour.Precipitation != 0.5 {
+		t.Errorf("12:00 = %+v, want gusts falling back to the wind and 3 mm spread over 6 hours", sixHour)
+	}
+	if f.Raw[0]["time"] != d.Add(9*time.Hour).Format(time.RFC3339) {
+		t.Errorf("raw[0] = %v, want the 09:00 step", f.Raw[0])
+	}
+}
+
+func TestWeatherProviderFallback(t *testing.T) {
+	d := day(10)
+	met := metServer(t, d)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { http.Error(w, "down", 503) }))
+	defer down.Close()
+	slow := &stubWeather{hour: func(time.Time) ForecastHour { return storm }, delay: 5 * time.Second}
+	tests := []struct {
+		name      string
+		providers []WeatherProvider
+		want      string
+	}{
+		{"first answers", []WeatherProvider{NewMetNorwayProvider(met.URL), NewOpenMeteoProvider(down.URL)}, MetNorwayName},
+		{"first fails", []WeatherProvider{NewOpenMeteoProvider(down.URL), NewMetNorwayProvider(met.URL)}, MetNorwayName},
+		{"first too slow", []WeatherProvider{slow, NewMetNorwayProvider(met.URL)}, MetNorwayName},
+		{"all fail", []WeatherProvider{NewOpenMeteoProvider(down.URL)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.New()
+			weather := NewWeatherService(repo, repo, repo, tt.providers, "", 200*time.Millisecond, time.Second, 20*time.Minute, true, WeatherRules{DefaultDifficulty: DefaultScoringRules})
+			w, err := weather.Get(45.092, 37.268, d.Add(9*time.Hour), "", "")
+			if tt.want == "" {
+				if err == nil || !strings.Contains(err.Error(), "503") {
+					t.Errorf("err = %v, want the provider error", err)
+				}
+				return
+			}
+			if err != nil || w.Provider != tt.want || w.WindSpeed != 4 {
+				t.Errorf("provider %q, wind %.0f, err %v; want %s with 4 m/s", w.Provider, w.WindSpeed, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultDifficulty is the rule set key used for routes whose difficulty has
+// no rules of its own.
+const DefaultDifficulty = "default"
+
+var ErrInvalidPreview = errors.New("invalid weather preview")
+
+// Band takes Penalty points off once a value reaches Limit. Of several
+// bands the value reaches, the largest penalty counts.
+type Band struct {
+	Limit   float64 `json:"limit"`
+	Penalty int     `json:"penalty"`
+}
+
+// ScoringRules are the thresholds (m/s, °C, m, s, degrees) and penalties of
+// one hour's score, which starts at 100. Temperatures outside ComfortTemp
+// cost MildPenalty, outside TolerableTemp HarshPenalty; inside ComfortTemp
+// they add ComfortBonus. SeaCold bands apply when the water is below Limit.
+// Wind of at least OffshoreMinWind blowing within OffshoreSector of the
+// seaward bearing is offshore. An unsafe hour scores at most UnsafeCap.
+// Levels are the lowest scores of "Отличные", "Хорошие" and "Нормальные".
+// WindStrong, WavesHigh, WaterWetsuit and WaterCool only pick the
+// explanation.
+type ScoringRules struct {
+	Wind            []Band     `json:"wind"`
+	WindStrong      float64    `json:"wind_strong"`
+	GustsStrong     float64    `json:"gusts_strong"`
+	GustsPenalty    int        `json:"gusts_penalty"`
+	GustsUnsafe     float64    `json:"gusts_unsafe"`
+	OffshorePenalty int        `json:"offshore_penalty"`
+	OffshoreUnsafe  float64    `json:"offshore_unsafe"`
+	OffshoreMinWind float64    `json:"offshore_min_wind"`
+	OffshoreSector  float64    `json:"offshore_sector"`
+	RainPenalty     int        `json:"rain_penalty"`
+	DryBonus        int        `json:"dry_bonus"`
+	ComfortTemp     [2]float64 `json:"comfort_temp"`
+	TolerableTemp   [2]float64 `json:"tolerable_temp"`
+	ComfortBonus    int        `json:"comfort_bonus"`
+	MildPenalty     int        `json:"mild_penalty"`
+	HarshPenalty    int        `json:"harsh_penalty"`
+	CloudLimit      int        `json:"cloud_limit"`
+	CloudPenalty    int        `json:"cloud_penalty"`
+	Waves           []Band     `json:"waves"`
+	WavesHigh       float64    `json:"waves_high"`
+	WavesUnsafe     float64    `json:"waves_unsafe"`
+	ChopHeight      float64    `json:"chop_height"`
+	ChopPeriod      float64    `json:"chop_period"`
+	ChopPenalty     int        `json:"chop_penalty"`
+	SeaCold         []Band     `json:"sea_cold"`
+	WaterWetsuit    float64    `json:"water_wetsuit"`
+	WaterCool       float64    `json:"water_cool"`
+	UnsafeCap       int        `json:"unsafe_cap"`
+	Levels          [3]int     `json:"levels"`
+}
+
+// WeatherRules maps a route difficulty to its scoring rules; the
+// DefaultDifficulty entry is always present.
+type WeatherRules map[string]ScoringRules
+
+// DefaultScoringRules are the built-in rules, used as is when WEATHER_RULES
+// is empty and as the base every configured rule set overrides.
+var DefaultScoringRules = ScoringRules{
+	Wind:            []Band{{Limit: 4, Penalty: 10}, {Limit: 6, Penalty: 25}, {Limit: 8, Penalty: 45}},
+	WindStrong:      8,
+	GustsStrong:     10,
+	GustsPenalty:    15,
+	GustsUnsafe:     14,
+	OffshorePenalty: 20,
+	OffshoreUnsafe:  5,
+	OffshoreMinWind: 1,
+	OffshoreSector:  60,
+	RainPenalty:     20,
+	DryBonus:        5,
+	ComfortTemp:     [2]float64{16, 28},
+	TolerableTemp:   [2]float64{12, 32},
+	ComfortBonus:    5,
+	MildPenalty:     10,
+	HarshPenalty:    20,
+	CloudLimit:      90,
+	CloudPenalty:    5,
+	Waves:           []Band{{Limit: 0.3, Penalty: 5}, {Limit: 0.6, Penalty: 15}, {Limit: 1, Penalty: 30}},
+	WavesHigh:       0.6,
+	WavesUnsafe:     1.5,
+	ChopHeight:      0.5,
+	ChopPeriod:      4,
+	ChopPenalty:     10,
+	SeaCold:         []Band{{Limit: 18, Penalty: 5}, {Limit: 14, Penalty: 15}},
+	WaterWetsuit:    16,
+	WaterCool:       20,
+	UnsafeCap:       39,
+	Levels:          [3]int{80, 60, 40},
+}
+
+// ParseWeatherRulesYAML is ParseWeatherRules for the same object written
+// as YAML, as in a WEATHER_RULES_FILE ending in .yaml or .yml.
+func ParseWeatherRulesYAML(raw []byte) (WeatherRules, error) {
+	var doc any
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("WEATHER_RULES: %w", err)
+	}
+	if doc == nil {
+		return ParseWeatherRules(nil)
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("WEATHER_RULES: %w", err)
+	}
+	return ParseWeatherRules(b)
+}
+
+// ParseWeatherRules reads WEATHER_RULES: a JSON object keyed by route
+// difficulty. Each entry overrides only the fields it sets, on top of the
+// "default" entry, which itself overrides DefaultScoringRules.
+func ParseWeatherRules(raw []byte) (WeatherRules, error) {
+	rules := WeatherRules{DefaultDifficulty: DefaultScoringRules.clone()}
+	if len(raw) == 0 {
+		return rules, nil
+	}
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("WEATHER_RULES: %w", err)
+	}
+	keys := []string{}
+	for k := range entries {
+		if k == "" {
+			return nil, fmt.Errorf("WEATHER_RULES: empty difficulty")
+		}
+		if k != DefaultDifficulty {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if _, ok := entries[DefaultDifficulty]; ok {
+		keys = append([]string{DefaultDifficulty}, keys...)
+	}
+	for _, k := range keys {
+		r := rules[DefaultDifficulty].clone()
+		dec := json.NewDecoder(bytes.NewReader(entries[k]))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&r); err != nil {
+			return nil, fmt.Errorf("WEATHER_RULES %s: %w", k, err)
+		}
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("WEATHER_RULES %s: %w", k, err)
+		}
+		rules[k] = r
+	}
+	return rules, nil
+}
+
+// For returns the rules of a difficulty and the key they were found under.
+func (w WeatherRules) For(difficulty string) (ScoringRules, string) {
+	if r, ok := w[difficulty]; ok {
+		return r, difficulty
+	}
+	return w[DefaultDifficulty], DefaultDifficulty
+}
+
+// clone copies r so decoding into it cannot touch the bands of r.
+func (r ScoringRules) clone() ScoringRules {
+	r.Wind = append([]Band(nil), r.Wind...)
+	r.Waves = append([]Band(nil), r.Waves...)
+	r.SeaCold = append([]Band(nil), r.SeaCold...)
+	return r
+}
+
+func (r ScoringRules) validate() error {
+	penalties := map[string]int{"gusts_penalty": r.GustsPenalty, "offshore_penalty": r.OffshorePenalty, "rain_penalty": r.RainPenalty, "dry_bonus": r.DryBonus, "comfort_bonus": r.ComfortBonus, "mild_penalty": r.MildPenalty, "harsh_penalty": r.HarshPenalty, "cloud_penalty": r.CloudPenalty, "chop_penalty": r.ChopPenalty}
+	for name, p := range penalties {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("%s must be between 0 and 100", name)
+		}
+	}
+	limits := map[string]float64{"offshore_sector": r.OffshoreSector, "wind_strong": r.WindStrong, "gusts_strong": r.GustsStrong, "gusts_unsafe": r.GustsUnsafe, "offshore_unsafe": r.OffshoreUnsafe, "waves_high": r.WavesHigh, "waves_unsafe": r.WavesUnsafe, "chop_height": r.ChopHeight, "chop_period": r.ChopPeriod}
+	for name, v := range limits {
+		if math.IsNaN(v) || math.IsInf(v, 0) || v <= 0 {
+			return fmt.Errorf("%s must be a positive number", name)
+		}
+	}
+	bands := map[string][]Band{"wind": r.Wind, "waves": r.Waves, "sea_cold": r.SeaCold}
+	for name, list := range bands {
+		for _, b := range list {
+			if math.IsNaN(b.Limit) || math.IsInf(b.Limit, 0) || b.Penalty < 0 || b.Penalty > 100 {
+				return fmt.Errorf("%s: limit must be a number and penalty between 0 and 100", name)
+			}
+		}
+	}
+	switch {
+	case len(r.Wind) == 0:
+		return fmt.Errorf("wind needs at least one band")
+	case r.GustsStrong > r.GustsUnsafe:
+		return fmt.Errorf("gusts_strong must not exceed gusts_unsafe")
+	case r.WavesHigh > r.WavesUnsafe:
+		return fmt.Errorf("waves_high must not exceed waves_unsafe")
+	case r.ComfortTemp[0] > r.ComfortTemp[1]:
+		return fmt.Errorf("comfort_temp must be [min, max]")
+	case r.TolerableTemp[0] > r.ComfortTemp[0] || r.TolerableTemp[1] < r.ComfortTemp[1]:
+		return fmt.Errorf("tolerable_temp must contain comfort_temp")
+	case r.CloudLimit < 0 || r.CloudLimit > 100:
+		return fmt.Errorf("cloud_limit must be between 0 and 100")
+	case r.OffshoreSector > 180:
+		return fmt.Errorf("offshore_sector must not exceed 180")
+	case math.IsNaN(r.OffshoreMinWind) || math.IsInf(r.OffshoreMinWind, 0) || r.OffshoreMinWind < 0:
+		return fmt.Errorf("offshore_min_wind must be a non-negative number")
+	case math.IsNaN(r.WaterWetsuit) || math.IsNaN(r.WaterCool) || math.IsInf(r.WaterWetsuit, 0) || math.IsInf(r.WaterCool, 0) || r.WaterWetsuit > r.WaterCool:
+		return fmt.Errorf("water_wetsuit and water_cool must be numbers, water_wetsuit not above water_cool")
+	case r.Levels[0] > 100 || r.Levels[0] < r.Levels[1] || r.Levels[1] < r.Levels[2] || r.Levels[2] < 1:
+		return fmt.Errorf("levels must be three scores from 100 down to 1")
+	case r.UnsafeCap < 0 || r.UnsafeCap >= r.Levels[2]:
+		return fmt.Errorf("unsafe_cap must be at least 0 and below the lowest level, so unsafe hours rate \"Плохие\"")
+	}
+	return nil
+}
+
+// penaltyAbove is the largest penalty of the bands v reaches.
+func penaltyAbove(bands []Band, v float64) int {
+	p := 0
+	for _, b := range bands {
+		if v >= b.Limit {
+			p = max(p, b.Penalty)
+		}
+	}
+	return p
+}
+
+// penaltyBelow is the largest penalty of the bands v is under.
+func penaltyBelow(bands []Band, v float64) int {
+	p := 0
+	for _, b := range bands {
+		if v < b.Limit {
+			p = max(p, b.Penalty)
+		}
+	}
+	return p
+}
+
+// WeatherSample is one hour of conditions to preview a score for.
+type WeatherSample struct {
+	Temperature    float64  `json:"temperature"`
+	WindSpeed      float64  `json:"wind_speed"`
+	WindGusts      float64  `json:"wind_gusts"`
+	WindDirection  int      `json:"wind_direction"`
+	Precipitation  float64  `json:"precipitation"`
+	CloudCover     int      `json:"cloud_cover"`
+	WaveHeight     *float64 `json:"wave_height,omitempty"`
+	WavePeriod     *float64 `json:"wave_period,omitempty"`
+	SeaTemperature *float64 `json:"sea_temperature,omitempty"`
+}
+
+// ScorePreview is a sample with the score it would get.
+type ScorePreview struct {
+	WeatherSample
+	ConditionsLevel string `json:"conditions_level"`
+	Score           int    `json:"score"`
+	Explanation     string `json:"explanation"`
+	Offshore        bool   `json:"offshore"`
+	Unsafe          bool   `json:"unsafe"`
+	SafetyNote      string `json:"safety_note,omitempty"`
+}
+
+// Rules returns the loaded rule set.
+func (s *WeatherService) Rules() WeatherRules { return s.rules }
+
+// Preview scores samples with the rules of difficulty, as if on a route
+// with shore bearing shore. Candidate rules, when not nil, are used instead
+// of the loaded ones so a change can be tried before it is deployed. It
+// returns the rule set key that was used.
+func (s *WeatherService) Preview(difficulty string, shore *int, samples []WeatherSample, candidate WeatherRules) ([]ScorePreview, string, error) {
+	if len(samples) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one sample is required", ErrInvalidPreview)
+	}
+	if shore != nil && (*shore < 0 || *shore > 359) {
+		return nil, "", fmt.Errorf("%w: shore_bearing must be between 0 and 359", ErrInvalidPreview)
+	}
+	if candidate == nil {
+		candidate = s.rules
+	}
+	rules, key := candidate.For(difficulty)
+	out := make([]ScorePreview, 0, len(samples))
+	for _, in := range samples {
+		d := ForecastHour{Temperature: in.Temperature, WindSpeed: in.WindSpeed, WindGusts: max(in.WindGusts, in.WindSpeed), WindDirection: (in.WindDirection%360 + 360) % 360, Precipitation: in.Precipitation, CloudCover: in.CloudCover, WaveHeight: in.WaveHeight, WavePeriod: in.WavePeriod, SeaTemperature: in.SeaTemperature}
+		a := scoreWeather(d, shore, rules)
+		out = append(out, ScorePreview{WeatherSample: in, ConditionsLevel: a.Level, Score: a.Score, Explanation: a.Explanation, Offshore: a.Offshore, Unsafe: a.Unsafe, SafetyNote: a.SafetyNote})
+	}
+	return out, key, nil
+}
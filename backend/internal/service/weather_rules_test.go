@@ -0,0 +1,137 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"sup-anapa/backend/internal/repository"
+)
+
+func TestParseWeatherRules(t *testing.T) {
+	rules, err := ParseWeatherRules(nil)
+	if err != nil || len(rules) != 1 || !reflect.DeepEqual(rules[DefaultDifficulty], DefaultScoringRules) {
+		t.Fatalf("empty WEATHER_RULES = %v, %v; want the built-in rules", rules, err)
+	}
+
+	rules, err = ParseWeatherRules([]byte(`{
+		"hard": {"levels": [90, 70, 50], "offshore_sector": 45, "wind": [{"limit": 3, "penalty": 30}]},
+		"default": {"gusts_unsafe": 12, "unsafe_cap": 30}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, key := rules.For("easy")
+	if key != DefaultDifficulty || def.GustsUnsafe != 12 || def.UnsafeCap != 30 || def.Levels != DefaultScoringRules.Levels {
+		t.Errorf("rules for easy = %s %+v, want the default entry over the built-in rules", key, def)
+	}
+	hard, key := rules.For("hard")
+	if key != "hard" || hard.GustsUnsafe != 12 || hard.OffshoreSector != 45 || hard.Levels != [3]int{90, 70, 50} || len(hard.Wind) != 1 {
+		t.Errorf("rules for hard = %s %+v, want the hard entry over the default entry", key, hard)
+	}
+	if len(DefaultScoringRules.Wind) != 3 || DefaultScoringRules.Wind[0].Limit != 4 {
+		t.Errorf("built-in wind bands changed to %v", DefaultScoringRules.Wind)
+	}
+
+	// At 30 °C and 2 m/s both rule sets give 95; the hard wind band from 3 m/s
+	// costs 30 and the hard levels rate the rest "Нормальные".
+	hour := ForecastHour{Temperature: 30, WindSpeed: 2, CloudCover: 10}
+	if a := scoreWeather(hour, nil, def); a.Score != 95 || a.Level != "Отличные" {
+		t.Errorf("default rules: %d %s, want 95 Отличные", a.Score, a.Level)
+	}
+	if a := scoreWeather(hour, nil, hard); a.Score != 95 || a.Level != "Отличные" {
+		t.Errorf("hard rules: %d %s, want 95 Отличные", a.Score, a.Level)
+	}
+	hour.WindSpeed = 3
+	if a := scoreWeather(hour, nil, hard); a.Score != 65 || a.Level != "Нормальные" {
+		t.Errorf("hard rules at 3 m/s: %d %s, want 65 Нормальные", a.Score, a.Level)
+	}
+}
+
+func TestParseWeatherRulesRejects(t *testing.T) {
+	for _, raw := range []string{
+		`[]`,
+		`{"": {}}`,
+		`{"default": {"wind_speed_max": 5}}`,
+		`{"default": {"wind": []}}`,
+		`{"default": {"rain_penalty": 101}}`,
+		`{"default": {"gusts_strong": 15, "gusts_unsafe": 12}}`,
+		`{"default": {"offshore_sector": 200}}`,
+		`{"default": {"offshore_sector": 0}}`,
+		`{"default": {"offshore_min_wind": -1}}`,
+		`{"default": {"water_wetsuit": 22, "water_cool": 20}}`,
+		`{"default": {"levels": [60, 80, 40]}}`,
+		`{"default": {"levels": [80, 60, 0]}}`,
+		`{"default": {"unsafe_cap": 40}}`,
+		`{"hard": {"comfort_temp": [10, 35]}}`,
+	} {
+		if _, err := ParseWeatherRules([]byte(raw)); err == nil {
+			t.Errorf("ParseWeatherRules(%s): want an error", raw)
+		}
+	}
+}
+
+func TestParseWeatherRulesYAML(t *testing.T) {
+	fromYAML, err := ParseWeatherRulesYAML([]byte(`
+default:
+  gusts_unsafe: 12
+hard:
+  levels: [90, 70, 50]
+  wind:
+    - {limit: 3, penalty: 30}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := ParseWeatherRules([]byte(`{"default": {"gusts_unsafe": 12}, "hard": {"levels": [90, 70, 50], "wind": [{"limit": 3, "penalty": 30}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Errorf("YAML rules = %+v, want the same as JSON %+v", fromYAML, fromJSON)
+	}
+	if rules, err := ParseWeatherRulesYAML(nil); err != nil || !reflect.DeepEqual(rules[DefaultDifficulty], DefaultScoringRules) {
+		t.Errorf("empty YAML = %v, %v; want the built-in rules", rules, err)
+	}
+	if _, err := ParseWeatherRulesYAML([]byte("default:\n  wind_speed_max: 5\n")); err == nil {
+		t.Error("unknown YAML field: want an error")
+	}
+}
+
+func TestPreviewUsesDifficultyRules(t *testing.T) {
+	rules, err := ParseWeatherRules([]byte(`{"hard": {"gusts_unsafe": 10, "gusts_strong": 8, "unsafe_cap": 20}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := repository.New()
+	weather := NewWeatherService(repo, repo, repo, nil, "", 0, 0, 0, true, rules)
+	sample := []WeatherSample{{Temperature: 24, WindSpeed: 4, WindGusts: 11}}
+
+	easy, key, err := weather.Preview("easy", nil, sample, nil)
+	if err != nil || key != DefaultDifficulty || easy[0].Unsafe {
+		t.Errorf("easy: key %s, unsafe %v, err %v; want the default rules and a safe hour", key, easy[0].Unsafe, err)
+	}
+	hard, key, err := weather.Preview("hard", nil, sample, nil)
+	if err != nil || key != "hard" || !hard[0].Unsafe || hard[0].Score > 20 {
+		t.Errorf("hard: key %s, unsafe %v, score %d, err %v; want unsafe and capped at 20", key, hard[0].Unsafe, hard[0].Score, err)
+	}
+	candidate, err := ParseWeatherRules([]byte(`{"hard": {"gusts_unsafe": 15}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tried, key, err := weather.Preview("hard", nil, sample, candidate); err != nil || key != "hard" || tried[0].Unsafe {
+		t.Errorf("candidate hard rules: key %s, unsafe %v, err %v; want a safe hour under the candidate", key, tried[0].Unsafe, err)
+	}
+	if again, _, _ := weather.Preview("hard", nil, sample, nil); !again[0].Unsafe {
+		t.Error("a candidate preview changed the loaded rules")
+	}
+	bad := 360
+	for _, tt := range []struct {
+		shore   *int
+		samples []WeatherSample
+	}{{nil, nil}, {&bad, sample}} {
+		if _, _, err := weather.Preview("hard", tt.shore, tt.samples, nil); !errors.Is(err, ErrInvalidPreview) {
+			t.Errorf("preview %v: err = %v, want ErrInvalidPreview", tt, err)
+		}
+	}
+}
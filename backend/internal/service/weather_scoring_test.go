@@ -0,0 +1,65 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsOffshore(t *testing.T) {
+	south := 180 // the sea lies to the south of the start
+	tests := []struct {
+		direction int
+		want      bool
+	}{
+		{0, true},    // northerly wind blows straight out to sea
+		{60, true},   // at the edge of the sector
+		{300, true},  // the other edge
+		{61, false},  // just outside
+		{90, false},  // along the shore
+		{180, false}, // onshore
+	}
+	for _, tt := range tests {
+		if got := isOffshore(tt.direction, &south, 60); got != tt.want {
+			t.Errorf("isOffshore(%d°) = %v, want %v", tt.direction, got, tt.want)
+		}
+	}
+	if isOffshore(0, nil, 60) {
+		t.Error("a route without a shore bearing is never offshore")
+	}
+}
+
+func TestScoreWeatherGustsAndOffshore(t *testing.T) {
+	south := 180
+	// 30 °C: no comfort bonus, so penalties are not hidden by the cap of 100.
+	base := ForecastHour{Temperature: 30, WindSpeed: 3, CloudCover: 10}
+	with := func(change func(*ForecastHour)) ForecastHour {
+		h := base
+		change(&h)
+		return h
+	}
+	tests := []struct {
+		name    string
+		hour    ForecastHour
+		shore   *int
+		score   int
+		unsafe  bool
+		explain string
+	}{
+		{"calm", base, nil, 95, false, "комфортные"},
+		{"strong gusts", with(func(h *ForecastHour) { h.WindGusts = 11 }), nil, 80, false, "Порывы до 11.0"},
+		{"dangerous gusts", with(func(h *ForecastHour) { h.WindGusts = 14 }), nil, 39, true, "Порывы ветра до 14.0"},
+		{"offshore on the sea", with(func(h *ForecastHour) { h.WindDirection = 10 }), &south, 75, false, "дует от берега"},
+		{"same wind on the river", with(func(h *ForecastHour) { h.WindDirection = 10 }), nil, 95, false, "комфортные"},
+		{"strong offshore", with(func(h *ForecastHour) { h.WindDirection = 10; h.WindSpeed = 5 }), &south, 39, true, "Отжимной ветер 5.0"},
+		{"onshore", with(func(h *ForecastHour) { h.WindDirection = 180; h.WindSpeed = 5 }), &south, 85, false, "комфортные"},
+	}
+	for _, tt := range tests {
+		a := scoreWeather(tt.hour, tt.shore, DefaultScoringRules)
+		if a.Score != tt.score || a.Unsafe != tt.unsafe || !strings.Contains(a.Explanation, tt.explain) {
+			t.Errorf("%s: score %d, unsafe %v, %q; want %d, %v, mentioning %q", tt.name, a.Score, a.Unsafe, a.Explanation, tt.score, tt.unsafe, tt.explain)
+		}
+		if tt.unsafe && a.Level != "Плохие" {
+			t.Errorf("%s: level %s, want Плохие for an unsafe hour", tt.name, a.Level)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sup-anapa/backend/internal/models"
+	"sup-anapa/backend/internal/repository"
+)
+
+func TestGuardBlocksUnsafeAndReusesCache(t *testing.T) {
+	repo := repository.New()
+	p := &stubWeather{hour: func(time.Time) ForecastHour { return storm }}
+	weather := newTestWeather(repo, p)
+	slot := addSlot(t, repo, day(10).Add(10*time.Hour), 4)
+
+	for i := 0; i < 2; i++ {
+		if err := weather.Guard(slot); !errors.Is(err, ErrUnsafeConditions) {
+			t.Fatalf("guard #%d = %v, want ErrUnsafeConditions", i+1, err)
+		}
+	}
+	if p.Calls() != 1 {
+		t.Errorf("provider calls = %d, want 1: the second guard should use the cached hours", p.Calls())
+	}
+}
+
+func TestGuardFailsOpen(t *testing.T) {
+	tests := []struct {
+		name string
+		p    *stubWeather
+	}{
+		{"provider error", &stubWeather{err: errors.New("503")}},
+		{"provider too slow", &stubWeather{hour: func(time.Time) ForecastHour { return storm }, delay: 5 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.New()
+			weather := NewWeatherService(repo, repo, repo, []WeatherProvider{tt.p}, "", 5*time.Second, 50*time.Millisecond, 20*time.Minute, true, WeatherRules{DefaultDifficulty: DefaultScoringRules})
+			slot := addSlot(t, repo, day(10).Add(10*time.Hour), 4)
+			start := time.Now()
+			if err := weather.Guard(slot); err != nil {
+				t.Errorf("guard = %v, want nil while the forecast is unavailable", err)
+			}
+			if took := time.Since(start); took > time.Second {
+				t.Errorf("guard took %v, want it bounded by the guard budget", took)
+			}
+		})
+	}
+}
+
+func TestForSlotScoresEveryHour(t *testing.T) {
+	repo := repository.New()
+	d := day(10)
+	p := &stubWeather{hour: func(h time.Time) ForecastHour {
+		if h.Equal(d.Add(10 * time.Hour)) {
+			return storm
+		}
+		return calm
+	}}
+	weather := newTestWeather(repo, p)
+	route, err := repo.GetRoute(seedRoute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 09:30–11:00 covers the 09:00 and 10:00 hours.
+	slot := models.TimeSlot{ID: newID(), InstructorID: seedInstructor, RouteID: seedRoute, StartAt: d.Add(9*time.Hour + 30*time.Minute), EndAt: d.Add(11 * time.Hour)}
+
+	w, err := weather.ForSlot(route, slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good, bad := scoreWeather(calm, nil, DefaultScoringRules), scoreWeather(storm, nil, DefaultScoringRules)
+	if len(w.Hours) != 2 || !w.Hours[0].Time.Equal(d.Add(9*time.Hour)) || !w.Hours[1].Time.Equal(d.Add(10*time.Hour)) {
+		t.Fatalf("hours = %+v, want 09:00 and 10:00", w.Hours)
+	}
+	if !w.WorstHour.Equal(d.Add(10*time.Hour)) || w.Score != bad.Score || w.WindSpeed != storm.WindSpeed {
+		t.Errorf("worst hour %s, score %d, wind %.0f; want 10:00 scored %d with the storm wind", w.WorstHour, w.Score, w.WindSpeed, bad.Score)
+	}
+	if avg := (good.Score + bad.Score + 1) / 2; w.AverageScore != avg {
+		t.Errorf("average score = %d, want %d", w.AverageScore, avg)
+	}
+	if !w.Unsafe || w.Hours[0].Unsafe || !w.Hours[1].Unsafe {
+		t.Errorf("unsafe = %v, hours %v/%v; want the slot unsafe because of 10:00", w.Unsafe, w.Hours[0].Unsafe, w.Hours[1].Unsafe)
+	}
+	if _, err := weather.ForSlot(route, slot); err != nil || p.Calls() != 1 {
+		t.Errorf("second ForSlot: err %v, provider calls %d; want the cached hours reused", err, p.Calls())
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE bookings ADD COLUMN price_breakdown JSONB;
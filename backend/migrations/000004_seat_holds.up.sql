@@ -0,0 +1,9 @@
+CREATE TABLE seat_holds (
+  token TEXT PRIMARY KEY,
+  slot_id UUID NOT NULL REFERENCES time_slots(id) ON DELETE CASCADE,
+  seats INT NOT NULL CHECK (seats > 0),
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_seat_holds_expiry ON seat_holds(expires_at);
@@ -0,0 +1,17 @@
+CREATE TABLE waitlist_entries (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  slot_id UUID NOT NULL REFERENCES time_slots(id) ON DELETE CASCADE,
+  customer_name TEXT NOT NULL,
+  phone TEXT NOT NULL,
+  messenger TEXT NOT NULL DEFAULT '',
+  participants INT NOT NULL CHECK (participants > 0),
+  status TEXT NOT NULL,
+  hold_token TEXT,
+  offer_expires_at TIMESTAMPTZ,
+  booking_id UUID REFERENCES bookings(id),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_waitlist_queue ON waitlist_entries(slot_id, status, created_at);
+CREATE INDEX idx_waitlist_hold ON waitlist_entries(hold_token) WHERE hold_token IS NOT NULL;
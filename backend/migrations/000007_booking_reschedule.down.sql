@@ -0,0 +1,3 @@
+ALTER TABLE booking_status_history
+  DROP COLUMN IF EXISTS to_slot_id,
+  DROP COLUMN IF EXISTS from_slot_id;
@@ -0,0 +1,3 @@
+ALTER TABLE booking_status_history
+  ADD COLUMN from_slot_id UUID REFERENCES time_slots(id) ON DELETE SET NULL,
+  ADD COLUMN to_slot_id UUID REFERENCES time_slots(id) ON DELETE SET NULL;
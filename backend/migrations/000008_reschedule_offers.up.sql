@@ -0,0 +1,16 @@
+CREATE TABLE reschedule_offers (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+  token TEXT NOT NULL UNIQUE,
+  slot_ids UUID[] NOT NULL,
+  conditions_level TEXT NOT NULL,
+  score INT NOT NULL,
+  explanation TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL,
+  chosen_slot_id UUID REFERENCES time_slots(id) ON DELETE SET NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_reschedule_offers_pending ON reschedule_offers(booking_id) WHERE status = 'pending';
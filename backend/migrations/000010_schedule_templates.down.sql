@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_time_slots_instructor_start;
+DROP TABLE IF EXISTS schedule_templates;
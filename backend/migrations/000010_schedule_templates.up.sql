@@ -0,0 +1,16 @@
+CREATE TABLE schedule_templates (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  instructor_id UUID NOT NULL REFERENCES instructors(id) ON DELETE CASCADE,
+  route_id UUID NOT NULL REFERENCES routes(id) ON DELETE CASCADE,
+  weekdays INT[] NOT NULL,
+  times TEXT[] NOT NULL,
+  capacity INT NOT NULL CHECK (capacity > 0),
+  valid_from DATE,
+  valid_to DATE,
+  timezone TEXT NOT NULL,
+  is_active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_time_slots_instructor_start ON time_slots(instructor_id, start_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS equipment_reservations;
+ALTER TABLE routes DROP COLUMN IF EXISTS station_id;
+DROP TABLE IF EXISTS stations;
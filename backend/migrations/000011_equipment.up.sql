@@ -0,0 +1,21 @@
+CREATE TABLE stations (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  title TEXT NOT NULL,
+  stock JSONB NOT NULL DEFAULT '{}'::jsonb,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE routes ADD COLUMN station_id UUID REFERENCES stations(id) ON DELETE SET NULL;
+
+CREATE TABLE equipment_reservations (
+  booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+  station_id UUID NOT NULL REFERENCES stations(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL,
+  quantity INT NOT NULL CHECK (quantity > 0),
+  start_at TIMESTAMPTZ NOT NULL,
+  end_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (booking_id, kind)
+);
+
+CREATE INDEX idx_equipment_reservations_station_time ON equipment_reservations(station_id, start_at, end_at);
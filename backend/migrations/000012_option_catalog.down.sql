@@ -0,0 +1,2 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS option_lines;
+DROP TABLE IF EXISTS options;
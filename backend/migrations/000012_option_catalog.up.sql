@@ -0,0 +1,24 @@
+CREATE TABLE options (
+  code TEXT PRIMARY KEY,
+  title TEXT NOT NULL,
+  price INT NOT NULL CHECK (price >= 0),
+  price_type TEXT NOT NULL CHECK (price_type IN ('per_person', 'per_booking')),
+  equipment TEXT,
+  route_ids TEXT[] NOT NULL DEFAULT '{}',
+  is_active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO options (code, title, price, price_type, equipment) VALUES
+  ('photo', 'Фото/видео', 700, 'per_person', NULL),
+  ('drybag', 'Гидромешок', 200, 'per_person', 'drybag'),
+  ('vest', 'Спасательный жилет', 0, 'per_person', 'vest');
+
+ALTER TABLE bookings ADD COLUMN option_lines JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+UPDATE bookings b SET option_lines = COALESCE((
+  SELECT jsonb_agg(jsonb_strip_nulls(jsonb_build_object(
+    'code', o.code, 'title', o.title, 'quantity', b.participants, 'unit_price', o.price,
+    'amount', o.price * b.participants, 'equipment', o.equipment)) ORDER BY o.code)
+  FROM options o WHERE b.options ->> o.code = 'true'), '[]'::jsonb);
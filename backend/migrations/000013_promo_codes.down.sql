@@ -0,0 +1,2 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS promo_code;
+DROP TABLE IF EXISTS promo_codes;
@@ -0,0 +1,21 @@
+CREATE TABLE promo_codes (
+  code TEXT PRIMARY KEY,
+  kind TEXT NOT NULL CHECK (kind IN ('percent', 'fixed')),
+  value INT NOT NULL CHECK (value > 0),
+  valid_from TIMESTAMPTZ,
+  valid_to TIMESTAMPTZ,
+  max_uses INT NOT NULL DEFAULT 0 CHECK (max_uses >= 0),
+  used INT NOT NULL DEFAULT 0,
+  min_participants INT NOT NULL DEFAULT 0,
+  route_ids TEXT[] NOT NULL DEFAULT '{}',
+  instructor_ids TEXT[] NOT NULL DEFAULT '{}',
+  is_active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CHECK (kind <> 'percent' OR value <= 100),
+  CHECK (valid_to IS NULL OR valid_from IS NULL OR valid_to > valid_from)
+);
+
+-- No foreign key: a booking keeps the code it was priced with even after
+-- the code is deleted.
+ALTER TABLE bookings ADD COLUMN promo_code TEXT;
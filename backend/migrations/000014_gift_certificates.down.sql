@@ -0,0 +1,3 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS gift_amount, DROP COLUMN IF EXISTS gift_code;
+DROP TABLE IF EXISTS gift_redemptions;
+DROP TABLE IF EXISTS gift_certificates;
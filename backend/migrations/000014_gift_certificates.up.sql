@@ -0,0 +1,25 @@
+CREATE TABLE gift_certificates (
+  code TEXT PRIMARY KEY,
+  kind TEXT NOT NULL CHECK (kind IN ('value', 'route')),
+  amount INT NOT NULL DEFAULT 0 CHECK (amount >= 0),
+  balance INT NOT NULL DEFAULT 0 CHECK (balance >= 0),
+  route_id UUID REFERENCES routes(id),
+  participants INT NOT NULL DEFAULT 0,
+  recipient TEXT,
+  note TEXT,
+  status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'redeemed', 'voided')),
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE gift_redemptions (
+  booking_id UUID PRIMARY KEY REFERENCES bookings(id) ON DELETE CASCADE,
+  code TEXT NOT NULL REFERENCES gift_certificates(code),
+  amount INT NOT NULL CHECK (amount >= 0),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_gift_redemptions_code ON gift_redemptions(code);
+
+ALTER TABLE bookings ADD COLUMN gift_code TEXT, ADD COLUMN gift_amount INT NOT NULL DEFAULT 0;
@@ -0,0 +1,16 @@
+CREATE TABLE payments (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+  provider TEXT NOT NULL,
+  provider_ref TEXT NOT NULL,
+  mode TEXT NOT NULL CHECK (mode IN ('full', 'deposit')),
+  amount INT NOT NULL CHECK (amount > 0),
+  refunded INT NOT NULL DEFAULT 0 CHECK (refunded >= 0 AND refunded <= amount),
+  status TEXT NOT NULL CHECK (status IN ('pending', 'succeeded', 'failed', 'refunded')),
+  confirmation_url TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (provider, provider_ref)
+);
+
+CREATE INDEX idx_payments_booking ON payments(booking_id);
@@ -0,0 +1 @@
+ALTER TABLE bookings ADD COLUMN refund_amount INT NOT NULL DEFAULT 0, ADD COLUMN refund_rule TEXT;
@@ -0,0 +1,2 @@
+ALTER TABLE weather_snapshots DROP COLUMN IF EXISTS wind_direction, DROP COLUMN IF EXISTS wind_gusts;
+ALTER TABLE routes DROP COLUMN IF EXISTS shore_bearing;
@@ -0,0 +1,2 @@
+ALTER TABLE routes ADD COLUMN shore_bearing INT CHECK (shore_bearing BETWEEN 0 AND 359);
+ALTER TABLE weather_snapshots ADD COLUMN wind_gusts DOUBLE PRECISION NOT NULL DEFAULT 0, ADD COLUMN wind_direction INT NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE weather_snapshots DROP COLUMN IF EXISTS sea_temperature, DROP COLUMN IF EXISTS wave_period, DROP COLUMN IF EXISTS wave_height;
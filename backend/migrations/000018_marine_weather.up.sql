@@ -0,0 +1 @@
+ALTER TABLE weather_snapshots ADD COLUMN wave_height DOUBLE PRECISION, ADD COLUMN wave_period DOUBLE PRECISION, ADD COLUMN sea_temperature DOUBLE PRECISION;
@@ -0,0 +1 @@
+ALTER TABLE weather_snapshots DROP COLUMN IF EXISTS provider;
@@ -0,0 +1 @@
+ALTER TABLE weather_snapshots ADD COLUMN provider TEXT NOT NULL DEFAULT '';
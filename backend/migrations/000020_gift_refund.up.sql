@@ -0,0 +1 @@
+ALTER TABLE bookings ADD COLUMN gift_refund INT NOT NULL DEFAULT 0;
@@ -3,9 +3,12 @@ INSERT INTO instructors (id,name,photo_url,bio,rating,reviews_count,experience_y
 ('22222222-2222-2222-2222-222222222222','Мария Волна','https://images.unsplash.com/photo-1494790108377-be9c29b29330','Тренировки и SUP-фитнес на реке.',4.8,96,5,'["спорт","новички"]','["RU"]',3200,true),
 ('33333333-3333-3333-3333-333333333333','Илья Бриз','https://images.unsplash.com/photo-1506794778202-cad84cf45f1d','Фото-тур на закате, уверенный темп.',4.7,87,6,'["закат","спорт"]','["RU","EN"]',3500,true);
 
-INSERT INTO routes (id,title,duration_minutes,difficulty,base_price,description,location_lat,location_lng,location_title) VALUES
-('aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa','Река у Анапы — спокойная вода',90,'easy',2500,'Идеально для первого SUP: тихая вода, короткие остановки.',45.092,37.268,'Старт: река у Анапы'),
-('bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb','Река у Анапы — закатный маршрут',120,'medium',3200,'Маршрут к золотому часу с фотопаузами.',45.092,37.268,'Старт: река у Анапы');
+INSERT INTO stations (id,title,stock) VALUES
+('cccccccc-cccc-cccc-cccc-cccccccccccc','Станция на реке у Анапы','{"board":10,"paddle":10,"vest":12,"drybag":6}');
+
+INSERT INTO routes (id,title,duration_minutes,difficulty,base_price,description,location_lat,location_lng,location_title,station_id) VALUES
+('aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa','Река у Анапы — спокойная вода',90,'easy',2500,'Идеально для первого SUP: тихая вода, короткие остановки.',45.092,37.268,'Старт: река у Анапы','cccccccc-cccc-cccc-cccc-cccccccccccc'),
+('bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb','Река у Анапы — закатный маршрут',120,'medium',3200,'Маршрут к золотому часу с фотопаузами.',45.092,37.268,'Старт: река у Анапы','cccccccc-cccc-cccc-cccc-cccccccccccc');
 
 DO $$
 DECLARE d INT;
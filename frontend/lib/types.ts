@@ -1,4 +1,8 @@
 export type Instructor = { id:string; name:string; photo_url:string; bio:string; rating:number; reviews_count:number; experience_years:number; tags:string[]; languages:string[]; base_price:number; is_active:boolean };
-export type Route = { id:string; title:string; duration_minutes:number; difficulty:string; base_price:number; description:string; location_lat:number; location_lng:number; location_title:string };
+export type Route = { id:string; title:string; duration_minutes:number; difficulty:string; base_price:number; description:string; location_lat:number; location_lng:number; location_title:string; shore_bearing?:number };
 export type Slot = { id:string; instructor_id:string; route_id:string; start_at:string; end_at:string; capacity:number; remaining:number; status:string };
-export type Weather = { temperature:number; wind_speed:number; precipitation:number; cloud_cover:number; conditions_level:string; explanation:string; score:number; suggested_slots?: Slot[] };
+export type HourlyWeather = { time:string; temperature:number; wind_speed:number; wind_gusts:number; wind_direction:number; precipitation:number; cloud_cover:number; wave_height?:number; wave_period?:number; sea_temperature?:number; conditions_level:string; score:number; offshore:boolean; unsafe:boolean };
+export type Weather = { temperature:number; wind_speed:number; precipitation:number; cloud_cover:number; wave_height?:number; wave_period?:number; sea_temperature?:number; conditions_level:string; explanation:string; score:number; wind_gusts:number; wind_direction:number; offshore:boolean; unsafe:boolean; safety_note?:string; average_score:number; worst_hour:string; provider?:string; hours?: HourlyWeather[]; suggested_slots?: Slot[] };
+export type Option = { code:string; title:string; price:number; price_type:'per_person'|'per_booking'; equipment?:string };
+export type PromoCheck = { valid:boolean; code:string; discount?:number; price_total?:number; error?:string };
+export type Payment = { id:string; booking_id:string; mode:'full'|'deposit'; amount:number; refunded:number; status:'pending'|'succeeded'|'failed'|'refunded'; confirmation_url?:string };